@@ -13,6 +13,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet"
 )
 
 var walletCmd = &cmds.Command{
@@ -20,9 +21,10 @@ var walletCmd = &cmds.Command{
 		Tagline: "Manage your filecoin wallets",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"balance": balanceCmd,
-		"import":  walletImportCmd,
-		"export":  walletExportCmd,
+		"balance":      balanceCmd,
+		"import":       walletImportCmd,
+		"export":       walletExportCmd,
+		"sign-message": walletSignMessageCmd,
 	},
 }
 
@@ -258,3 +260,54 @@ var walletExportCmd = &cmds.Command{
 		}),
 	},
 }
+
+var walletSignMessageCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Sign a message with a key in the wallet, without a daemon",
+		ShortDescription: `Signs an unsigned message, as printed by "message create", with the key of its
+sender and prints the signed message as JSON for "message sendsigned". The message is
+given as the argument or on stdin.
+
+The command reads the wallet in the repo directly and needs no daemon, so it can run
+on an offline machine. It fails while a daemon is running on the repo.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("message", true, false, "Unsigned Json message").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		var msg types.UnsignedMessage
+		if err := json.Unmarshal([]byte(req.Arguments[0]), &msg); err != nil {
+			return errors.Wrap(err, "invalid message")
+		}
+
+		rep, err := getRepo(req)
+		if err != nil {
+			return errors.Wrap(err, "failed to open repo")
+		}
+		defer func() { _ = rep.Close() }()
+
+		backend, err := wallet.NewDSBackend(rep.WalletDatastore())
+		if err != nil {
+			return errors.Wrap(err, "failed to open wallet")
+		}
+		signed, err := signMessage(backend, &msg)
+		if err != nil {
+			return err
+		}
+		return re.Emit(signed)
+	},
+	Type: types.SignedMessage{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, signed *types.SignedMessage) error {
+			return json.NewEncoder(w).Encode(signed)
+		}),
+	},
+}
+
+// signMessage signs `msg` with the key of its sender in `backend`.
+func signMessage(backend wallet.Backend, msg *types.UnsignedMessage) (*types.SignedMessage, error) {
+	if !backend.HasAddress(msg.From) {
+		return nil, errors.Errorf("no key for %s in the wallet", msg.From)
+	}
+	return types.NewSignedMessage(*msg, backend)
+}
@@ -4,16 +4,22 @@ package commands
 import (
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"strconv"
 	"strings"
 
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cst"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/ipfs/go-cid"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	files "github.com/ipfs/go-ipfs-files"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
 )
 
 var chainCmd = &cmds.Command{
@@ -21,13 +27,21 @@ var chainCmd = &cmds.Command{
 		Tagline: "Inspect the filecoin blockchain",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"export":   storeExportCmd,
-		"head":     storeHeadCmd,
-		"import":   storeImportCmd,
-		"ls":       storeLsCmd,
-		"status":   storeStatusCmd,
-		"set-head": storeSetHeadCmd,
-		"sync":     storeSyncCmd,
+		"check-index": storeCheckIndexCmd,
+		"checkpoint":  storeCheckpointCmd,
+		"export":      storeExportCmd,
+		"fsck":        storeFsckCmd,
+		"gc":          storeGCCmd,
+		"get":         storeGetCmd,
+		"head":        storeHeadCmd,
+		"import":      storeImportCmd,
+		"ls":          storeLsCmd,
+		"messages":    storeMessagesCmd,
+		"notify":      storeNotifyCmd,
+		"status":      storeStatusCmd,
+		"set-head":    storeSetHeadCmd,
+		"state-diff":  storeStateDiffCmd,
+		"sync":        storeSyncCmd,
 	},
 }
 
@@ -56,6 +70,40 @@ var storeHeadCmd = &cmds.Command{
 	},
 }
 
+var storeGetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Get the tipset at a height of the blockchain",
+		ShortDescription: `Provides the CIDs of the blocks of the tipset at the given height of the
+current chain. If the height is a null round the closest tipset below it is returned.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("height", "Height of the tipset to get"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		height, ok := req.Options["height"].(uint64)
+		if !ok {
+			return errors.New("must specify a height with --height")
+		}
+		ts, err := GetPorcelainAPI(env).ChainGetTipSetAtHeight(req.Context, height)
+		if err != nil {
+			return err
+		}
+		return re.Emit(ts.Key())
+	},
+	Type: []cid.Cid{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res []cid.Cid) error {
+			for _, r := range res {
+				_, err := fmt.Fprintln(w, r.String())
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
 var storeLsCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline:          "List blocks in the blockchain",
@@ -116,6 +164,136 @@ var storeLsCmd = &cmds.Command{
 	},
 }
 
+// ChainMessagesResult is a message included in the chain with its receipt.
+type ChainMessagesResult struct {
+	Cid     cid.Cid
+	Height  uint64
+	Message *types.SignedMessage
+	Receipt *types.MessageReceipt
+}
+
+var storeMessagesCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the messages sent from or to an address",
+		ShortDescription: `Lists the messages on the chain sent from the --from address and to the --to
+address, with their receipts and inclusion heights, in order of inclusion. At
+least one address is required. Messages are found through an index built as the
+chain is synced, which briefly lags the head.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address the messages were sent from"),
+		cmdkit.StringOption("to", "Address the messages were sent to"),
+		cmdkit.Uint64Option("since-height", "Lowest inclusion height of the messages to list"),
+		cmdkit.Uint64Option("until-height", "Highest inclusion height of the messages to list"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		var from, to address.Address
+		var err error
+		if o, ok := req.Options["from"].(string); ok {
+			if from, err = address.NewFromString(o); err != nil {
+				return errors.Wrap(err, "invalid from address")
+			}
+		}
+		if o, ok := req.Options["to"].(string); ok {
+			if to, err = address.NewFromString(o); err != nil {
+				return errors.Wrap(err, "invalid to address")
+			}
+		}
+		if from.Empty() && to.Empty() {
+			return errors.New("--from or --to is required")
+		}
+		sinceHeight, _ := req.Options["since-height"].(uint64)
+		untilHeight, ok := req.Options["until-height"].(uint64)
+		if !ok {
+			untilHeight = math.MaxUint64
+		}
+
+		msgs, err := GetPorcelainAPI(env).MessageFindByAddress(req.Context, from, to, sinceHeight, untilHeight)
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			// BLS messages are included unsigned and listed with no signature.
+			c, err := msg.Message.Cid()
+			if len(msg.Message.Signature) == 0 {
+				c, err = msg.Message.Message.Cid()
+			}
+			if err != nil {
+				return err
+			}
+			if err := re.Emit(&ChainMessagesResult{
+				Cid:     c,
+				Height:  msg.Block.Height,
+				Message: msg.Message,
+				Receipt: msg.Receipt,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: ChainMessagesResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *ChainMessagesResult) error {
+			_, err := fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\n", res.Height, res.Cid, res.Message.Message.From,
+				res.Message.Message.To, res.Message.Message.Value, res.Receipt.ExitCode)
+			return err
+		}),
+	},
+}
+
+var storeStateDiffCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Compare two state roots",
+		ShortDescription: `Lists the actors added (+), removed (-) or changed (~) going from the first
+state root to the second, with the changes of their balance, nonce (CallSeqNum),
+code and head. For builtin actors the changes of the fields of their state,
+prefixed with "State.", are listed too. Maps and HAMTs in the state, such as
+the power table, are compared entry by entry, with the key of each changed
+entry in brackets.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("root-a", true, false, "CID of the state root to compare from"),
+		cmdkit.StringArg("root-b", true, false, "CID of the state root to compare to"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		roots, err := cidsFromSlice(req.Arguments)
+		if err != nil {
+			return err
+		}
+		diffs, err := GetPorcelainAPI(env).ChainStateDiff(req.Context, roots[0], roots[1])
+		if err != nil {
+			return err
+		}
+		for _, diff := range diffs {
+			if err := re.Emit(diff); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: cst.ActorStateDiff{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *cst.ActorStateDiff) error {
+			kind := "~"
+			if res.Before == nil {
+				kind = "+"
+			} else if res.After == nil {
+				kind = "-"
+			}
+			if _, err := fmt.Fprintf(w, "%s %s\n", kind, res.Address); err != nil {
+				return err
+			}
+			for _, change := range res.Changes {
+				if _, err := fmt.Fprintf(w, "\t%s: %s -> %s\n", change.Field, change.Before, change.After); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
 var storeStatusCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Show status of chain sync operation.",
@@ -132,17 +310,89 @@ var storeStatusCmd = &cmds.Command{
 var storeSetHeadCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Set the chain head to a specific tipset key.",
+		ShortDescription: `The chain of the new head must include the checkpointed tipset, if one is
+set, unless --force is given.`,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("cids", true, true, "CID's of the blocks of the tipset to set the chain head to."),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("force", "Set the head even if its chain does not include the checkpoint"),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		headCids, err := cidsFromSlice(req.Arguments)
 		if err != nil {
 			return err
 		}
+		force, _ := req.Options["force"].(bool)
 		maybeNewHead := block.NewTipSetKey(headCids...)
-		return GetPorcelainAPI(env).ChainSetHead(req.Context, maybeNewHead)
+		return GetPorcelainAPI(env).ChainSetHead(req.Context, maybeNewHead, force)
+	},
+}
+
+var storeCheckpointCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the checkpointed tipset",
+		ShortDescription: `A checkpoint pins a tipset the node must never reorg below. Chains that do
+not include the checkpointed tipset are rejected by the syncer.`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"clear": storeCheckpointClearCmd,
+		"set":   storeCheckpointSetCmd,
+		"show":  storeCheckpointShowCmd,
+	},
+}
+
+var storeCheckpointSetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Checkpoint a tipset on the current chain",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cids", true, true, "CID's of the blocks of the tipset to checkpoint."),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		cids, err := cidsFromSlice(req.Arguments)
+		if err != nil {
+			return err
+		}
+		return GetPorcelainAPI(env).ChainSetCheckpoint(req.Context, block.NewTipSetKey(cids...))
+	},
+}
+
+var storeCheckpointClearCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove the checkpoint",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return GetPorcelainAPI(env).ChainClearCheckpoint()
+	},
+}
+
+var storeCheckpointShowCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the CIDs of the checkpointed tipset",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		key, err := GetPorcelainAPI(env).ChainCheckpoint()
+		if err != nil {
+			return err
+		}
+		return re.Emit(key)
+	},
+	Type: []cid.Cid{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res []cid.Cid) error {
+			if len(res) == 0 {
+				_, err := fmt.Fprintln(w, "no checkpoint set")
+				return err
+			}
+			for _, r := range res {
+				if _, err := fmt.Fprintln(w, r.String()); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
 	},
 }
 
@@ -177,15 +427,99 @@ var storeSyncCmd = &cmds.Command{
 	},
 }
 
+// ChainNotifyResult is a tipset reverted from or applied to the head chain by
+// a head change.
+type ChainNotifyResult struct {
+	Type   string
+	Height uint64
+	Key    block.TipSetKey
+}
+
+var storeNotifyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Follow changes of the chain head",
+		ShortDescription: `Streams the tipsets reverted and applied by each change of the chain head.
+The first tipset applied is the current head. Reverted tipsets are listed from
+the old head down to the common ancestor of the old and new heads, applied
+tipsets from the common ancestor up to the new head.
+
+Changes of the head are not held back for a slow reader. A reader that falls
+more than 16 changes behind is dropped and the stream ends with an error, as it
+does if a change is missed for any other reason. Run the command again to
+resume from the current head.`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		sub := GetPorcelainAPI(env).ChainHeadChanges(req.Context)
+		defer sub.Unsubscribe()
+		for change := range sub.Changes() {
+			if err := emitTipSets(re, "revert", change.Revert); err != nil {
+				return err
+			}
+			if err := emitTipSets(re, "apply", change.Apply); err != nil {
+				return err
+			}
+		}
+		if req.Context.Err() != nil {
+			return nil
+		}
+		if err := sub.Err(); err != nil {
+			return errors.Wrap(err, "head change subscription ended")
+		}
+		return nil
+	},
+	Type: ChainNotifyResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *ChainNotifyResult) error {
+			_, err := fmt.Fprintf(w, "%s\t%d\t%s\n", res.Type, res.Height, res.Key.String())
+			return err
+		}),
+	},
+}
+
+func emitTipSets(re cmds.ResponseEmitter, typ string, tips []block.TipSet) error {
+	for _, ts := range tips {
+		h, err := ts.Height()
+		if err != nil {
+			return err
+		}
+		if err := re.Emit(&ChainNotifyResult{Type: typ, Height: h, Key: ts.Key()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 var storeExportCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Export the chain store to a car file.",
+		ShortDescription: `Exports the chain from the given tipset back to genesis with all messages and
+receipts. With --recent all headers are exported but messages, receipts and
+state only for the most recent tipsets, producing a snapshot to bootstrap a node
+from. With --to-height only the tipsets between --from-height and --to-height
+are exported, along with the state the lowest of them was computed on.`,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("file", true, false, "File to export chain data to."),
 		cmdkit.StringArg("cids", true, true, "CID's of the blocks of the tipset to export from."),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("recent", "Export messages and state for only this many of the most recent tipsets"),
+		cmdkit.Uint64Option("from-height", "Lowest height of the range of tipsets to export"),
+		cmdkit.Uint64Option("to-height", "Highest height of the range of tipsets to export"),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		var opts chain.ExportOptions
+		opts.RecentTipSets, _ = req.Options["recent"].(uint64)
+		opts.FromHeight, _ = req.Options["from-height"].(uint64)
+		toHeight, hasTo := req.Options["to-height"].(uint64)
+		if _, hasFrom := req.Options["from-height"].(uint64); hasFrom && !hasTo {
+			return errors.New("--from-height requires --to-height")
+		}
+		if hasTo && toHeight == 0 {
+			return errors.New("--to-height must be above genesis")
+		}
+		opts.ToHeight = toHeight
+
 		f, err := os.Create(req.Arguments[0])
 		if err != nil {
 			return err
@@ -198,20 +532,66 @@ var storeExportCmd = &cmds.Command{
 		}
 		expKey := block.NewTipSetKey(expCids...)
 
-		if err := GetPorcelainAPI(env).ChainExport(req.Context, expKey, f); err != nil {
+		if err := GetPorcelainAPI(env).ChainExport(req.Context, expKey, opts, f); err != nil {
 			return err
 		}
 		return nil
 	},
 }
 
+var storeGCCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Delete old chain state from the block store.",
+		ShortDescription: `Deletes the state trees, actor storage and message receipts of tipsets more
+than keep-epochs epochs below finality. Block headers, messages and the genesis
+state are always kept. Defaults to the stategc.keepEpochs config value.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("keep-epochs", "Number of finalized epochs to keep state for"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		keepEpochs, ok := req.Options["keep-epochs"].(uint64)
+		if !ok {
+			configured, err := GetPorcelainAPI(env).ConfigGet("stategc.keepEpochs")
+			if err != nil {
+				return err
+			}
+			v, ok := configured.(uint64)
+			if !ok {
+				return errors.Errorf("stategc.keepEpochs config value %v is not an unsigned integer", configured)
+			}
+			keepEpochs = v
+		}
+		res, err := GetPorcelainAPI(env).ChainPruneState(req.Context, keepEpochs)
+		if err != nil {
+			return err
+		}
+		return re.Emit(res)
+	},
+	Type: chain.PruneResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *chain.PruneResult) error {
+			_, err := fmt.Fprintf(w, "pruned state of %d tipsets (%d blocks), state kept from height %d\n", res.TipSetsPruned, res.BlocksDeleted, res.PrunedHeight)
+			return err
+		}),
+	},
+}
+
 var storeImportCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Import the chain from a car file.",
+		ShortDescription: `Imports the chain from a car file into the block store. With --validate the
+imported chain is checked and executed by the syncer before its head is staged.
+With --trust-height the state recorded in the imported headers is trusted up
+to that height instead of being recomputed.`,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.FileArg("file", true, false, "File to import chain data from.").EnableStdin(),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("validate", "Validate the imported chain before staging its head"),
+		cmdkit.Uint64Option("trust-height", "Trust state at or below this height instead of re-executing it, implies --validate"),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		iter := req.Files.Entries()
 		if !iter.Next() {
@@ -223,10 +603,132 @@ var storeImportCmd = &cmds.Command{
 			return fmt.Errorf("given file was not a files.File")
 		}
 		defer func() { _ = fi.Close() }()
-		headKey, err := GetPorcelainAPI(env).ChainImport(req.Context, fi)
+
+		validate, _ := req.Options["validate"].(bool)
+		trustHeight, trust := req.Options["trust-height"].(uint64)
+		var headKey block.TipSetKey
+		var err error
+		if validate || trust {
+			headKey, err = GetPorcelainAPI(env).ChainImportValidated(req.Context, fi, trustHeight)
+		} else {
+			headKey, err = GetPorcelainAPI(env).ChainImport(req.Context, fi)
+		}
 		if err != nil {
 			return err
 		}
 		return re.Emit(headKey)
 	},
 }
+
+var storeFsckCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Check the chain data for missing or corrupt objects.",
+		ShortDescription: `Checks from the head down to genesis, or down --depth tipsets, that every block
+header, TxMeta, message AMT, receipt AMT and state root is present and decodes,
+and that the state root and receipts recorded for each tipset match those in the
+headers of its child. State and receipts removed by chain gc are not checked.
+With --repair missing headers and messages are fetched from peers and missing
+state is recomputed by executing the affected tipsets.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("depth", "Number of tipsets to check below the head, all if unset"),
+		cmdkit.BoolOption("repair", "Repair the problems found"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		depth, _ := req.Options["depth"].(uint64)
+		repair, _ := req.Options["repair"].(bool)
+		report, err := GetPorcelainAPI(env).ChainFsck(req.Context, depth, repair)
+		if err != nil {
+			return err
+		}
+		return re.Emit(report)
+	},
+	Type: chain.FsckReport{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, report *chain.FsckReport) error {
+			if _, err := fmt.Fprintf(w, "checked %d tipsets\n", report.TipSetsChecked); err != nil {
+				return err
+			}
+			if report.PrunedHeight > 0 {
+				if _, err := fmt.Fprintf(w, "state below height %d is pruned and was not checked\n", report.PrunedHeight); err != nil {
+					return err
+				}
+			}
+			for _, p := range report.Problems {
+				if _, err := fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", p.Height, p.TipSet, p.Kind, p.Error); err != nil {
+					return err
+				}
+			}
+			if report.Truncated {
+				if _, err := fmt.Fprintln(w, "check stopped at a tipset with no headers"); err != nil {
+					return err
+				}
+			}
+			status := "chain is consistent"
+			if report.Repaired {
+				status = "chain repaired"
+			} else if !report.Consistent() {
+				status = "chain is inconsistent"
+			}
+			_, err := fmt.Fprintln(w, status)
+			return err
+		}),
+	},
+}
+
+var storeCheckIndexCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Check the persisted tipset index for consistency.",
+		ShortDescription: `Checks that every tipset on the chain is recorded in the persisted tipset
+index and that the index holds no entries for unknown tipsets. With --repair
+missing entries are written, dangling entries removed and the index checkpoint
+rewritten. Tipsets with no recorded state cannot be repaired by this command.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("repair", "Repair the index if it is inconsistent"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		repair, _ := req.Options["repair"].(bool)
+		report, err := GetPorcelainAPI(env).ChainCheckTipIndex(req.Context, repair)
+		if err != nil {
+			return err
+		}
+		return re.Emit(report)
+	},
+	Type: chain.TipIndexReport{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, report *chain.TipIndexReport) error {
+			if _, err := fmt.Fprintf(w, "checked %d tipsets\n", report.TipSetsChecked); err != nil {
+				return err
+			}
+			for _, key := range report.MissingMetadata {
+				if _, err := fmt.Fprintf(w, "missing state: %s\n", key); err != nil {
+					return err
+				}
+			}
+			for _, key := range report.MissingEntries {
+				if _, err := fmt.Fprintf(w, "missing index entry: %s\n", key); err != nil {
+					return err
+				}
+			}
+			if report.DanglingEntries > 0 {
+				if _, err := fmt.Fprintf(w, "dangling index entries: %d\n", report.DanglingEntries); err != nil {
+					return err
+				}
+			}
+			if !report.CheckpointValid {
+				if _, err := fmt.Fprintln(w, "index checkpoint missing or invalid"); err != nil {
+					return err
+				}
+			}
+			status := "index is inconsistent"
+			if report.Consistent() {
+				status = "index is consistent"
+			} else if report.Repaired {
+				status = "index repaired"
+			}
+			_, err := fmt.Fprintln(w, status)
+			return err
+		}),
+	},
+}
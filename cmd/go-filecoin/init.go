@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
@@ -18,6 +19,7 @@ import (
 	cbor "github.com/ipfs/go-ipld-cbor"
 	logging "github.com/ipfs/go-log"
 	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/fixtures"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/node"
@@ -44,6 +46,8 @@ var initCmd = &cmds.Command{
 		cmdkit.BoolOption(DevnetStaging, "when set, populates config bootstrap addrs with the dns multiaddrs of the staging devnet and other staging devnet specific bootstrap parameters."),
 		cmdkit.BoolOption(DevnetNightly, "when set, populates config bootstrap addrs with the dns multiaddrs of the nightly devnet and other nightly devnet specific bootstrap parameters"),
 		cmdkit.BoolOption(DevnetUser, "when set, populates config bootstrap addrs with the dns multiaddrs of the user devnet and other user devnet specific bootstrap parameters"),
+		cmdkit.StringOption(InstantSealSigner, "when set, creates a genesis block for a development network using instant-seal consensus, where blocks are sealed by this address as soon as messages arrive"),
+		cmdkit.UintOption(InstantSealInterval, "with instant-seal consensus, the number of seconds between blocks sealed when no messages arrive, 0 to seal only when messages arrive"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		repoDir, _ := req.Options[OptionRepoDir].(string)
@@ -66,9 +70,16 @@ var initCmd = &cmds.Command{
 		defer func() { _ = rep.Close() }()
 
 		genesisFileSource, _ := req.Options[GenesisFile].(string)
+		genesisOpts, err := genesisOptsFromOptions(req.Options)
+		if err != nil {
+			return err
+		}
+		if genesisFileSource != "" && len(genesisOpts) > 0 {
+			return fmt.Errorf("cannot select instant-seal consensus with a genesis file, the genesis file selects the consensus protocol")
+		}
 		// Writing to the repo here is messed up; this should create a genesis init function that
 		// writes to the repo when invoked.
-		genesisFile, err := loadGenesis(req.Context, rep, genesisFileSource)
+		genesisFile, err := loadGenesis(req.Context, rep, genesisFileSource, genesisOpts...)
 		if err != nil {
 			return err
 		}
@@ -150,14 +161,32 @@ func setConfigFromOptions(cfg *config.Config, options cmdkit.OptMap) error {
 	return nil
 }
 
+// genesisOptsFromOptions returns the options of the genesis block created when no genesis file
+// is given.
+func genesisOptsFromOptions(options cmdkit.OptMap) ([]consensus.GenOption, error) {
+	signer, ok := options[InstantSealSigner].(string)
+	if !ok {
+		if _, ok := options[InstantSealInterval]; ok {
+			return nil, fmt.Errorf("%s requires %s", InstantSealInterval, InstantSealSigner)
+		}
+		return nil, nil
+	}
+	signerAddr, err := address.NewFromString(signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid instant-seal signer")
+	}
+	interval, _ := options[InstantSealInterval].(uint)
+	return []consensus.GenOption{consensus.InstantSeal(signerAddr, time.Duration(interval)*time.Second)}, nil
+}
+
 func initTextEncoder(_ *cmds.Request, w io.Writer, val interface{}) error {
 	_, err := fmt.Fprintf(w, val.(string))
 	return err
 }
 
-func loadGenesis(ctx context.Context, rep repo.Repo, sourceName string) (consensus.GenesisInitFunc, error) {
+func loadGenesis(ctx context.Context, rep repo.Repo, sourceName string, opts ...consensus.GenOption) (consensus.GenesisInitFunc, error) {
 	if sourceName == "" {
-		return consensus.MakeGenesisFunc(consensus.ProofsMode(types.LiveProofsMode)), nil
+		return consensus.MakeGenesisFunc(append([]consensus.GenOption{consensus.ProofsMode(types.LiveProofsMode)}, opts...)...), nil
 	}
 
 	source, err := openGenesisSource(sourceName)
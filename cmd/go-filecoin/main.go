@@ -6,6 +6,7 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"reflect"
 	"syscall"
 
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
@@ -17,6 +18,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paths"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 )
@@ -78,6 +80,12 @@ const (
 	// DevnetUser populates config bootstrap addrs with the dns multiaddrs of the user devnet and other user devnet specific bootstrap parameters
 	DevnetUser = "devnet-user"
 
+	// InstantSealSigner selects instant-seal consensus for a new network, with blocks signed by the given address
+	InstantSealSigner = "instant-seal-signer"
+
+	// InstantSealInterval is the number of seconds between blocks sealed by instant-seal consensus when no messages arrive
+	InstantSealInterval = "instant-seal-interval"
+
 	// IsRelay when set causes the the daemon to provide libp2p relay
 	// services allowing other filecoin nodes behind NATs to talk directly.
 	IsRelay = "is-relay"
@@ -181,6 +189,7 @@ var rootSubcmdsDaemon = map[string]*cmds.Command{
 	"protocol":         protocolCmd,
 	"retrieval-client": retrievalClientCmd,
 	"show":             showCmd,
+	"slashing":         slashingCmd,
 	"stats":            statsCmd,
 	"swarm":            swarmCmd,
 	"wallet":           walletCmd,
@@ -308,12 +317,22 @@ func getAPIAddress(req *cmds.Request) (string, error) {
 	return host, nil
 }
 
+// subcommands of daemon commands that run locally, on a repo without a daemon
+var subcmdPathsLocal = [][]string{
+	{"wallet", "sign-message"},
+}
+
 func requiresDaemon(req *cmds.Request) bool {
 	for cmd := range rootSubcmdsLocal {
 		if len(req.Path) > 0 && req.Path[0] == cmd {
 			return false
 		}
 	}
+	for _, path := range subcmdPathsLocal {
+		if len(req.Path) >= len(path) && reflect.DeepEqual(req.Path[:len(path)], path) {
+			return false
+		}
+	}
 	return true
 }
 
@@ -335,19 +354,16 @@ func isConnectionRefused(err error) bool {
 	return syscallErr.Err == syscall.ECONNREFUSED
 }
 
-var priceOption = cmdkit.StringOption("gas-price", "Price (FIL e.g. 0.00013) to pay for each GasUnit consumed mining this message")
+var priceOption = cmdkit.StringOption("gas-price", "Price (FIL e.g. 0.00013) to pay for each GasUnit consumed mining this message (estimated from recent blocks if not given)")
 var limitOption = cmdkit.Uint64Option("gas-limit", "Maximum GasUnits this message is allowed to consume")
 var previewOption = cmdkit.BoolOption("preview", "Preview the Gas cost of this command without actually executing it")
 
-func parseGasOptions(req *cmds.Request) (types.AttoFIL, types.GasUnits, bool, error) {
-	priceOption := req.Options["gas-price"]
-	if priceOption == nil {
-		return types.ZeroAttoFIL, types.NewGasUnits(0), false, errors.New("gas-price option is required")
-	}
-
-	price, ok := types.NewAttoFILFromFILString(priceOption.(string))
-	if !ok {
-		return types.ZeroAttoFIL, types.NewGasUnits(0), false, errors.New("invalid gas price (specify FIL as a decimal number)")
+// parseGasOptions parses the gas options of a message sending command. If no gas price is
+// given, the price is estimated for the message to be included in the next tipset.
+func parseGasOptions(req *cmds.Request, env cmds.Environment) (types.AttoFIL, types.GasUnits, bool, error) {
+	price, err := parseGasPrice(req, env)
+	if err != nil {
+		return types.ZeroAttoFIL, types.NewGasUnits(0), false, err
 	}
 
 	limitOption := req.Options["gas-limit"]
@@ -365,3 +381,21 @@ func parseGasOptions(req *cmds.Request) (types.AttoFIL, types.GasUnits, bool, er
 
 	return price, types.NewGasUnits(gasLimitInt), preview, nil
 }
+
+// parseGasPrice parses the gas price option of a message sending command, estimating the price
+// for the message to be included in the next tipset if it is not given.
+func parseGasPrice(req *cmds.Request, env cmds.Environment) (types.AttoFIL, error) {
+	priceOption := req.Options["gas-price"]
+	if priceOption == nil {
+		estimate, err := GetPorcelainAPI(env).MessageEstimateGasPrice(req.Context, porcelain.DefaultGasPriceSampleTipSets, porcelain.DefaultGasPriceTargetDelay)
+		if err != nil {
+			return types.ZeroAttoFIL, errors.Wrap(err, "failed to estimate gas price")
+		}
+		return estimate.Price, nil
+	}
+	price, ok := types.NewAttoFILFromFILString(priceOption.(string))
+	if !ok {
+		return types.ZeroAttoFIL, errors.New("invalid gas price (specify FIL as a decimal number)")
+	}
+	return price, nil
+}
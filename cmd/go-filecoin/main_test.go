@@ -23,4 +23,12 @@ func TestRequiresDaemon(t *testing.T) {
 	reqSubcmdDaemon, err := cmds.NewRequest(context.Background(), []string{"leb128", "decode"}, nil, []string{"A=="}, nil, rootCmd)
 	assert.NoError(t, err)
 	assert.False(t, requiresDaemon(reqSubcmdDaemon))
+
+	reqLocalSubcmd, err := cmds.NewRequest(context.Background(), []string{"wallet", "sign-message"}, nil, []string{"{}"}, nil, rootCmd)
+	assert.NoError(t, err)
+	assert.False(t, requiresDaemon(reqLocalSubcmd))
+
+	reqSiblingSubcmd, err := cmds.NewRequest(context.Background(), []string{"wallet", "balance"}, nil, []string{"t0100"}, nil, rootCmd)
+	assert.NoError(t, err)
+	assert.True(t, requiresDaemon(reqSiblingSubcmd))
 }
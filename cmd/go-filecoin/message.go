@@ -2,6 +2,8 @@ package commands
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,10 +16,12 @@ import (
 	"github.com/ipfs/go-cid"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	files "github.com/ipfs/go-ipfs-files"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cst"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 )
@@ -27,10 +31,13 @@ var msgCmd = &cmds.Command{
 		Tagline: "Send and monitor messages",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"send":       msgSendCmd,
-		"sendsigned": signedMsgSendCmd,
-		"status":     msgStatusCmd,
-		"wait":       msgWaitCmd,
+		"create":             msgCreateCmd,
+		"estimate-gas-price": msgEstimateGasPriceCmd,
+		"send":               msgSendCmd,
+		"send-batch":         msgSendBatchCmd,
+		"sendsigned":         signedMsgSendCmd,
+		"status":             msgStatusCmd,
+		"wait":               msgWaitCmd,
 	},
 }
 
@@ -77,7 +84,7 @@ var msgSendCmd = &cmds.Command{
 			return err
 		}
 
-		gasPrice, gasLimit, preview, err := parseGasOptions(req)
+		gasPrice, gasLimit, preview, err := parseGasOptions(req, env)
 		if err != nil {
 			return err
 		}
@@ -137,12 +144,285 @@ var msgSendCmd = &cmds.Command{
 	},
 }
 
+// MessageSendBatchResult is the return type for message send-batch command
+type MessageSendBatchResult struct {
+	Cids     []cid.Cid
+	Receipts []*types.MessageReceipt
+}
+
+var msgSendBatchCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Send a batch of messages listed in a file",
+		ShortDescription: `Sends the messages listed in a JSON or CSV file from one address with a contiguous
+range of nonces. Either all the messages are sent or none is.
+
+A JSON file holds an array of objects with "to" and "value" (FIL) fields and optional
+"method" and "params" (hex encoded ABI parameters) fields. A CSV file holds one message
+per line with the same fields in that order, of which the last two may be omitted.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("file", true, false, "File listing the messages to send").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address to send the messages from"),
+		cmdkit.StringOption("format", "Format of the file, json or csv").WithDefault("json"),
+		priceOption,
+		limitOption,
+		cmdkit.BoolOption("wait", "Wait until all the messages are mined"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		iter := req.Files.Entries()
+		if !iter.Next() {
+			return fmt.Errorf("no file given: %s", iter.Err())
+		}
+
+		fi, ok := iter.Node().(files.File)
+		if !ok {
+			return fmt.Errorf("given file was not a files.File")
+		}
+		defer func() { _ = fi.Close() }()
+
+		format, _ := req.Options["format"].(string)
+		msgs, err := readMessageBatch(fi, format)
+		if err != nil {
+			return err
+		}
+
+		fromAddr, err := fromAddrOrDefault(req, env)
+		if err != nil {
+			return err
+		}
+
+		gasPrice, gasLimit, _, err := parseGasOptions(req, env)
+		if err != nil {
+			return err
+		}
+
+		wait, _ := req.Options["wait"].(bool)
+		cids, receipts, err := GetPorcelainAPI(env).MessageSendBatch(req.Context, fromAddr, msgs, gasPrice, gasLimit, wait)
+		if err != nil {
+			return err
+		}
+		return re.Emit(&MessageSendBatchResult{Cids: cids, Receipts: receipts})
+	},
+	Type: &MessageSendBatchResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *MessageSendBatchResult) error {
+			sw := NewSilentWriter(w)
+			for i, c := range res.Cids {
+				if res.Receipts != nil {
+					sw.Printf("%s\texit code %d\n", c, res.Receipts[i].ExitCode)
+				} else {
+					sw.Println(c.String())
+				}
+			}
+			return sw.Error()
+		}),
+	},
+}
+
+// messageBatchEntry is a message listed in a send-batch file.
+type messageBatchEntry struct {
+	To     string `json:"to"`
+	Value  string `json:"value"`
+	Method uint64 `json:"method"`
+	Params string `json:"params"`
+}
+
+// readMessageBatch reads the messages listed in a send-batch file of the given format.
+func readMessageBatch(r io.Reader, format string) ([]*message.BatchMessage, error) {
+	var entries []messageBatchEntry
+	switch format {
+	case "json":
+		if err := json.NewDecoder(r).Decode(&entries); err != nil {
+			return nil, errors.Wrap(err, "invalid JSON batch")
+		}
+	case "csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid CSV batch")
+		}
+		for i, record := range records {
+			if len(record) < 2 || len(record) > 4 {
+				return nil, errors.Errorf("line %d: expected 2 to 4 fields, got %d", i+1, len(record))
+			}
+			entry := messageBatchEntry{To: record[0], Value: record[1]}
+			if len(record) > 2 && record[2] != "" {
+				if entry.Method, err = strconv.ParseUint(record[2], 10, 64); err != nil {
+					return nil, errors.Wrapf(err, "line %d: invalid method", i+1)
+				}
+			}
+			if len(record) > 3 {
+				entry.Params = record[3]
+			}
+			entries = append(entries, entry)
+		}
+	default:
+		return nil, errors.Errorf("unknown batch format %s", format)
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.New("no messages in batch")
+	}
+	msgs := make([]*message.BatchMessage, len(entries))
+	for i, entry := range entries {
+		to, err := address.NewFromString(entry.To)
+		if err != nil {
+			return nil, errors.Wrapf(err, "message %d: invalid address", i+1)
+		}
+		value, ok := types.NewAttoFILFromFILString(entry.Value)
+		if !ok {
+			return nil, errors.Errorf("message %d: invalid value %s (specify FIL as a decimal number)", i+1, entry.Value)
+		}
+		params, err := hex.DecodeString(entry.Params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "message %d: invalid params", i+1)
+		}
+		msgs[i] = &message.BatchMessage{To: to, Value: value, Method: types.MethodID(entry.Method), Params: params}
+	}
+	return msgs, nil
+}
+
+var msgEstimateGasPriceCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Recommend a gas price from recent blocks and the message pool",
+		ShortDescription: `Recommends a gas price for a message to be included within --delay tipsets.
+The recommendation is a percentile of the gas prices of the messages included in
+the last --blocks tipsets, raised to outbid the pending messages if there are more
+than those tipsets included on average in --delay tipsets.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.UintOption("blocks", "Number of recent tipsets to sample").WithDefault(uint(porcelain.DefaultGasPriceSampleTipSets)),
+		cmdkit.UintOption("delay", "Number of tipsets within which the message should be included").WithDefault(uint(porcelain.DefaultGasPriceTargetDelay)),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		blocks, _ := req.Options["blocks"].(uint)
+		delay, _ := req.Options["delay"].(uint)
+		estimate, err := GetPorcelainAPI(env).MessageEstimateGasPrice(req.Context, blocks, delay)
+		if err != nil {
+			return err
+		}
+		return re.Emit(estimate)
+	},
+	Type: porcelain.GasPriceEstimate{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, estimate *porcelain.GasPriceEstimate) error {
+			sw := NewSilentWriter(w)
+			sw.Println(estimate.Price.String())
+			sw.Printf("sampled %d messages in %d tipsets, %d pending\n", estimate.MessagesSampled, estimate.TipSetsSampled, estimate.Pending)
+			return sw.Error()
+		}),
+	},
+}
+
+var msgCreateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create an unsigned message to be signed offline",
+		ShortDescription: `Prints as JSON an unsigned message to the target bearing the next nonce of the
+sender. The message can be signed on another machine with "wallet sign-message" and
+the result sent with "message sendsigned". The gas price is estimated from recent
+blocks if not given. The gas limit, if not given, is the gas used by a preview of the
+message with a margin of 20%; a message with params requires it.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("target", true, false, "Address of the actor to send the message to"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("value", "Value to send with message in FIL"),
+		cmdkit.StringOption("from", "Address to send message from"),
+		cmdkit.UintOption("method", "The method to invoke on the target actor"),
+		cmdkit.StringOption("params", "Hex encoded ABI parameters of the method"),
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		target, err := address.NewFromString(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		rawVal := req.Options["value"]
+		if rawVal == nil {
+			rawVal = "0"
+		}
+		val, ok := types.NewAttoFILFromFILString(rawVal.(string))
+		if !ok {
+			return errors.New("mal-formed value")
+		}
+
+		fromAddr, err := fromAddrOrDefault(req, env)
+		if err != nil {
+			return err
+		}
+
+		methodID := types.SendMethodID
+		if method, ok := req.Options["method"].(uint); ok {
+			methodID = types.MethodID(method)
+		}
+
+		rawParams, _ := req.Options["params"].(string)
+		params, err := hex.DecodeString(rawParams)
+		if err != nil {
+			return errors.Wrap(err, "invalid params")
+		}
+
+		gasPrice, err := parseGasPrice(req, env)
+		if err != nil {
+			return err
+		}
+		var gasLimit types.GasUnits
+		if limit, ok := req.Options["gas-limit"].(uint64); ok {
+			gasLimit = types.NewGasUnits(limit)
+		} else if len(params) > 0 {
+			// The preview takes the params as values rather than encoded.
+			return errors.New("gas-limit option is required for a message with params")
+		} else {
+			usedGas, err := GetPorcelainAPI(env).MessagePreview(req.Context, fromAddr, target, methodID)
+			if err != nil {
+				return errors.Wrap(err, "failed to estimate gas limit")
+			}
+			gasLimit = gasLimitWithMargin(usedGas)
+		}
+
+		msg, err := GetPorcelainAPI(env).MessageCreate(req.Context, fromAddr, target, val, methodID, params, gasPrice, gasLimit)
+		if err != nil {
+			return err
+		}
+		return re.Emit(msg)
+	},
+	Type: types.UnsignedMessage{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, msg *types.UnsignedMessage) error {
+			return json.NewEncoder(w).Encode(msg)
+		}),
+	},
+}
+
+// gasLimitMarginPercent is the margin added to the gas used by a preview of a message to set
+// its gas limit, since the state may change before the message is mined.
+const gasLimitMarginPercent = 20
+
+// gasLimitWithMargin returns a gas limit for a message previewed to use `used` gas, at most the
+// block gas limit.
+func gasLimitWithMargin(used types.GasUnits) types.GasUnits {
+	limit := used + used*gasLimitMarginPercent/100
+	if limit > types.BlockGasLimit {
+		return types.BlockGasLimit
+	}
+	return limit
+}
+
 var signedMsgSendCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Send a signed message",
+		ShortDescription: `Sends a message signed as JSON, as printed by "wallet sign-message", given as
+the argument or on stdin.`,
 	},
 	Arguments: []cmdkit.Argument{
-		cmdkit.StringArg("message", true, false, "Signed Json message"),
+		cmdkit.StringArg("message", true, false, "Signed Json message").EnableStdin(),
 	},
 	Options: []cmdkit.Option{},
 
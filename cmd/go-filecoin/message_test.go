@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet"
+)
+
+func TestReadMessageBatch(t *testing.T) {
+	tf.UnitTest(t)
+
+	addrGetter := vmaddr.NewForTestGetter()
+	alice, bob := addrGetter(), addrGetter()
+
+	t.Run("json", func(t *testing.T) {
+		in := `[{"to": "` + alice.String() + `", "value": "1.5"}, {"to": "` + bob.String() + `", "value": "2", "method": 3, "params": "0a0b"}]`
+		msgs, err := readMessageBatch(strings.NewReader(in), "json")
+		require.NoError(t, err)
+		require.Len(t, msgs, 2)
+
+		value, _ := types.NewAttoFILFromFILString("1.5")
+		assert.Equal(t, alice, msgs[0].To)
+		assert.Equal(t, value, msgs[0].Value)
+		assert.Equal(t, types.SendMethodID, msgs[0].Method)
+		assert.Empty(t, msgs[0].Params)
+
+		assert.Equal(t, bob, msgs[1].To)
+		assert.True(t, types.NewAttoFILFromFIL(2).Equal(msgs[1].Value))
+		assert.Equal(t, types.MethodID(3), msgs[1].Method)
+		assert.Equal(t, []byte{0x0a, 0x0b}, msgs[1].Params)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		in := alice.String() + ",1.5\n" + bob.String() + ", 2, 3, 0a0b\n"
+		msgs, err := readMessageBatch(strings.NewReader(in), "csv")
+		require.NoError(t, err)
+		require.Len(t, msgs, 2)
+		assert.Equal(t, alice, msgs[0].To)
+		assert.Equal(t, types.SendMethodID, msgs[0].Method)
+		assert.Equal(t, bob, msgs[1].To)
+		assert.Equal(t, types.MethodID(3), msgs[1].Method)
+		assert.Equal(t, []byte{0x0a, 0x0b}, msgs[1].Params)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, tc := range []struct {
+			format, in string
+		}{
+			{"json", `[]`},
+			{"json", `[{"to": "not an address", "value": "1"}]`},
+			{"json", `[{"to": "` + alice.String() + `", "value": "x"}]`},
+			{"csv", alice.String() + "\n"},
+			{"csv", alice.String() + ",1,x\n"},
+			{"csv", alice.String() + ",1,0,zz\n"},
+			{"xml", `<batch/>`},
+		} {
+			_, err := readMessageBatch(strings.NewReader(tc.in), tc.format)
+			assert.Error(t, err, tc.in)
+		}
+	})
+}
+
+func TestOfflineSigning(t *testing.T) {
+	tf.UnitTest(t)
+
+	backend, err := wallet.NewDSBackend(repo.NewInMemoryRepo().WalletDatastore())
+	require.NoError(t, err)
+	from, err := backend.NewAddress(address.SECP256K1)
+	require.NoError(t, err)
+	to := vmaddr.NewForTestGetter()()
+
+	// The unsigned message as printed by message create.
+	created := types.NewMeteredMessage(from, to, 42, types.NewAttoFILFromFIL(3), types.MethodID(2), []byte{1, 2}, types.NewGasPrice(5), types.NewGasUnits(100))
+	out, err := json.Marshal(created)
+	require.NoError(t, err)
+
+	var unsigned types.UnsignedMessage
+	require.NoError(t, json.Unmarshal(out, &unsigned))
+	signed, err := signMessage(backend, &unsigned)
+	require.NoError(t, err)
+	out, err = json.Marshal(signed)
+	require.NoError(t, err)
+
+	// The signed message as read by message sendsigned.
+	var sent types.SignedMessage
+	require.NoError(t, json.Unmarshal(out, &sent))
+	assert.True(t, sent.VerifySignature())
+	assert.True(t, created.Equals(&sent.Message))
+
+	unknown := *created
+	unknown.From = vmaddr.NewForTestGetter()()
+	_, err = signMessage(backend, &unknown)
+	assert.Error(t, err)
+}
+
+func TestGasLimitWithMargin(t *testing.T) {
+	tf.UnitTest(t)
+
+	assert.Equal(t, types.NewGasUnits(0), gasLimitWithMargin(types.NewGasUnits(0)))
+	assert.Equal(t, types.NewGasUnits(1200), gasLimitWithMargin(types.NewGasUnits(1000)))
+	assert.Equal(t, types.BlockGasLimit, gasLimitWithMargin(types.BlockGasLimit))
+}
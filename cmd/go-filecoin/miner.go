@@ -111,7 +111,7 @@ additional sectors.`,
 			return ErrInvalidCollateral
 		}
 
-		gasPrice, gasLimit, preview, err := parseGasOptions(req)
+		gasPrice, gasLimit, preview, err := parseGasOptions(req, env)
 		if err != nil {
 			return err
 		}
@@ -213,7 +213,7 @@ This command waits for the ask to be mined.`,
 			return fmt.Errorf("expiry must be a valid integer")
 		}
 
-		gasPrice, gasLimit, preview, err := parseGasOptions(req)
+		gasPrice, gasLimit, preview, err := parseGasOptions(req, env)
 		if err != nil {
 			return err
 		}
@@ -313,7 +313,7 @@ var minerUpdatePeerIDCmd = &cmds.Command{
 			return err
 		}
 
-		gasPrice, gasLimit, preview, err := parseGasOptions(req)
+		gasPrice, gasLimit, preview, err := parseGasOptions(req, env)
 		if err != nil {
 			return err
 		}
@@ -512,7 +512,7 @@ var minerSetWorkerAddressCmd = &cmds.Command{
 			return err
 		}
 
-		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		gasPrice, gasLimit, _, err := parseGasOptions(req, env)
 		if err != nil {
 			return err
 		}
@@ -6,11 +6,13 @@ import (
 	"io"
 	"strconv"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-cmdkit"
 	"github.com/ipfs/go-ipfs-cmds"
 	"github.com/pkg/errors"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 )
 
@@ -19,9 +21,10 @@ var mpoolCmd = &cmds.Command{
 		Tagline: "Manage the message pool",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"ls":   mpoolLsCmd,
-		"show": mpoolShowCmd,
-		"rm":   mpoolRemoveCmd,
+		"ls":    mpoolLsCmd,
+		"show":  mpoolShowCmd,
+		"rm":    mpoolRemoveCmd,
+		"watch": mpoolWatchCmd,
 	},
 }
 
@@ -124,3 +127,57 @@ var mpoolRemoveCmd = &cmds.Command{
 		return nil
 	},
 }
+
+var mpoolWatchCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Follow changes of the message pool",
+		ShortDescription: `Streams the messages added to and removed from the message pool as it changes.
+Each event has a type: add, remove (deleted or timed out), evict (replaced by a
+message with the same nonce or pushed out of the full pool) or included (mined
+in a block of the head chain). The stream ends with an error if the reader falls
+behind.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Only follow messages from this address"),
+		cmdkit.StringOption("to", "Only follow messages to this address"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		from, err := optionalAddr(req.Options["from"])
+		if err != nil {
+			return err
+		}
+		var to address.Address
+		if o, ok := req.Options["to"]; ok {
+			to, err = address.NewFromString(o.(string))
+			if err != nil {
+				return errors.Wrap(err, "invalid to address")
+			}
+		}
+
+		sub := GetPorcelainAPI(env).MessagePoolSubscribe(req.Context)
+		for event := range sub.Events() {
+			msg := event.Message.Message
+			if (from != address.Undef && msg.From != from) || (to != address.Undef && msg.To != to) {
+				continue
+			}
+			if err := re.Emit(event); err != nil {
+				return err
+			}
+		}
+		if req.Context.Err() != nil {
+			return nil
+		}
+		if err := sub.Err(); err != nil {
+			return errors.Wrap(err, "message pool subscription ended")
+		}
+		return nil
+	},
+	Type: message.PoolEvent{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, event *message.PoolEvent) error {
+			msg := event.Message.Message
+			_, err := fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", event.Type, event.Cid, msg.From, msg.CallSeqNum, msg.To, msg.GasPrice)
+			return err
+		}),
+	},
+}
@@ -2,12 +2,16 @@ package commands
 
 import (
 	"io"
+	"time"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 )
 
 var outboxCmd = &cmds.Command{
@@ -15,8 +19,9 @@ var outboxCmd = &cmds.Command{
 		Tagline: "View and manipulate the outbound message queue",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"clear": outboxClearCmd,
-		"ls":    outboxLsCmd,
+		"clear":   outboxClearCmd,
+		"ls":      outboxLsCmd,
+		"replace": outboxReplaceCmd,
 	},
 }
 
@@ -55,7 +60,11 @@ var outboxLsCmd = &cmds.Command{
 			sw.Println("From:", queue.Address.String())
 			for _, qm := range queue.Messages {
 				msg := qm.Msg
-				sw.Printf("%s, height: %d\n", msg.String(), qm.Stamp)
+				lastBroadcast := "never"
+				if qm.Attempts > 0 {
+					lastBroadcast = time.Unix(int64(qm.LastBroadcast), 0).Format(time.RFC3339)
+				}
+				sw.Printf("%s, height: %d, broadcasts: %d, last broadcast: %s\n", msg.String(), qm.Stamp, qm.Attempts, lastBroadcast)
 			}
 			return sw.Error()
 		}),
@@ -83,6 +92,48 @@ var outboxClearCmd = &cmds.Command{
 	Encoders: cmds.EncoderMap{},
 }
 
+var outboxReplaceCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Replace a queued message with one paying a higher gas price",
+		ShortDescription: `Re-signs the queued message with the given gas price and publishes it in its
+place. The gas price must beat that of the queued message by the message pool's
+minimum bump (mpool.replaceByFeePercent).`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "CID of the queued message to replace"),
+	},
+	Options: []cmdkit.Option{
+		priceOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		c, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return errors.Wrap(err, "invalid message cid")
+		}
+
+		rawPrice, ok := req.Options["gas-price"].(string)
+		if !ok {
+			return errors.New("gas-price option is required")
+		}
+		gasPrice, ok := types.NewAttoFILFromFILString(rawPrice)
+		if !ok {
+			return errors.New("invalid gas price (specify FIL as a decimal number)")
+		}
+
+		replacement, err := GetPorcelainAPI(env).OutboxReplace(req.Context, c, gasPrice)
+		if err != nil {
+			return err
+		}
+		return re.Emit(replacement)
+	},
+	Type: cid.Cid{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, c cid.Cid) error {
+			return PrintString(w, c)
+		}),
+	},
+}
+
 // Reads an address from an argument, or lists addresses of all outbox queues if no arg is given.
 func queueAddressesFromArg(req *cmds.Request, env cmds.Environment, argIndex int) ([]address.Address, error) {
 	var addresses []address.Address
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/slashing"
+)
+
+var slashingCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect the consensus faults detected by the node",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls": slashingLsCmd,
+	},
+}
+
+var slashingLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the consensus faults detected and reported by the node",
+		ShortDescription: `
+Lists the consensus faults detected while syncing the chain, oldest first. A fault
+is reported to the power actor in a message sent from slashing.reporterAddress, and
+shows the CID of that message once it is sent. A report is submitted once its message
+succeeds, and sent again if the message fails or is not included in the chain in time.
+A report fails at once if the power actor rejects it, or after its last message fails
+or is not included in time.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return re.Emit(GetPorcelainAPI(env).SlashingReports())
+	},
+	Type: []*slashing.FaultReport{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, reports *[]*slashing.FaultReport) error {
+			sw := NewSilentWriter(w)
+			for _, report := range *reports {
+				status := "detected"
+				if report.Submitted() {
+					status = "submitted in " + report.Message.String()
+				} else if report.Failed() && report.Included {
+					status = fmt.Sprintf("failed in %s with exit code %d", report.Message.String(), report.ExitCode)
+				} else if report.Failed() {
+					status = "failed, " + report.Message.String() + " not included"
+				} else if report.Message.Defined() {
+					status = "sent in " + report.Message.String()
+				}
+				sw.Printf("%s fault by miner: %s, blocks: %s (height %d) and %s (height %d), detected: %s, %s\n",
+					report.Type, report.Miner, report.Block1.Cid(), report.Block1.Height, report.Block2.Cid(), report.Block2.Height,
+					time.Unix(int64(report.Detected), 0).Format(time.RFC3339), status)
+			}
+			return sw.Error()
+		}),
+	},
+}
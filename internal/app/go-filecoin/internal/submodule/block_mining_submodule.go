@@ -24,6 +24,9 @@ type BlockMiningSubmodule struct {
 		IsMining bool
 	}
 	MiningDoneWg *sync.WaitGroup
+
+	// InstantSealer seals the blocks of an instant-seal network when the node holds its signer.
+	InstantSealer *mining.InstantSealer
 }
 
 type newBlockFunc func(context.Context, *block.Block)
@@ -39,5 +42,6 @@ func NewBlockMiningSubmodule(ctx context.Context) (BlockMiningSubmodule, error)
 		// mining:       nil,
 		// miningDoneWg: nil,
 		// MessageSub:   nil,
+		// InstantSealer: nil,
 	}, nil
 }
@@ -17,13 +17,17 @@ import (
 type ChainSubmodule struct {
 	ChainReader  *chain.Store
 	MessageStore *chain.MessageStore
+	MessageIndex *chain.MessageIndex
 	State        *cst.ChainStateReadWriter
+	StatePruner  *chain.StatePruner
 	// HeavyTipSetCh is a subscription to the heaviest tipset topic on the chain.
 	// https://github.com/filecoin-project/go-filecoin/issues/2309
 	HeaviestTipSetCh chan interface{}
 
 	ActorState *consensus.ActorStateStore
 	Processor  *consensus.DefaultProcessor
+	// SignatureCache holds the messages whose signatures have been verified.
+	SignatureCache *consensus.SignatureCache
 
 	StatusReporter *chain.StatusReporter
 }
@@ -61,14 +65,19 @@ func NewChainSubmodule(ctx context.Context, config chainConfig, repo chainRepo,
 	actorState := consensus.NewActorStateStore(chainStore, blockstore.CborStore, blockstore.Blockstore, processor)
 	messageStore := chain.NewMessageStore(blockstore.Blockstore)
 	chainState := cst.NewChainStateReadWriter(chainStore, messageStore, blockstore.Blockstore, builtin.DefaultActors)
+	statePruner := chain.NewStatePruner(repo.ChainDatastore(), blockstore.Blockstore, chainStore)
+	messageIndex := chain.NewMessageIndex(repo.ChainDatastore(), chainStore, messageStore, chainState)
 
 	return ChainSubmodule{
 		ChainReader:  chainStore,
 		MessageStore: messageStore,
+		MessageIndex: messageIndex,
 		// HeaviestTipSetCh nil
 		ActorState:     actorState,
 		State:          chainState,
+		StatePruner:    statePruner,
 		Processor:      processor,
+		SignatureCache: consensus.NewSignatureCache(consensus.DefaultSignatureCacheSize),
 		StatusReporter: chainStatusReporter,
 	}, nil
 }
@@ -2,7 +2,12 @@ package submodule
 
 import (
 	"context"
+	"time"
 
+	"github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
@@ -18,6 +23,8 @@ type MessagingSubmodule struct {
 
 	// Messages sent and not yet mined.
 	Outbox *message.Outbox
+	// Broadcasts outbox messages again while they are not mined.
+	Rebroadcaster *message.Rebroadcaster
 
 	// Network Fields
 	MessageTopic *pubsub.Topic
@@ -32,29 +39,57 @@ type messagingConfig interface {
 
 type messagingRepo interface {
 	Config() *config.Config
+	Datastore() datastore.Batching
 }
 
 // NewMessagingSubmodule creates a new discovery submodule.
 func NewMessagingSubmodule(ctx context.Context, config messagingConfig, repo messagingRepo, network *NetworkSubmodule, chain *ChainSubmodule, wallet *WalletSubmodule) (MessagingSubmodule, error) {
-	msgPool := message.NewPool(repo.Config().Mpool, consensus.NewIngestionValidator(chain.State, repo.Config().Mpool))
+	msgPool := message.NewPersistentPool(repo.Config().Mpool, consensus.NewIngestionValidator(chain.State, repo.Config().Mpool, chain.SignatureCache), wallet.Wallet, repo.Datastore())
 	inbox := message.NewInbox(msgPool, message.InboxMaxAgeTipsets, chain.ChainReader, chain.MessageStore)
 
+	// register message validation on the messaging topic
+	mpoolCfg := repo.Config().Mpool
+	limiter := net.NewPeerLimiter(mpoolCfg.PeerMessageRate, mpoolCfg.PeerMessageBurst, mpoolCfg.MaxPeerInvalidMessages, network.pubsub, clock.NewSystemClock())
+	mtv := net.NewMessageTopicValidator(network.Host.ID(), consensus.NewIngestionValidator(chain.State, mpoolCfg, chain.SignatureCache), msgPool, limiter)
+	if err := network.pubsub.RegisterTopicValidator(mtv.Topic(network.NetworkName), mtv.Validator(), mtv.Opts()...); err != nil {
+		return MessagingSubmodule{}, errors.Wrap(err, "failed to register message validator")
+	}
+
 	// setup messaging topic.
 	topic, err := network.pubsub.Join(net.MessageTopic(network.NetworkName))
 	if err != nil {
 		return MessagingSubmodule{}, err
 	}
 
-	msgQueue := message.NewQueue()
+	msgQueue := message.NewPersistentQueue(repo.Datastore())
 	outboxPolicy := message.NewMessageQueuePolicy(chain.MessageStore, message.OutboxMaxAgeRounds)
 	msgPublisher := message.NewDefaultPublisher(pubsub.NewTopic(topic), msgPool)
-	outbox := message.NewOutbox(wallet.Wallet, consensus.NewOutboundMessageValidator(), msgQueue, msgPublisher, outboxPolicy, chain.ChainReader, chain.State, config.Journal().Topic("outbox"))
+	outboxJournal := config.Journal().Topic("outbox")
+	outbox := message.NewOutbox(wallet.Wallet, consensus.NewOutboundMessageValidator(), msgQueue, msgPublisher, outboxPolicy, chain.ChainReader, chain.State, outboxJournal, clock.NewSystemClock())
+
+	rebroadcastInterval, err := time.ParseDuration(mpoolCfg.RebroadcastInterval)
+	if err != nil {
+		return MessagingSubmodule{}, errors.Wrapf(err, "invalid rebroadcast interval %s", mpoolCfg.RebroadcastInterval)
+	}
+	rebroadcaster := message.NewRebroadcaster(msgQueue, msgPublisher, chain.ChainReader, rebroadcastInterval, mpoolCfg.MaxRebroadcasts, outboxJournal, clock.NewSystemClock())
 
 	return MessagingSubmodule{
-		Inbox:        inbox,
-		Outbox:       outbox,
-		MessageTopic: pubsub.NewTopic(topic),
+		Inbox:         inbox,
+		Outbox:        outbox,
+		Rebroadcaster: rebroadcaster,
+		MessageTopic:  pubsub.NewTopic(topic),
 		// MessageSub: nil,
 		MsgPool: msgPool,
 	}, nil
 }
+
+// Start restores the pending messages and outbound queue persisted before a restart, once
+// the chain is loaded.
+func (m *MessagingSubmodule) Start(ctx context.Context) error {
+	loaded, err := m.MsgPool.Load(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to load message pool")
+	}
+	log.Infof("restored %d pending messages", loaded)
+	return m.Outbox.Load(ctx)
+}
@@ -0,0 +1,78 @@
+package submodule
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cst"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/slashing"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// SlashingSubmodule enhances the `Node` with the reporting of consensus faults.
+type SlashingSubmodule struct {
+	// Reports the consensus faults detected while syncing the chain.
+	Reporter *slashing.Reporter
+}
+
+type slashingRepo interface {
+	Config() *config.Config
+	Datastore() datastore.Batching
+}
+
+// NewSlashingSubmodule creates a new slashing submodule.
+func NewSlashingSubmodule(ctx context.Context, repo slashingRepo, chn *ChainSubmodule, messaging *MessagingSubmodule) (SlashingSubmodule, error) {
+	pricer := &reportGasPricer{chain: chn.State, pool: messaging.MsgPool}
+	return SlashingSubmodule{
+		Reporter: slashing.NewReporter(repo.Datastore(), messaging.Outbox, chn.MessageIndex, pricer, repo.Config().Slashing.ReporterAddress, clock.NewSystemClock()),
+	}, nil
+}
+
+// Start restores the fault reports persisted before a restart and reports the faults
+// detected by the syncer until the context is done.
+func (s *SlashingSubmodule) Start(ctx context.Context, syncer *SyncerSubmodule) error {
+	if err := s.Reporter.Load(ctx); err != nil {
+		return errors.Wrap(err, "failed to load fault reports")
+	}
+	go s.Reporter.Run(ctx, syncer.faultCh)
+	return nil
+}
+
+// reportGasPricer estimates the gas price of fault reports from the messages of the recent
+// chain and of the message pool.
+type reportGasPricer struct {
+	chain *cst.ChainStateReadWriter
+	pool  *message.Pool
+}
+
+func (p *reportGasPricer) EstimateGasPrice(ctx context.Context) (types.AttoFIL, error) {
+	estimate, err := porcelain.MessageEstimateGasPrice(ctx, p, porcelain.DefaultGasPriceSampleTipSets, porcelain.DefaultGasPriceTargetDelay)
+	if err != nil {
+		return types.ZeroAttoFIL, err
+	}
+	return estimate.Price, nil
+}
+
+func (p *reportGasPricer) ChainHeadKey() block.TipSetKey {
+	return p.chain.Head()
+}
+
+func (p *reportGasPricer) ChainTipSet(key block.TipSetKey) (block.TipSet, error) {
+	return p.chain.GetTipSet(key)
+}
+
+func (p *reportGasPricer) ChainGetMessages(ctx context.Context, metaCid cid.Cid) ([]*types.SignedMessage, error) {
+	return p.chain.GetMessages(ctx, metaCid)
+}
+
+func (p *reportGasPricer) MessagePoolPending() []*types.SignedMessage {
+	return p.pool.Pending()
+}
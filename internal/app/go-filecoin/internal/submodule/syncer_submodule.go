@@ -38,6 +38,7 @@ type syncerConfig interface {
 	GenesisCid() cid.Cid
 	BlockTime() time.Duration
 	ChainClock() clock.ChainEpochClock
+	InstantSeal() *consensus.InstantSealParams
 }
 
 type nodeChainSelector interface {
@@ -63,8 +64,14 @@ func NewSyncerSubmodule(ctx context.Context, config syncerConfig, repo chainRepo
 		return SyncerSubmodule{}, err
 	}
 
-	// set up consensus
-	nodeConsensus := consensus.NewExpected(blockstore.CborStore, blockstore.Blockstore, chn.Processor, chn.ActorState, config.BlockTime(), consensus.ElectionMachine{}, consensus.TicketMachine{}, postVerifier)
+	// set up consensus, instant-seal if the genesis block selects it
+	sigVerifier := consensus.NewSignatureVerifier(chn.SignatureCache, consensus.DefaultSignatureWorkers)
+	var nodeConsensus consensus.Protocol
+	if params := config.InstantSeal(); params != nil {
+		nodeConsensus = consensus.NewInstantSeal(blockstore.CborStore, blockstore.Blockstore, chn.Processor, params.Signer, consensus.TicketMachine{}, sigVerifier)
+	} else {
+		nodeConsensus = consensus.NewExpected(blockstore.CborStore, blockstore.Blockstore, chn.Processor, chn.ActorState, config.BlockTime(), consensus.ElectionMachine{}, consensus.TicketMachine{}, postVerifier, sigVerifier)
+	}
 	nodeChainSelector := consensus.NewChainSelector(blockstore.CborStore, chn.ActorState, config.GenesisCid())
 
 	// setup fecher
@@ -92,17 +99,7 @@ type syncerNode interface {
 }
 
 // Start starts the syncer submodule for a node.
+// Detected consensus faults are reported by the slashing submodule.
 func (s *SyncerSubmodule) Start(ctx context.Context, _node syncerNode) error {
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-s.faultCh:
-				// TODO #3690 connect this up to a slasher that sends messages
-				// to outbound queue to carry out penalization
-			}
-		}
-	}()
 	return s.ChainSyncManager.Start(ctx)
 }
@@ -18,6 +18,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/strgdls"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/proofs/verification"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
@@ -35,6 +36,7 @@ type Builder struct {
 	isRelay     bool
 	chainClock  clock.ChainEpochClock
 	genCid      cid.Cid
+	instantSeal *consensus.InstantSealParams
 }
 
 // BuilderOpt is an option for building a filecoin node.
@@ -175,12 +177,21 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 		return nil, errors.Wrap(err, "failed to build node.Chain")
 	}
 
+	// get the genesis block from the chainsubmodule
+	geneBlk, err := nd.chain.ChainReader.GetGenesisBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// the genesis block selects the consensus protocol of the network
+	b.instantSeal, err = consensus.LoadInstantSealParams(ctx, nd.Blockstore.CborStore, geneBlk)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read consensus protocol from genesis")
+	}
+	if b.instantSeal != nil {
+		b.blockTime = consensus.InstantSealBlockTime
+	}
+
 	if b.chainClock == nil {
-		// get the genesis block time from the chainsubmodule
-		geneBlk, err := nd.chain.ChainReader.GetGenesisBlock(ctx)
-		if err != nil {
-			return nil, err
-		}
 		b.chainClock = clock.NewChainClock(geneBlk.Timestamp, b.blockTime)
 	}
 	nd.ChainClock = b.chainClock
@@ -200,6 +211,11 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 		return nil, errors.Wrap(err, "failed to build node.Messaging")
 	}
 
+	nd.Slashing, err = submodule.NewSlashingSubmodule(ctx, b.repo, &nd.chain, &nd.Messaging)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build node.Slashing")
+	}
+
 	nd.StorageNetworking, err = submodule.NewStorgeNetworkingSubmodule(ctx, &nd.network)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build node.StorageNetworking")
@@ -222,13 +238,19 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 		MsgPool:      nd.Messaging.MsgPool,
 		MsgPreviewer: msg.NewPreviewer(nd.chain.ChainReader, nd.Blockstore.CborStore, nd.Blockstore.Blockstore, nd.chain.Processor),
 		ActState:     nd.chain.ActorState,
-		MsgWaiter:    msg.NewWaiter(nd.chain.ChainReader, nd.chain.MessageStore, nd.Blockstore.Blockstore, nd.Blockstore.CborStore),
+		MsgWaiter:    msg.NewWaiter(nd.chain.ChainReader, nd.chain.MessageStore, nd.chain.MessageIndex, nd.Blockstore.Blockstore, nd.Blockstore.CborStore),
 		Network:      nd.network.Network,
 		Outbox:       nd.Messaging.Outbox,
 		PieceManager: nd.PieceManager,
+		Slasher:      nd.Slashing.Reporter,
+		StatePruner:  nd.chain.StatePruner,
 		Wallet:       nd.Wallet.Wallet,
 	}))
 
+	if b.instantSeal != nil && nd.Wallet.Wallet.HasAddress(b.instantSeal.Signer) {
+		nd.BlockMining.InstantSealer = nd.createInstantSealer(b.instantSeal)
+	}
+
 	return nd, nil
 }
 
@@ -261,6 +283,10 @@ func (b builder) ChainClock() clock.ChainEpochClock {
 	return b.chainClock
 }
 
+func (b builder) InstantSeal() *consensus.InstantSealParams {
+	return b.instantSeal
+}
+
 func (b builder) Journal() journal.Journal {
 	return b.journal
 }
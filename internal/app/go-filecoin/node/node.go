@@ -6,6 +6,7 @@ import (
 	"os"
 	"reflect"
 	"runtime"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-sectorbuilder"
@@ -85,6 +86,7 @@ type Node struct {
 
 	Wallet            submodule.WalletSubmodule
 	Messaging         submodule.MessagingSubmodule
+	Slashing          submodule.SlashingSubmodule
 	StorageNetworking submodule.StorageNetworkingSubmodule
 	ProofVerification submodule.ProofVerificationSubmodule
 
@@ -138,7 +140,22 @@ func (node *Node) Start(ctx context.Context) error {
 	if err != nil {
 		return errors.Wrap(err, "failed to get chain head")
 	}
+	if err := node.Messaging.Start(syncCtx); err != nil {
+		return errors.Wrap(err, "failed to start messaging")
+	}
+	if err := node.Slashing.Start(syncCtx, &node.syncer); err != nil {
+		return errors.Wrap(err, "failed to start slashing")
+	}
 	go node.handleNewChainHeads(syncCtx, head)
+	go node.chain.MessageIndex.Run(syncCtx)
+
+	if gcConfig := node.Repo.Config().StateGC; gcConfig.AutoPrune {
+		period, err := time.ParseDuration(gcConfig.Period)
+		if err != nil {
+			return errors.Wrapf(err, "invalid state gc period %s", gcConfig.Period)
+		}
+		go node.pruneStatePeriodically(syncCtx, period, gcConfig.KeepEpochs)
+	}
 
 	if !node.OfflineMode {
 
@@ -173,6 +190,14 @@ func (node *Node) Start(ctx context.Context) error {
 
 		// Wire up syncing and possible mining
 		go node.doMiningPause(syncCtx)
+
+		// Broadcast sent messages again until they are mined.
+		go node.Messaging.Rebroadcaster.Run(syncCtx)
+
+		// Seal blocks if this node is the signer of an instant-seal network.
+		if node.BlockMining.InstantSealer != nil {
+			go node.BlockMining.InstantSealer.Run(syncCtx)
+		}
 	}
 
 	return nil
@@ -280,6 +305,23 @@ func (node *Node) handleNewChainHeads(ctx context.Context, prevHead block.TipSet
 	}
 }
 
+// pruneStatePeriodically deletes old chain state from the blockstore every
+// period until the context is cancelled.
+func (node *Node) pruneStatePeriodically(ctx context.Context, period time.Duration, keepEpochs uint64) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := node.PorcelainAPI.ChainPruneState(ctx, keepEpochs); err != nil {
+				log.Errorf("failed to prune chain state: %s", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (node *Node) cancelSubscriptions() {
 	if node.syncer.CancelChainSync != nil {
 		node.syncer.CancelChainSync()
@@ -303,6 +345,7 @@ func (node *Node) Stop(ctx context.Context) {
 
 	node.cancelSubscriptions()
 	node.chain.ChainReader.Stop()
+	node.Messaging.MsgPool.Close()
 
 	if node.StorageMining != nil {
 		if err := node.StorageMining.Stop(ctx); err != nil {
@@ -422,7 +465,7 @@ func (node *Node) setupStorageMining(ctx context.Context) error {
 		return err
 	}
 
-	waiter := msg.NewWaiter(node.chain.ChainReader, node.chain.MessageStore, node.Blockstore.Blockstore, node.Blockstore.CborStore)
+	waiter := msg.NewWaiter(node.chain.ChainReader, node.chain.MessageStore, node.chain.MessageIndex, node.Blockstore.Blockstore, node.Blockstore.CborStore)
 
 	// TODO: rework these modules so they can be at least partially constructed during the building phase #3738
 	node.StorageMining, err = submodule.NewStorageMiningSubmodule(minerAddr, workerAddr, node.Repo.Datastore(), sectorBuilder, &node.chain, &node.Messaging, waiter, &node.Wallet)
@@ -635,6 +678,26 @@ func (node *Node) CreateMiningWorker(ctx context.Context) (*mining.DefaultWorker
 	}), nil
 }
 
+// createInstantSealer creates the sealer of the blocks of an instant-seal network.
+func (node *Node) createInstantSealer(params *consensus.InstantSealParams) *mining.InstantSealer {
+	return mining.NewInstantSealer(mining.InstantSealerParameters{
+		Signer:   params.Signer,
+		Wallet:   node.Wallet.Wallet,
+		Interval: params.Interval,
+
+		GetHead:        node.PorcelainAPI.ChainHead,
+		GetStateTree:   node.chain.ChainReader.GetTipSetState,
+		GetWeight:      node.getWeight,
+		TipSetMetadata: node.chain.ChainReader,
+		TicketGen:      consensus.TicketMachine{},
+
+		MessageSource: node.Messaging.Inbox.Pool(),
+		MessageStore:  node.chain.MessageStore,
+		Clock:         node.ChainClock,
+		Publish:       node.addMinedBlockSynchronous,
+	})
+}
+
 // getWeight is the default GetWeight function for the mining worker.
 func (node *Node) getWeight(ctx context.Context, ts block.TipSet) (fbig.Int, error) {
 	parent, err := ts.Parents()
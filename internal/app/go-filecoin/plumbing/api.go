@@ -21,6 +21,7 @@ import (
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
 	ma "github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cfg"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cst"
@@ -32,6 +33,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/net"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage/storagedeal"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/slashing"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet"
@@ -57,6 +59,8 @@ type API struct {
 	network      *net.Network
 	outbox       *message.Outbox
 	pieceManager func() piecemanager.PieceManager
+	slasher      *slashing.Reporter
+	statePruner  *chain.StatePruner
 	storagedeals *strgdls.Store
 	wallet       *wallet.Wallet
 }
@@ -76,6 +80,8 @@ type APIDeps struct {
 	Network      *net.Network
 	Outbox       *message.Outbox
 	PieceManager func() piecemanager.PieceManager
+	Slasher      *slashing.Reporter
+	StatePruner  *chain.StatePruner
 	Wallet       *wallet.Wallet
 }
 
@@ -95,6 +101,8 @@ func New(deps *APIDeps) *API {
 		network:      deps.Network,
 		outbox:       deps.Outbox,
 		pieceManager: deps.PieceManager,
+		slasher:      deps.Slasher,
+		statePruner:  deps.StatePruner,
 		storagedeals: deps.Deals,
 		wallet:       deps.Wallet,
 	}
@@ -159,8 +167,26 @@ func (api *API) ChainHeadKey() block.TipSetKey {
 }
 
 // ChainSetHead sets `key` as the new head of this chain iff it exists in the nodes chain store.
-func (api *API) ChainSetHead(ctx context.Context, key block.TipSetKey) error {
-	return api.chain.SetHead(ctx, key)
+// Unless `force` is true the new head's chain must include the checkpointed tipset.
+func (api *API) ChainSetHead(ctx context.Context, key block.TipSetKey, force bool) error {
+	return api.chain.SetHead(ctx, key, force)
+}
+
+// ChainCheckpoint returns the key of the checkpointed tipset, which is empty
+// if no checkpoint is set.
+func (api *API) ChainCheckpoint() (block.TipSetKey, error) {
+	return api.chain.Checkpoint()
+}
+
+// ChainSetCheckpoint checkpoints the tipset identified by `key`.  The syncer
+// rejects chains that do not include the checkpointed tipset.
+func (api *API) ChainSetCheckpoint(ctx context.Context, key block.TipSetKey) error {
+	return api.chain.SetCheckpoint(ctx, key)
+}
+
+// ChainClearCheckpoint removes the checkpoint.
+func (api *API) ChainClearCheckpoint() error {
+	return api.chain.ClearCheckpoint()
 }
 
 // ChainTipSet returns the tipset at the given key
@@ -168,6 +194,24 @@ func (api *API) ChainTipSet(key block.TipSetKey) (block.TipSet, error) {
 	return api.chain.GetTipSet(key)
 }
 
+// ChainGetTipSetAtHeight returns the tipset at height `h` on the current chain.
+// Null rounds resolve to the nearest lower tipset.
+func (api *API) ChainGetTipSetAtHeight(ctx context.Context, h uint64) (block.TipSet, error) {
+	return api.chain.GetTipSetAtHeight(ctx, h)
+}
+
+// ChainHeadChanges subscribes to the tipsets reverted and applied by each
+// change of the chain head until `ctx` is done.
+func (api *API) ChainHeadChanges(ctx context.Context) *chain.HeadChangeSubscription {
+	return api.chain.HeadChanges(ctx)
+}
+
+// ChainStateDiff returns the actors that were added, removed or changed going
+// from state root `before` to `after`, with the changes of their fields.
+func (api *API) ChainStateDiff(ctx context.Context, before, after cid.Cid) ([]*cst.ActorStateDiff, error) {
+	return api.chain.StateDiff(ctx, before, after)
+}
+
 // ChainLs returns an iterator of tipsets from head to genesis
 func (api *API) ChainLs(ctx context.Context) (*chain.TipsetIterator, error) {
 	return api.chain.Ls(ctx)
@@ -190,9 +234,10 @@ func (api *API) ChainSyncHandleNewTipSet(ci *block.ChainInfo) error {
 	return api.syncer.HandleNewTipSet(ci)
 }
 
-// ChainExport exports the chain from `head` up to and including the genesis block to `out`
-func (api *API) ChainExport(ctx context.Context, head block.TipSetKey, out io.Writer) error {
-	return api.chain.ChainExport(ctx, head, out)
+// ChainExport exports the chain from `head` up to and including the genesis block to `out`,
+// restricted to the part of the chain selected by `opts`.
+func (api *API) ChainExport(ctx context.Context, head block.TipSetKey, opts chain.ExportOptions, out io.Writer) error {
+	return api.chain.ChainExport(ctx, head, opts, out)
 }
 
 // ChainImport imports a chain from `in`.
@@ -200,6 +245,65 @@ func (api *API) ChainImport(ctx context.Context, in io.Reader) (block.TipSetKey,
 	return api.chain.ChainImport(ctx, in)
 }
 
+// ChainCheckTipIndex checks the persisted tip index against the chain and
+// repairs it if `repair` is true.
+func (api *API) ChainCheckTipIndex(ctx context.Context, repair bool) (*chain.TipIndexReport, error) {
+	return api.chain.ChainCheckTipIndex(ctx, repair)
+}
+
+// ChainFsck checks that the data of the `depth` most recent tipsets of the
+// chain, or all of them if `depth` is zero, is present and consistent.  If
+// `repair` is true missing data is fetched from peers and missing state
+// recomputed, after which the chain is checked again.
+func (api *API) ChainFsck(ctx context.Context, depth uint64, repair bool) (*chain.FsckReport, error) {
+	report, err := api.chain.ChainFsck(ctx, depth)
+	if err != nil {
+		return nil, err
+	}
+	if !repair || report.Consistent() {
+		return report, nil
+	}
+	if err := api.syncer.RepairChain(ctx, report); err != nil {
+		return nil, errors.Wrap(err, "failed to repair chain")
+	}
+	repaired, err := api.chain.ChainFsck(ctx, depth)
+	if err != nil {
+		return nil, err
+	}
+	if repaired.Consistent() {
+		report.Repaired = true
+	}
+	return report, nil
+}
+
+// ChainImportValidated imports a chain from `in` and validates it through the
+// syncer, which stages its head if it is the heaviest.  Tipsets at or below
+// `trustedHeight` are not re-executed.
+func (api *API) ChainImportValidated(ctx context.Context, in io.Reader, trustedHeight uint64) (block.TipSetKey, error) {
+	headKey, err := api.chain.ChainImport(ctx, in)
+	if err != nil {
+		return block.UndefTipSet.Key(), err
+	}
+	tipsets, err := api.chain.ChainImportedTipSets(ctx, headKey)
+	if err != nil {
+		return block.UndefTipSet.Key(), err
+	}
+	if err := api.syncer.HandleImportedChain(ctx, tipsets, trustedHeight); err != nil {
+		return block.UndefTipSet.Key(), errors.Wrapf(err, "imported chain with head %s is invalid", headKey)
+	}
+	return headKey, nil
+}
+
+// ChainPruneState deletes chain state and receipts of tipsets more than
+// `keepEpochs` epochs below finality from the blockstore.
+func (api *API) ChainPruneState(ctx context.Context, keepEpochs uint64) (*chain.PruneResult, error) {
+	head, err := api.chain.GetTipSet(api.chain.Head())
+	if err != nil {
+		return nil, err
+	}
+	return api.statePruner.Prune(ctx, head, keepEpochs)
+}
+
 // DealsIterator returns an iterator to access all deals
 func (api *API) DealsIterator() (*query.Results, error) {
 	return api.storagedeals.Iterator()
@@ -220,11 +324,35 @@ func (api *API) OutboxQueueLs(sender address.Address) []*message.Queued {
 	return api.outbox.Queue().List(sender)
 }
 
+// SlashingReports lists the consensus faults detected by the node and the progress of
+// their reports, oldest first.
+func (api *API) SlashingReports() []*slashing.FaultReport {
+	return api.slasher.Reports()
+}
+
 // OutboxQueueClear clears messages in the queue for an address/
 func (api *API) OutboxQueueClear(ctx context.Context, sender address.Address) {
 	api.outbox.Queue().Clear(ctx, sender)
 }
 
+// OutboxReplace re-signs a queued message with a higher gas price and publishes it
+// in place of the original. It returns the CID of the replacement.
+func (api *API) OutboxReplace(ctx context.Context, c cid.Cid, gasPrice types.AttoFIL) (cid.Cid, error) {
+	return api.outbox.Replace(ctx, c, gasPrice, true)
+}
+
+// OutboxNextNonce returns the nonce of the next message from `from`, following both the
+// actor's state at the head and the messages queued for it.
+func (api *API) OutboxNextNonce(ctx context.Context, from address.Address) (uint64, error) {
+	return api.outbox.NextNonce(ctx, from)
+}
+
+// OutboxSendBatch signs and sends messages from `from` with a contiguous range of nonces. Either
+// all the messages are queued or none is. It returns their CIDs, in order.
+func (api *API) OutboxSendBatch(ctx context.Context, from address.Address, msgs []*message.BatchMessage, gasPrice types.AttoFIL, gasLimit types.GasUnits) ([]cid.Cid, chan error, error) {
+	return api.outbox.SendBatch(ctx, from, msgs, gasPrice, gasLimit, true)
+}
+
 // MessagePoolPending lists messages un-mined in the pool
 func (api *API) MessagePoolPending() []*types.SignedMessage {
 	return api.msgPool.Pending()
@@ -240,6 +368,12 @@ func (api *API) MessagePoolRemove(cid cid.Cid) {
 	api.msgPool.Remove(cid)
 }
 
+// MessagePoolSubscribe delivers the messages added to and removed from the message pool until
+// the context is done.
+func (api *API) MessagePoolSubscribe(ctx context.Context) *message.PoolSubscription {
+	return api.msgPool.Subscribe(ctx)
+}
+
 // MessagePreview previews the Gas cost of a message by running it locally on the client and
 // recording the amount of Gas used.
 func (api *API) MessagePreview(ctx context.Context, from, to address.Address, method types.MethodID, params ...interface{}) (types.GasUnits, error) {
@@ -282,9 +416,14 @@ func (api *API) SignedMessageSend(ctx context.Context, smsg *types.SignedMessage
 
 // MessageFind returns a message and receipt from the blockchain, if it exists.
 func (api *API) MessageFind(ctx context.Context, mcid cid.Cid) (*msg.ChainMessage, bool, error) {
-	return api.msgWaiter.Find(ctx, func(msg *types.SignedMessage, msgCid cid.Cid) bool {
-		return msgCid.Equals(mcid)
-	})
+	return api.msgWaiter.FindCid(ctx, mcid)
+}
+
+// MessageFindByAddress returns the messages sent from `from` and to `to` at
+// heights between `minHeight` and `maxHeight` inclusive, with their receipts.
+// An empty address matches any address but at least one must be given.
+func (api *API) MessageFindByAddress(ctx context.Context, from, to address.Address, minHeight, maxHeight uint64) ([]*msg.ChainMessage, error) {
+	return api.msgWaiter.FindAddressMessages(ctx, from, to, minHeight, maxHeight)
 }
 
 // MessageWait invokes the callback when a message with the given cid appears on chain.
@@ -26,16 +26,27 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin/initactor"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
 )
 
 var logStore = logging.Logger("plumbing/chain_store")
 
 type chainReadWriter interface {
+	CheckTipIndex(context.Context, bool) (*chain.TipIndexReport, error)
+	Fsck(context.Context, chain.MessageProvider, uint64) (*chain.FsckReport, error)
+	ClearCheckpoint() error
+	GetCheckpoint() (block.TipSetKey, error)
 	GetHead() block.TipSetKey
 	GetTipSet(block.TipSetKey) (block.TipSet, error)
+	GetTipSetAtHeight(context.Context, block.TipSet, uint64) (block.TipSet, error)
 	GetTipSetState(context.Context, block.TipSetKey) (state.Tree, error)
+	HasTipSetAndState(context.Context, block.TipSetKey) bool
+	IncludesCheckpoint(context.Context, block.TipSet) (bool, error)
+	SetCheckpoint(context.Context, block.TipSetKey) error
 	SetHead(context.Context, block.TipSet) error
+	SubscribeHeadChanges(context.Context) *chain.HeadChangeSubscription
 	ReadOnlyStateStore() cborutil.ReadOnlyIpldStore
 }
 
@@ -103,6 +114,22 @@ func (chn *ChainStateReadWriter) GetTipSet(key block.TipSetKey) (block.TipSet, e
 	return chn.readWriter.GetTipSet(key)
 }
 
+// GetTipSetAtHeight returns the tipset at height `h` on the chain ending in
+// the head tipset. Null rounds resolve to the nearest lower tipset.
+func (chn *ChainStateReadWriter) GetTipSetAtHeight(ctx context.Context, h uint64) (block.TipSet, error) {
+	head, err := chn.readWriter.GetTipSet(chn.readWriter.GetHead())
+	if err != nil {
+		return block.UndefTipSet, err
+	}
+	return chn.readWriter.GetTipSetAtHeight(ctx, head, h)
+}
+
+// HeadChanges subscribes to the tipsets reverted and applied by each change
+// of the head until `ctx` is done.
+func (chn *ChainStateReadWriter) HeadChanges(ctx context.Context) *chain.HeadChangeSubscription {
+	return chn.readWriter.SubscribeHeadChanges(ctx)
+}
+
 // Ls returns an iterator over tipsets from head to genesis.
 func (chn *ChainStateReadWriter) Ls(ctx context.Context) (*chain.TipsetIterator, error) {
 	ts, err := chn.readWriter.GetTipSet(chn.readWriter.GetHead())
@@ -142,12 +169,22 @@ func (chn *ChainStateReadWriter) SampleRandomness(ctx context.Context, sampleHei
 	if err != nil {
 		return nil, err
 	}
-	tipSetBuffer, err := chain.GetRecentAncestors(ctx, headTipSet, chn.readWriter, sampleHeight)
+	if sampleHeight.LessThan(types.NewBlockHeight(0)) {
+		return nil, errors.Errorf("can't sample chain at negative height %s", sampleHeight)
+	}
+	sampleTipSet := headTipSet
+	headHeight, err := headTipSet.Height()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get recent ancestors")
+		return nil, err
+	}
+	if sampleHeight.LessThan(types.NewBlockHeight(headHeight)) {
+		sampleTipSet, err = chn.readWriter.GetTipSetAtHeight(ctx, headTipSet, sampleHeight.AsBigInt().Uint64())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get tipset at sample height")
+		}
 	}
 
-	return sampling.SampleChainRandomness(sampleHeight, tipSetBuffer)
+	return sampling.SampleChainRandomness(sampleHeight, []block.TipSet{sampleTipSet})
 }
 
 // GetActor returns an actor from the latest state on the chain
@@ -177,6 +214,27 @@ func (chn *ChainStateReadWriter) GetActorAt(ctx context.Context, tipKey block.Ti
 	return actr, nil
 }
 
+// ResolveAddress returns the ID address of the actor with address `addr` in the state of the
+// tipset identified by `key`. It returns false if no actor has that address.
+func (chn *ChainStateReadWriter) ResolveAddress(ctx context.Context, key block.TipSetKey, addr address.Address) (address.Address, bool, error) {
+	if addr.Protocol() == address.ID {
+		return addr, true, nil
+	}
+	st, err := chn.readWriter.GetTipSetState(ctx, key)
+	if err != nil {
+		return address.Undef, false, errors.Wrap(err, "failed to load tipset state")
+	}
+	initActor, err := st.GetActor(ctx, vmaddr.InitAddress)
+	if err != nil {
+		return address.Undef, false, errors.Wrap(err, "failed to get init actor")
+	}
+
+	vms := vm.NewStorage(chn.bstore)
+	store := vm.NewActorStorage(&vms)
+	view := initactor.NewView(vm.NewReadonlyStateHandle(store, initActor.Head.Cid), store)
+	return view.IDAddressByAddress(ctx, addr)
+}
+
 // GetActorStateAt returns the root state of an actor at a given point in the chain (specified by tipset key)
 func (chn *ChainStateReadWriter) GetActorStateAt(ctx context.Context, tipKey block.TipSetKey, addr address.Address, out interface{}) error {
 	act, err := chn.GetActorAt(ctx, tipKey, addr)
@@ -231,27 +289,55 @@ func (chn *ChainStateReadWriter) GetActorSignature(ctx context.Context, actorAdd
 }
 
 // SetHead sets `key` as the new head of this chain iff it exists in the nodes chain store.
-func (chn *ChainStateReadWriter) SetHead(ctx context.Context, key block.TipSetKey) error {
+// Unless `force` is true the new head's chain must include the checkpointed tipset.
+func (chn *ChainStateReadWriter) SetHead(ctx context.Context, key block.TipSetKey, force bool) error {
 	headTs, err := chn.readWriter.GetTipSet(key)
 	if err != nil {
 		return err
 	}
+	if !force {
+		included, err := chn.readWriter.IncludesCheckpoint(ctx, headTs)
+		if err != nil {
+			return err
+		}
+		if !included {
+			return errors.Errorf("chain of tipset %s does not include the checkpoint", key)
+		}
+	}
 	return chn.readWriter.SetHead(ctx, headTs)
 }
 
+// Checkpoint returns the key of the checkpointed tipset, which is empty if no
+// checkpoint is set.
+func (chn *ChainStateReadWriter) Checkpoint() (block.TipSetKey, error) {
+	return chn.readWriter.GetCheckpoint()
+}
+
+// SetCheckpoint checkpoints the tipset identified by `key`, which must be on
+// the chain ending in the head.
+func (chn *ChainStateReadWriter) SetCheckpoint(ctx context.Context, key block.TipSetKey) error {
+	return chn.readWriter.SetCheckpoint(ctx, key)
+}
+
+// ClearCheckpoint removes the checkpoint.
+func (chn *ChainStateReadWriter) ClearCheckpoint() error {
+	return chn.readWriter.ClearCheckpoint()
+}
+
 // ReadOnlyStateStore returns a read-only state store.
 func (chn *ChainStateReadWriter) ReadOnlyStateStore() cborutil.ReadOnlyIpldStore {
 	return chn.readWriter.ReadOnlyStateStore()
 }
 
-// ChainExport exports the chain from `head` up to and including the genesis block to `out`
-func (chn *ChainStateReadWriter) ChainExport(ctx context.Context, head block.TipSetKey, out io.Writer) error {
+// ChainExport exports the chain from `head` up to and including the genesis block to `out`,
+// restricted to the part of the chain selected by `opts`.
+func (chn *ChainStateReadWriter) ChainExport(ctx context.Context, head block.TipSetKey, opts chain.ExportOptions, out io.Writer) error {
 	headTS, err := chn.GetTipSet(head)
 	if err != nil {
 		return err
 	}
 	logStore.Infof("starting CAR file export: %s", head.String())
-	if err := chain.Export(ctx, headTS, chn.readWriter, chn.messageProvider, chn, out); err != nil {
+	if err := chain.Export(ctx, headTS, chn.readWriter, chn.messageProvider, chn, opts, out); err != nil {
 		return err
 	}
 	logStore.Infof("exported CAR file with head: %s", head.String())
@@ -269,6 +355,41 @@ func (chn *ChainStateReadWriter) ChainImport(ctx context.Context, in io.Reader)
 	return headKey, nil
 }
 
+// ChainCheckTipIndex checks the persisted tip index against the chain and
+// repairs it if `repair` is true.
+func (chn *ChainStateReadWriter) ChainCheckTipIndex(ctx context.Context, repair bool) (*chain.TipIndexReport, error) {
+	return chn.readWriter.CheckTipIndex(ctx, repair)
+}
+
+// ChainFsck checks that the data of the `depth` most recent tipsets of the
+// chain, or all of them if `depth` is zero, is present and consistent.
+func (chn *ChainStateReadWriter) ChainFsck(ctx context.Context, depth uint64) (*chain.FsckReport, error) {
+	return chn.readWriter.Fsck(ctx, chn.messageProvider, depth)
+}
+
+// ChainImportedTipSets returns the tipsets of the chain ending in `head` that
+// are in the blockstore but not yet validated into the chain store, in height
+// order.
+func (chn *ChainStateReadWriter) ChainImportedTipSets(ctx context.Context, head block.TipSetKey) ([]block.TipSet, error) {
+	var tipsets []block.TipSet
+	blocks := chain.TipSetProviderFromBlocks(ctx, chn)
+	headTS, err := blocks.GetTipSet(head)
+	if err != nil {
+		return nil, err
+	}
+	for iter := chain.IterAncestors(ctx, blocks, headTS); !iter.Complete(); err = iter.Next() {
+		if err != nil {
+			return nil, err
+		}
+		if chn.readWriter.HasTipSetAndState(ctx, iter.Value().Key()) {
+			break
+		}
+		tipsets = append(tipsets, iter.Value())
+	}
+	chain.Reverse(tipsets)
+	return tipsets, nil
+}
+
 // ChainStateTree returns the state tree as a slice of IPLD nodes at the passed stateroot cid `c`.
 func (chn *ChainStateReadWriter) ChainStateTree(ctx context.Context, c cid.Cid) ([]format.Node, error) {
 	offl := offline.Exchange(chn.bstore)
@@ -1,13 +1,18 @@
 package cst
 
 import (
+	"context"
+
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/status"
 )
 
 type chainSync interface {
 	BlockProposer() chainsync.BlockProposer
+	HandleImportedChain(context.Context, []block.TipSet, uint64) error
+	RepairChain(context.Context, *chain.FsckReport) error
 	Status() status.Status
 }
 
@@ -36,3 +41,16 @@ func (chs *ChainSyncProvider) Status() status.Status {
 func (chs *ChainSyncProvider) HandleNewTipSet(ci *block.ChainInfo) error {
 	return chs.sync.BlockProposer().SendOwnBlock(ci)
 }
+
+// HandleImportedChain validates a chain of tipsets imported to the local
+// blockstore and stages its head if it is the heaviest.  Tipsets at or below
+// `trustedHeight` are trusted and not executed.
+func (chs *ChainSyncProvider) HandleImportedChain(ctx context.Context, tipsets []block.TipSet, trustedHeight uint64) error {
+	return chs.sync.HandleImportedChain(ctx, tipsets, trustedHeight)
+}
+
+// RepairChain repairs the problems found by a chain check by fetching missing
+// data from peers and recomputing state.
+func (chs *ChainSyncProvider) RepairChain(ctx context.Context, report *chain.FsckReport) error {
+	return chs.sync.RepairChain(ctx, report)
+}
@@ -0,0 +1,405 @@
+package cst
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/pkg/errors"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin/account"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin/initactor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin/miner"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin/power"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin/storagemarket"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+// actorStates constructs the state type of each builtin actor by code CID.
+var actorStates = map[cid.Cid]func() interface{}{
+	types.AccountActorCodeCid:        func() interface{} { return &account.State{} },
+	types.InitActorCodeCid:           func() interface{} { return &initactor.State{} },
+	types.PowerActorCodeCid:          func() interface{} { return &power.State{} },
+	types.MinerActorCodeCid:          func() interface{} { return &miner.State{} },
+	types.BootstrapMinerActorCodeCid: func() interface{} { return &miner.State{} },
+	types.StorageMarketActorCodeCid:  func() interface{} { return &storagemarket.State{} },
+}
+
+// actorCollections constructs the value type of the HAMTs rooted at fields of
+// the state of builtin actors, by code CID and field name.
+var actorCollections = map[cid.Cid]map[string]func() interface{}{
+	types.InitActorCodeCid: {
+		"AddressMap": func() interface{} { return new(types.Uint64) },
+		"IDMap":      func() interface{} { return new(address.Address) },
+	},
+	types.PowerActorCodeCid: {
+		"PowerTable": func() interface{} { return &power.TableEntry{} },
+	},
+	types.StorageMarketActorCodeCid: {
+		"Miners": func() interface{} { return new(bool) },
+	},
+}
+
+// FieldChange is a field whose value differs between two versions of an
+// actor.  Values are JSON encoded and empty if the field is absent.  Entries
+// of maps and HAMTs in an actor's state are compared one by one, each change
+// naming the key in brackets after the field, e.g. "State.PowerTable[t0101]".
+type FieldChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// ActorStateDiff is an actor that differs between two state roots along with
+// the changes of its fields.  Fields of the actor's state are prefixed with
+// "State." and are only listed for actors of a builtin type.
+type ActorStateDiff struct {
+	*state.ActorDiff
+	Changes []FieldChange
+}
+
+// StateDiff returns the actors that were added, removed or changed going
+// from state root `before` to `after`.
+func (chn *ChainStateReadWriter) StateDiff(ctx context.Context, before, after cid.Cid) ([]*ActorStateDiff, error) {
+	store := cborutil.NewIpldStore(chn.bstore)
+	loader := state.NewTreeLoader()
+	beforeTree, err := loader.LoadStateTree(ctx, store, before)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load state %s", before)
+	}
+	afterTree, err := loader.LoadStateTree(ctx, store, after)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load state %s", after)
+	}
+
+	diffs, err := state.Diff(ctx, beforeTree, afterTree)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*ActorStateDiff, len(diffs))
+	for i, diff := range diffs {
+		changes, err := chn.actorChanges(ctx, store, diff.Before, diff.After)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to diff actor %s", diff.Address)
+		}
+		out[i] = &ActorStateDiff{ActorDiff: diff, Changes: changes}
+	}
+	return out, nil
+}
+
+// actorChanges returns the field changes of an actor.  Either version may be
+// nil.
+func (chn *ChainStateReadWriter) actorChanges(ctx context.Context, store *cborutil.IpldStore, before, after *actor.Actor) ([]FieldChange, error) {
+	var beforeActor, afterActor reflect.Value
+	if before != nil {
+		beforeActor = reflect.ValueOf(*before)
+	}
+	if after != nil {
+		afterActor = reflect.ValueOf(*after)
+	}
+	changes, err := appendFieldChanges(nil, "", beforeActor, afterActor, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	beforeState, err := chn.loadActorState(before)
+	if err != nil {
+		return nil, err
+	}
+	afterState, err := chn.loadActorState(after)
+	if err != nil {
+		return nil, err
+	}
+	if !beforeState.IsValid() && !afterState.IsValid() {
+		return changes, nil
+	}
+
+	collections := stateCollections(before, after)
+	changes, err = appendFieldChanges(changes, "State.", beforeState, afterState, collections)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(collections))
+	for name := range collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		changes, err = appendCollectionChanges(ctx, store, changes, "State."+name, cidField(beforeState, name), cidField(afterState, name), collections[name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to diff %s", name)
+		}
+	}
+	return changes, nil
+}
+
+// stateCollections returns the HAMT fields of the state of an actor.  They are
+// only known for builtin actors whose code did not change.
+func stateCollections(before, after *actor.Actor) map[string]func() interface{} {
+	switch {
+	case before == nil:
+		return actorCollections[after.Code.Cid]
+	case after == nil || before.Code.Equals(after.Code.Cid):
+		return actorCollections[before.Code.Cid]
+	default:
+		return nil
+	}
+}
+
+func cidField(v reflect.Value, name string) cid.Cid {
+	f := fieldValue(v, name)
+	if !f.IsValid() {
+		return cid.Undef
+	}
+	c, _ := f.Interface().(cid.Cid)
+	return c
+}
+
+// loadActorState decodes the state of a builtin actor.  It returns the zero
+// Value if the actor is nil or not of a builtin type.
+func (chn *ChainStateReadWriter) loadActorState(a *actor.Actor) (reflect.Value, error) {
+	if a == nil || !a.Head.Defined() {
+		return reflect.Value{}, nil
+	}
+	newState, ok := actorStates[a.Code.Cid]
+	if !ok {
+		return reflect.Value{}, nil
+	}
+	blk, err := chn.bstore.Get(a.Head.Cid)
+	if err != nil {
+		return reflect.Value{}, errors.Wrapf(err, "failed to load actor head %s", a.Head.Cid)
+	}
+	st := newState()
+	if err := encoding.Decode(blk.RawData(), st); err != nil {
+		return reflect.Value{}, errors.Wrapf(err, "failed to decode actor head %s", a.Head.Cid)
+	}
+	return reflect.ValueOf(st).Elem(), nil
+}
+
+// appendFieldChanges appends the exported fields of structs `before` and
+// `after` whose JSON encodings differ.  Either struct may be the zero Value.
+// The structs are of the same type unless the actor's code changed.  Map
+// fields are compared by entry and fields named in `skip` are left out.
+func appendFieldChanges(changes []FieldChange, prefix string, before, after reflect.Value, skip map[string]func() interface{}) ([]FieldChange, error) {
+	var names []string
+	seen := make(map[string]struct{})
+	for _, v := range []reflect.Value{before, after} {
+		if !v.IsValid() {
+			continue
+		}
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			if _, ok := skip[f.Name]; ok {
+				continue
+			}
+			if _, ok := seen[f.Name]; !ok {
+				seen[f.Name] = struct{}{}
+				names = append(names, f.Name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		beforeField, afterField := fieldValue(before, name), fieldValue(after, name)
+		if isMapOrAbsent(beforeField) && isMapOrAbsent(afterField) {
+			var err error
+			if changes, err = appendMapChanges(changes, prefix+name, beforeField, afterField); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		beforeValue, err := valueJSON(beforeField)
+		if err != nil {
+			return nil, err
+		}
+		afterValue, err := valueJSON(afterField)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(beforeValue, afterValue) {
+			continue
+		}
+		changes = append(changes, FieldChange{
+			Field:  prefix + name,
+			Before: string(beforeValue),
+			After:  string(afterValue),
+		})
+	}
+	return changes, nil
+}
+
+// appendMapChanges appends the entries of maps `before` and `after` whose
+// JSON encodings differ.  Either map may be the zero Value.
+func appendMapChanges(changes []FieldChange, field string, before, after reflect.Value) ([]FieldChange, error) {
+	entries := func(m reflect.Value) map[string]reflect.Value {
+		out := make(map[string]reflect.Value)
+		if m.IsValid() {
+			for _, k := range m.MapKeys() {
+				out[fmt.Sprint(k.Interface())] = m.MapIndex(k)
+			}
+		}
+		return out
+	}
+	beforeEntries, afterEntries := entries(before), entries(after)
+
+	for _, key := range unionKeys(beforeEntries, afterEntries) {
+		beforeValue, err := valueJSON(beforeEntries[key])
+		if err != nil {
+			return nil, err
+		}
+		afterValue, err := valueJSON(afterEntries[key])
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(beforeValue, afterValue) {
+			continue
+		}
+		changes = append(changes, FieldChange{
+			Field:  entryField(field, key),
+			Before: string(beforeValue),
+			After:  string(afterValue),
+		})
+	}
+	return changes, nil
+}
+
+// appendCollectionChanges appends the entries of the HAMTs rooted at `before`
+// and `after` that differ.  Either root may be undefined for an empty HAMT.
+// Values are decoded with `newValue` to be JSON encoded.
+func appendCollectionChanges(ctx context.Context, store *cborutil.IpldStore, changes []FieldChange, field string, before, after cid.Cid, newValue func() interface{}) ([]FieldChange, error) {
+	if before.Equals(after) {
+		return changes, nil
+	}
+	beforeEntries, err := loadCollection(ctx, store, before)
+	if err != nil {
+		return nil, err
+	}
+	afterEntries, err := loadCollection(ctx, store, after)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range unionKeys(beforeEntries, afterEntries) {
+		beforeRaw, afterRaw := beforeEntries[key], afterEntries[key]
+		if bytes.Equal(beforeRaw, afterRaw) {
+			continue
+		}
+		beforeValue, err := entryJSON(beforeRaw, newValue)
+		if err != nil {
+			return nil, err
+		}
+		afterValue, err := entryJSON(afterRaw, newValue)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, FieldChange{
+			Field:  entryField(field, key),
+			Before: string(beforeValue),
+			After:  string(afterValue),
+		})
+	}
+	return changes, nil
+}
+
+// loadCollection returns the encoded values of the HAMT rooted at `root` by
+// key.
+func loadCollection(ctx context.Context, store *cborutil.IpldStore, root cid.Cid) (map[string][]byte, error) {
+	entries := make(map[string][]byte)
+	if !root.Defined() {
+		return entries, nil
+	}
+	node, err := hamt.LoadNode(ctx, store, root, hamt.UseTreeBitWidth(actor.TreeBitWidth))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load HAMT %s", root)
+	}
+	err = node.ForEach(ctx, func(k string, v interface{}) error {
+		deferred, ok := v.(*cbg.Deferred)
+		if !ok {
+			return errors.Errorf("unexpected value of type %T at key %q", v, k)
+		}
+		entries[k] = deferred.Raw
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func entryJSON(raw []byte, newValue func() interface{}) ([]byte, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	v := newValue()
+	if err := encoding.DecodeDeprecated(raw, v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// entryField names the entry at `key` of `field`.  Keys that are not
+// printable, such as encoded integers, are shown in hex.
+func entryField(field, key string) string {
+	printable := utf8.ValidString(key)
+	for _, r := range key {
+		printable = printable && strconv.IsPrint(r)
+	}
+	if !printable {
+		key = fmt.Sprintf("0x%x", key)
+	}
+	return field + "[" + key + "]"
+}
+
+// unionKeys returns the sorted keys of maps `a` and `b`, which are keyed by
+// strings.
+func unionKeys(a, b interface{}) []string {
+	var keys []string
+	seen := make(map[string]struct{})
+	for _, m := range []reflect.Value{reflect.ValueOf(a), reflect.ValueOf(b)} {
+		for _, k := range m.MapKeys() {
+			if _, ok := seen[k.String()]; !ok {
+				seen[k.String()] = struct{}{}
+				keys = append(keys, k.String())
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isMapOrAbsent(v reflect.Value) bool {
+	return !v.IsValid() || v.Kind() == reflect.Map
+}
+
+// fieldValue returns the field `name` of struct `v`, or the zero Value if `v`
+// is the zero Value or has no such field.
+func fieldValue(v reflect.Value, name string) reflect.Value {
+	if !v.IsValid() {
+		return reflect.Value{}
+	}
+	return v.FieldByName(name)
+}
+
+func valueJSON(v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	return json.Marshal(v.Interface())
+}
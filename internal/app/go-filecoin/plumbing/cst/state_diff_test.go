@@ -0,0 +1,123 @@
+package cst
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-hamt-ipld"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin/power"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+func TestStateDiff(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	bs := bstore.NewBlockstore(repo.NewInMemoryRepo().Datastore())
+	store := cborutil.NewIpldStore(bs)
+	chn := &ChainStateReadWriter{bstore: bs}
+
+	addrGetter := vmaddr.NewForTestGetter()
+	kept, changed, removed, added := addrGetter(), addrGetter(), addrGetter(), addrGetter()
+	entry := func(active uint64) power.TableEntry {
+		return power.TableEntry{
+			ActivePower:            types.NewBytesAmount(active),
+			InactivePower:          types.NewBytesAmount(0),
+			AvailableBalance:       types.ZeroAttoFIL,
+			LockedPledgeCollateral: types.ZeroAttoFIL,
+			SectorSize:             types.NewBytesAmount(1024),
+		}
+	}
+	powerActor := func(table map[address.Address]power.TableEntry) *actor.Actor {
+		node := hamt.NewNode(store, hamt.UseTreeBitWidth(actor.TreeBitWidth))
+		for addr, entry := range table {
+			require.NoError(t, node.Set(ctx, addr.String(), entry))
+		}
+		require.NoError(t, node.Flush(ctx))
+		root, err := store.Put(ctx, node)
+		require.NoError(t, err)
+		head, err := store.Put(ctx, &power.State{PowerTable: root})
+		require.NoError(t, err)
+		a := actor.NewActor(types.PowerActorCodeCid, types.ZeroAttoFIL)
+		a.Head = e.NewCid(head)
+		return a
+	}
+	before := state.NewTree(store)
+	require.NoError(t, before.SetActor(ctx, vmaddr.StoragePowerAddress, powerActor(map[address.Address]power.TableEntry{
+		kept: entry(1), changed: entry(2), removed: entry(3),
+	})))
+	beforeRoot, err := before.Flush(ctx)
+	require.NoError(t, err)
+	after := state.NewTree(store)
+	require.NoError(t, after.SetActor(ctx, vmaddr.StoragePowerAddress, powerActor(map[address.Address]power.TableEntry{
+		kept: entry(1), changed: entry(4), added: entry(5),
+	})))
+	afterRoot, err := after.Flush(ctx)
+	require.NoError(t, err)
+
+	diffs, err := chn.StateDiff(ctx, beforeRoot, afterRoot)
+	require.NoError(t, err)
+	require.Len(t, diffs, 1)
+	assert.Equal(t, vmaddr.StoragePowerAddress, diffs[0].Address)
+
+	// The power table is compared entry by entry rather than by its root.
+	entryJSON := func(active uint64) string {
+		bb, err := json.Marshal(entry(active))
+		require.NoError(t, err)
+		return string(bb)
+	}
+	var stateChanges []FieldChange
+	for _, change := range diffs[0].Changes {
+		if strings.HasPrefix(change.Field, "State.") {
+			stateChanges = append(stateChanges, change)
+		}
+	}
+	expected := []FieldChange{
+		{Field: "State.PowerTable[" + added.String() + "]", After: entryJSON(5)},
+		{Field: "State.PowerTable[" + changed.String() + "]", Before: entryJSON(2), After: entryJSON(4)},
+		{Field: "State.PowerTable[" + removed.String() + "]", Before: entryJSON(3)},
+	}
+	sort.Slice(expected, func(i, j int) bool {
+		return expected[i].Field < expected[j].Field
+	})
+	assert.Equal(t, expected, stateChanges)
+
+	diffs, err = chn.StateDiff(ctx, afterRoot, afterRoot)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+}
+
+func TestAppendFieldChangesComparesMapEntries(t *testing.T) {
+	tf.UnitTest(t)
+
+	type withMap struct {
+		Name    string
+		Sectors map[string]int
+	}
+	before := withMap{Name: "a", Sectors: map[string]int{"1": 1, "2": 2}}
+	after := withMap{Name: "b", Sectors: map[string]int{"1": 1, "2": 3, "3": 4}}
+
+	changes, err := appendFieldChanges(nil, "State.", reflect.ValueOf(before), reflect.ValueOf(after), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []FieldChange{
+		{Field: "State.Name", Before: `"a"`, After: `"b"`},
+		{Field: "State.Sectors[2]", Before: "2", After: "3"},
+		{Field: "State.Sectors[3]", After: "4"},
+	}, changes)
+}
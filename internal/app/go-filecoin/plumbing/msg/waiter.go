@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/cskr/pubsub"
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/ipfs/go-cid"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
@@ -28,10 +29,18 @@ type waiterChainReader interface {
 	HeadEvents() *pubsub.PubSub
 }
 
+// Abstracts over an index of messages included in the chain.
+type messageIndex interface {
+	Head() (block.TipSetKey, error)
+	Lookup(cid.Cid) (*chain.MessageInclusion, bool, error)
+	AddressMessages(context.Context, address.Address, chain.MessageParty, uint64, uint64) ([]cid.Cid, error)
+}
+
 // Waiter waits for a message to appear on chain.
 type Waiter struct {
 	chainReader     waiterChainReader
 	messageProvider chain.MessageProvider
+	index           messageIndex
 	cst             cbor.IpldStore
 	bs              bstore.Blockstore
 }
@@ -47,12 +56,13 @@ type ChainMessage struct {
 type WaitPredicate func(msg *types.SignedMessage, msgCid cid.Cid) bool
 
 // NewWaiter returns a new Waiter.
-func NewWaiter(chainStore waiterChainReader, messages chain.MessageProvider, bs bstore.Blockstore, cst cbor.IpldStore) *Waiter {
+func NewWaiter(chainStore waiterChainReader, messages chain.MessageProvider, index messageIndex, bs bstore.Blockstore, cst cbor.IpldStore) *Waiter {
 	return &Waiter{
 		chainReader:     chainStore,
 		cst:             cst,
 		bs:              bs,
 		messageProvider: messages,
+		index:           index,
 	}
 }
 
@@ -65,6 +75,126 @@ func (w *Waiter) Find(ctx context.Context, pred WaitPredicate) (*ChainMessage, b
 	return w.findMessage(ctx, headTipSet, pred)
 }
 
+// FindCid looks up the message with CID `msgCid` in the message index.  The
+// blockchain history is only searched while the index has not caught up with
+// the head.
+func (w *Waiter) FindCid(ctx context.Context, msgCid cid.Cid) (*ChainMessage, bool, error) {
+	inclusion, found, err := w.index.Lookup(msgCid)
+	if err != nil {
+		return nil, false, err
+	}
+	if found {
+		return w.chainMessageFromInclusion(ctx, msgCid, inclusion)
+	}
+
+	indexHead, err := w.index.Head()
+	if err != nil {
+		return nil, false, err
+	}
+	if indexHead.Equals(w.chainReader.GetHead()) {
+		return nil, false, nil
+	}
+	return w.Find(ctx, func(msg *types.SignedMessage, c cid.Cid) bool {
+		return c.Equals(msgCid)
+	})
+}
+
+// FindAddressMessages returns the messages sent from `from` and to `to`,
+// included at heights between `minHeight` and `maxHeight` inclusive, ordered
+// by increasing height.  An empty address matches any address but at least
+// one must be given.  Messages are listed from the message index, so messages
+// included by tipsets the index has not caught up with yet are missing.
+func (w *Waiter) FindAddressMessages(ctx context.Context, from, to address.Address, minHeight, maxHeight uint64) ([]*ChainMessage, error) {
+	var cids []cid.Cid
+	var err error
+	switch {
+	case !from.Empty():
+		cids, err = w.index.AddressMessages(ctx, from, chain.MessageFrom, minHeight, maxHeight)
+	case !to.Empty():
+		cids, err = w.index.AddressMessages(ctx, to, chain.MessageTo, minHeight, maxHeight)
+	default:
+		return nil, errors.New("no address to find messages of")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !from.Empty() && !to.Empty() {
+		// The index resolves both addresses, so intersecting its lists matches
+		// recipients named by another address of the same actor.
+		toCids, err := w.index.AddressMessages(ctx, to, chain.MessageTo, minHeight, maxHeight)
+		if err != nil {
+			return nil, err
+		}
+		sentTo := make(map[cid.Cid]struct{}, len(toCids))
+		for _, c := range toCids {
+			sentTo[c] = struct{}{}
+		}
+		var both []cid.Cid
+		for _, c := range cids {
+			if _, ok := sentTo[c]; ok {
+				both = append(both, c)
+			}
+		}
+		cids = both
+	}
+
+	var msgs []*ChainMessage
+	for _, c := range cids {
+		inclusion, found, err := w.index.Lookup(c)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			// The message was reverted since it was listed.
+			continue
+		}
+		chainMsg, _, err := w.chainMessageFromInclusion(ctx, c, inclusion)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, chainMsg)
+	}
+	return msgs, nil
+}
+
+// chainMessageFromInclusion loads the message and block recorded by an index
+// entry.
+func (w *Waiter) chainMessageFromInclusion(ctx context.Context, msgCid cid.Cid, inclusion *chain.MessageInclusion) (*ChainMessage, bool, error) {
+	ts, err := w.chainReader.GetTipSet(inclusion.TipSet)
+	if err != nil {
+		return nil, false, err
+	}
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		if !blk.Cid().Equals(inclusion.Block.Cid) {
+			continue
+		}
+		secpMsgs, blsMsgs, err := w.messageProvider.LoadMessages(ctx, blk.Messages.Cid)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, msg := range blsMsgs {
+			c, err := msg.Cid()
+			if err != nil {
+				return nil, false, err
+			}
+			if c.Equals(msgCid) {
+				return &ChainMessage{&types.SignedMessage{Message: *msg}, blk, &inclusion.Receipt}, true, nil
+			}
+		}
+		for _, msg := range secpMsgs {
+			c, err := msg.Cid()
+			if err != nil {
+				return nil, false, err
+			}
+			if c.Equals(msgCid) {
+				return &ChainMessage{msg, blk, &inclusion.Receipt}, true, nil
+			}
+		}
+	}
+	return nil, false, errors.Errorf("indexed message %s not found in tipset %s", msgCid, inclusion.TipSet)
+}
+
 // WaitPredicate invokes the callback when the passed predicate succeeds.
 // See api description.
 //
@@ -74,9 +204,8 @@ func (w *Waiter) Find(ctx context.Context, pred WaitPredicate) (*ChainMessage, b
 // Something like receiptFromTipset is necessary because not every message in
 // a block will have a receipt in the tipset: it might be a duplicate message.
 //
-// TODO: This implementation will become prohibitively expensive since it
-// traverses the entire chain. We should use an index instead.
-// https://github.com/filecoin-project/go-filecoin/issues/1518
+// WaitPredicate traverses the entire chain looking for a match. Wait finds
+// messages by CID through the message index instead.
 func (w *Waiter) WaitPredicate(ctx context.Context, pred WaitPredicate, cb func(*block.Block, *types.SignedMessage, *types.MessageReceipt) error) error {
 	ch := w.chainReader.HeadEvents().Sub(chain.NewHeadTopic)
 	defer w.chainReader.HeadEvents().Unsub(ch, chain.NewHeadTopic)
@@ -88,8 +217,13 @@ func (w *Waiter) WaitPredicate(ctx context.Context, pred WaitPredicate, cb func(
 	if found {
 		return cb(chainMsg.Block, chainMsg.Message, chainMsg.Receipt)
 	}
+	return w.waitForNewMessage(ctx, ch, pred, cb)
+}
 
-	chainMsg, found, err = w.waitForMessage(ctx, ch, pred)
+// waitForNewMessage invokes the callback when a message matching the
+// predicate appears in a tipset read from `ch`.
+func (w *Waiter) waitForNewMessage(ctx context.Context, ch <-chan interface{}, pred WaitPredicate, cb func(*block.Block, *types.SignedMessage, *types.MessageReceipt) error) error {
+	chainMsg, found, err := w.waitForMessage(ctx, ch, pred)
 	if err != nil {
 		return err
 	}
@@ -107,7 +241,17 @@ func (w *Waiter) Wait(ctx context.Context, msgCid cid.Cid, cb func(*block.Block,
 		return c.Equals(msgCid)
 	}
 
-	return w.WaitPredicate(ctx, pred, cb)
+	ch := w.chainReader.HeadEvents().Sub(chain.NewHeadTopic)
+	defer w.chainReader.HeadEvents().Unsub(ch, chain.NewHeadTopic)
+
+	chainMsg, found, err := w.FindCid(ctx, msgCid)
+	if err != nil {
+		return err
+	}
+	if found {
+		return cb(chainMsg.Block, chainMsg.Message, chainMsg.Receipt)
+	}
+	return w.waitForNewMessage(ctx, ch, pred, cb)
 }
 
 // findMessage looks for a matching in the chain and returns the message,
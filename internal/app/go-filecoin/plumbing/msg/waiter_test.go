@@ -12,11 +12,13 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cst"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
 	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
 	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin"
 )
 
 var mockSigner, _ = types.NewMockSignersAndKeyInfo(10)
@@ -47,7 +49,8 @@ type smsgsSet [][]*types.SignedMessage
 
 func setupTest(t *testing.T) (cbor.IpldStore, *chain.Store, *chain.MessageStore, *Waiter) {
 	d := requiredCommonDeps(t, th.DefaultGenesis)
-	return d.cst, d.chainStore, d.messages, NewWaiter(d.chainStore, d.messages, d.blockstore, d.cst)
+	index := chain.NewMessageIndex(d.repo.ChainDatastore(), d.chainStore, d.messages, cst.NewChainStateReadWriter(d.chainStore, d.messages, d.blockstore, builtin.DefaultActors))
+	return d.cst, d.chainStore, d.messages, NewWaiter(d.chainStore, d.messages, index, d.blockstore, d.cst)
 }
 
 func TestWait(t *testing.T) {
@@ -106,6 +109,44 @@ func testWaitNew(ctx context.Context, t *testing.T, cst cbor.IpldStore, chainSto
 	wg.Wait()
 }
 
+func TestFindCidThroughIndex(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	d := requiredCommonDeps(t, th.DefaultGenesis)
+	index := chain.NewMessageIndex(d.repo.ChainDatastore(), d.chainStore, d.messages, cst.NewChainStateReadWriter(d.chainStore, d.messages, d.blockstore, builtin.DefaultActors))
+	waiter := NewWaiter(d.chainStore, d.messages, index, d.blockstore, d.cst)
+
+	m1, m2 := newSignedMessage(), newSignedMessage()
+	headTipSet, err := d.chainStore.GetTipSet(d.chainStore.GetHead())
+	require.NoError(t, err)
+	chainWithMsgs := newChainWithMessages(d.cst, d.messages, headTipSet, smsgsSet{smsgs{m1}})
+	ts := chainWithMsgs[len(chainWithMsgs)-1]
+	require.NoError(t, d.chainStore.PutTipSetMetadata(ctx, &chain.TipSetMetadata{
+		TipSet:          ts,
+		TipSetStateRoot: ts.At(0).StateRoot.Cid,
+		TipSetReceipts:  ts.At(0).MessageReceipts.Cid,
+	}))
+	require.NoError(t, d.chainStore.SetHead(ctx, ts))
+	require.NoError(t, index.CatchUp(ctx, ts))
+
+	c1, err := m1.Cid()
+	require.NoError(t, err)
+	chainMsg, found, err := waiter.FindCid(ctx, c1)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.True(t, types.SmsgCidsEqual(m1, chainMsg.Message))
+	assert.Equal(t, ts.At(0).Cid(), chainMsg.Block.Cid())
+	assert.Equal(t, [][]byte{c1.Bytes()}, chainMsg.Receipt.Return)
+
+	// Messages missing from an up to date index are not on chain.
+	c2, err := m2.Cid()
+	require.NoError(t, err)
+	_, found, err = waiter.FindCid(ctx, c2)
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
 func TestWaitError(t *testing.T) {
 	tf.UnitTest(t)
 
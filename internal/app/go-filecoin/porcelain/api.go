@@ -14,6 +14,7 @@ import (
 	"github.com/libp2p/go-libp2p-core/peer"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage/storagedeal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	minerActor "github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin/miner"
@@ -77,6 +78,24 @@ func (a *API) DealsLs(ctx context.Context) (<-chan *StorageDealLsResult, error)
 	return DealsLs(ctx, a)
 }
 
+// MessageEstimateGasPrice recommends a gas price for a message to be included within `delay`
+// tipsets, from the messages included in the last `tipsets` tipsets and the message pool.
+func (a *API) MessageEstimateGasPrice(ctx context.Context, tipsets, delay uint) (*GasPriceEstimate, error) {
+	return MessageEstimateGasPrice(ctx, a, tipsets, delay)
+}
+
+// MessageCreate builds an unsigned message from `from` bearing its next nonce, to be signed
+// elsewhere.
+func (a *API) MessageCreate(ctx context.Context, from, to address.Address, value types.AttoFIL, method types.MethodID, params []byte, gasPrice types.AttoFIL, gasLimit types.GasUnits) (*types.UnsignedMessage, error) {
+	return MessageCreate(ctx, a, from, to, value, method, params, gasPrice, gasLimit)
+}
+
+// MessageSendBatch sends messages from `from` with a contiguous range of nonces, optionally
+// waiting until they are mined.
+func (a *API) MessageSendBatch(ctx context.Context, from address.Address, msgs []*message.BatchMessage, gasPrice types.AttoFIL, gasLimit types.GasUnits, wait bool) ([]cid.Cid, []*types.MessageReceipt, error) {
+	return MessageSendBatch(ctx, a, from, msgs, gasPrice, gasLimit, wait)
+}
+
 // MessagePoolWait waits for the message pool to have at least messageCount unmined messages.
 // It's useful for integration testing.
 func (a *API) MessagePoolWait(ctx context.Context, messageCount uint) ([]*types.SignedMessage, error) {
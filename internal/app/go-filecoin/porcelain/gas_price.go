@@ -0,0 +1,120 @@
+package porcelain
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// DefaultGasPriceSampleTipSets is the number of recent tipsets whose messages are sampled to
+// estimate a gas price when the caller does not say.
+const DefaultGasPriceSampleTipSets = 10
+
+// DefaultGasPriceTargetDelay is the number of tipsets within which a message should be
+// included when the caller does not say.
+const DefaultGasPriceTargetDelay = 1
+
+// minGasPrice is the lowest gas price recommended, since messages must pay a positive price.
+var minGasPrice = types.NewGasPrice(1)
+
+// GasPriceEstimate is a gas price recommended for a message to be included within a target
+// number of tipsets.
+type GasPriceEstimate struct {
+	// Price is the recommended gas price.
+	Price types.AttoFIL
+	// TipSetsSampled is the number of recent tipsets whose messages were sampled.
+	TipSetsSampled uint
+	// MessagesSampled is the number of messages in the sampled tipsets.
+	MessagesSampled uint
+	// Pending is the number of messages in the message pool.
+	Pending uint
+}
+
+type gasPricePlumbing interface {
+	ChainHeadKey() block.TipSetKey
+	ChainTipSet(key block.TipSetKey) (block.TipSet, error)
+	ChainGetMessages(ctx context.Context, metaCid cid.Cid) ([]*types.SignedMessage, error)
+	MessagePoolPending() []*types.SignedMessage
+}
+
+// MessageEstimateGasPrice recommends a gas price for a message to be included within `delay`
+// tipsets from the messages included in the last `tipsets` tipsets and those pending in the
+// message pool.
+//
+// The recommendation starts from a percentile of the sampled prices: the median for the next
+// tipset and lower percentiles for longer delays (the 100/(delay+1)th). If there are more
+// pending messages than the sampled tipsets included on average in `delay` tipsets, miners
+// selecting by price will not get to a message paying less than the last of those that fit,
+// so the recommendation is raised to outbid it. Without history, the recommendation is the
+// lowest positive price.
+func MessageEstimateGasPrice(ctx context.Context, plumbing gasPricePlumbing, tipsets, delay uint) (*GasPriceEstimate, error) {
+	if tipsets == 0 {
+		return nil, errors.New("must sample at least one tipset")
+	}
+	if delay == 0 {
+		return nil, errors.New("target delay must be at least one tipset")
+	}
+
+	estimate := &GasPriceEstimate{Price: minGasPrice}
+	var prices []types.AttoFIL
+	key := plumbing.ChainHeadKey()
+	for !key.Empty() && estimate.TipSetsSampled < tipsets {
+		ts, err := plumbing.ChainTipSet(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get tipset %s", key)
+		}
+		// The blocks of a tipset may include the same message.
+		seen := make(map[cid.Cid]struct{})
+		for i := 0; i < ts.Len(); i++ {
+			msgs, err := plumbing.ChainGetMessages(ctx, ts.At(i).Messages.Cid)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get messages of block %s", ts.At(i).Cid())
+			}
+			for _, msg := range msgs {
+				c, err := msg.Cid()
+				if err != nil {
+					return nil, err
+				}
+				if _, ok := seen[c]; ok {
+					continue
+				}
+				seen[c] = struct{}{}
+				prices = append(prices, msg.Message.GasPrice)
+			}
+		}
+		estimate.TipSetsSampled++
+		key, err = ts.Parents()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pending := plumbing.MessagePoolPending()
+	estimate.MessagesSampled = uint(len(prices))
+	estimate.Pending = uint(len(pending))
+	if len(prices) == 0 {
+		return estimate, nil
+	}
+
+	sort.Slice(prices, func(i, j int) bool { return prices[i].LessThan(prices[j]) })
+	if percentile := prices[(len(prices)-1)/int(delay+1)]; percentile.GreaterThan(estimate.Price) {
+		estimate.Price = percentile
+	}
+
+	// The number of messages miners can be expected to include within the delay.
+	perTipSet := (estimate.MessagesSampled + estimate.TipSetsSampled - 1) / estimate.TipSetsSampled
+	capacity := int(perTipSet * delay)
+	if len(pending) >= capacity {
+		sort.Slice(pending, func(i, j int) bool { return pending[i].Message.GasPrice.GreaterThan(pending[j].Message.GasPrice) })
+		outbid := pending[capacity-1].Message.GasPrice.Add(types.NewGasPrice(1))
+		if outbid.GreaterThan(estimate.Price) {
+			estimate.Price = outbid
+		}
+	}
+	return estimate, nil
+}
@@ -0,0 +1,120 @@
+package porcelain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+type fakeGasPricePlumbing struct {
+	builder *chain.Builder
+	head    block.TipSetKey
+	pending []*types.SignedMessage
+}
+
+func (p *fakeGasPricePlumbing) ChainHeadKey() block.TipSetKey {
+	return p.head
+}
+
+func (p *fakeGasPricePlumbing) ChainTipSet(key block.TipSetKey) (block.TipSet, error) {
+	return p.builder.GetTipSet(key)
+}
+
+func (p *fakeGasPricePlumbing) ChainGetMessages(ctx context.Context, metaCid cid.Cid) ([]*types.SignedMessage, error) {
+	secpMsgs, _, err := p.builder.LoadMessages(ctx, metaCid)
+	return secpMsgs, err
+}
+
+func (p *fakeGasPricePlumbing) MessagePoolPending() []*types.SignedMessage {
+	return p.pending
+}
+
+func TestMessageEstimateGasPrice(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer, _ := types.NewMockSignersAndKeyInfo(1)
+	from := signer.Addresses[0]
+	to := vmaddr.NewForTestGetter()()
+	nonce := uint64(0)
+	newMsg := func(price int64) *types.SignedMessage {
+		msg := types.NewMeteredMessage(from, to, nonce, types.ZeroAttoFIL, types.SendMethodID, nil, types.NewGasPrice(price), types.NewGasUnits(0))
+		nonce++
+		smsg, err := types.NewSignedMessage(*msg, signer)
+		require.NoError(t, err)
+		return smsg
+	}
+
+	// Three tipsets including messages paying 1 to 6.
+	builder := chain.NewBuilder(t, address.Undef)
+	genesis := builder.NewGenesis()
+	head := genesis
+	for price := int64(1); price <= 6; price += 2 {
+		msgs := []*types.SignedMessage{newMsg(price), newMsg(price + 1)}
+		head = builder.BuildOneOn(head, func(b *chain.BlockBuilder) {
+			b.AddMessages(msgs, []*types.UnsignedMessage{})
+		})
+	}
+	plumbing := &fakeGasPricePlumbing{builder: builder, head: head.Key()}
+
+	t.Run("percentile of recent prices", func(t *testing.T) {
+		estimate, err := porcelain.MessageEstimateGasPrice(ctx, plumbing, 3, 1)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewGasPrice(3), estimate.Price)
+		assert.Equal(t, uint(3), estimate.TipSetsSampled)
+		assert.Equal(t, uint(6), estimate.MessagesSampled)
+
+		estimate, err = porcelain.MessageEstimateGasPrice(ctx, plumbing, 3, 2)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewGasPrice(2), estimate.Price)
+
+		estimate, err = porcelain.MessageEstimateGasPrice(ctx, plumbing, 1, 1)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewGasPrice(5), estimate.Price)
+		assert.Equal(t, uint(1), estimate.TipSetsSampled)
+		assert.Equal(t, uint(2), estimate.MessagesSampled)
+	})
+
+	t.Run("outbids a congested pool", func(t *testing.T) {
+		// Two messages fit in a tipset.
+		plumbing.pending = []*types.SignedMessage{newMsg(2), newMsg(10), newMsg(8)}
+		defer func() { plumbing.pending = nil }()
+
+		estimate, err := porcelain.MessageEstimateGasPrice(ctx, plumbing, 3, 1)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewGasPrice(9), estimate.Price)
+		assert.Equal(t, uint(3), estimate.Pending)
+
+		// All pending messages fit within two tipsets.
+		estimate, err = porcelain.MessageEstimateGasPrice(ctx, plumbing, 3, 2)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewGasPrice(2), estimate.Price)
+	})
+
+	t.Run("no history", func(t *testing.T) {
+		plumbing := &fakeGasPricePlumbing{builder: builder, head: genesis.Key(), pending: []*types.SignedMessage{newMsg(10)}}
+		estimate, err := porcelain.MessageEstimateGasPrice(ctx, plumbing, 3, 1)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewGasPrice(1), estimate.Price)
+		assert.Equal(t, uint(1), estimate.TipSetsSampled)
+		assert.Equal(t, uint(0), estimate.MessagesSampled)
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		_, err := porcelain.MessageEstimateGasPrice(ctx, plumbing, 0, 1)
+		assert.Error(t, err)
+		_, err = porcelain.MessageEstimateGasPrice(ctx, plumbing, 3, 0)
+		assert.Error(t, err)
+	})
+}
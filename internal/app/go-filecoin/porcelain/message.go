@@ -3,9 +3,12 @@ package porcelain
 import (
 	"context"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/util/moresync"
 )
@@ -29,3 +32,44 @@ func MessageWaitDone(ctx context.Context, plumbing waitPlumbing, msgCid cid.Cid)
 	l.Wait()
 	return ret, nil
 }
+
+type sendBatchPlumbing interface {
+	waitPlumbing
+	OutboxSendBatch(ctx context.Context, from address.Address, msgs []*message.BatchMessage, gasPrice types.AttoFIL, gasLimit types.GasUnits) ([]cid.Cid, chan error, error)
+}
+
+// MessageSendBatch sends messages from `from` with a contiguous range of nonces and returns their
+// CIDs, in order. If wait is true, it blocks until all the messages are mined and returns their
+// receipts too.
+func MessageSendBatch(ctx context.Context, plumbing sendBatchPlumbing, from address.Address, msgs []*message.BatchMessage, gasPrice types.AttoFIL, gasLimit types.GasUnits, wait bool) ([]cid.Cid, []*types.MessageReceipt, error) {
+	cids, pubErrCh, err := plumbing.OutboxSendBatch(ctx, from, msgs, gasPrice, gasLimit)
+	if err != nil || !wait {
+		return cids, nil, err
+	}
+	if err := <-pubErrCh; err != nil {
+		return cids, nil, errors.Wrap(err, "failed to publish messages")
+	}
+
+	receipts := make([]*types.MessageReceipt, len(cids))
+	for i, c := range cids {
+		receipts[i], err = MessageWaitDone(ctx, plumbing, c)
+		if err != nil {
+			return cids, nil, errors.Wrapf(err, "failed waiting for message %s", c)
+		}
+	}
+	return cids, receipts, nil
+}
+
+type messageCreatePlumbing interface {
+	OutboxNextNonce(ctx context.Context, from address.Address) (uint64, error)
+}
+
+// MessageCreate builds an unsigned message from `from` bearing its next nonce, to be signed
+// elsewhere and sent with SignedMessageSend.
+func MessageCreate(ctx context.Context, plumbing messageCreatePlumbing, from, to address.Address, value types.AttoFIL, method types.MethodID, params []byte, gasPrice types.AttoFIL, gasLimit types.GasUnits) (*types.UnsignedMessage, error) {
+	nonce, err := plumbing.OutboxNextNonce(ctx, from)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get next nonce of %s", from)
+	}
+	return types.NewMeteredMessage(from, to, nonce, value, method, params, gasPrice, gasLimit), nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/ipfs/go-cid"
 	format "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
@@ -36,19 +37,60 @@ type carHeader struct {
 	Version uint64          `cbor:"version"`
 }
 
+// ExportOptions selects the part of the chain written by Export.  The zero
+// value exports every tipset from the head back to genesis with its messages
+// and receipts, along with the genesis state.
+type ExportOptions struct {
+	// RecentTipSets, when non-zero, selects snapshot mode: headers are
+	// exported back to genesis but messages, receipts and state are only
+	// exported for this many of the most recent tipsets and for genesis.
+	RecentTipSets uint64
+	// FromHeight and ToHeight, when ToHeight is non-zero, select range mode:
+	// only tipsets with heights between FromHeight and ToHeight inclusive are
+	// exported, with their messages and receipts.  The state the lowest of
+	// them was computed on is exported so the range can be re-executed.
+	FromHeight uint64
+	ToHeight   uint64
+}
+
+func (opts ExportOptions) isRange() bool {
+	return opts.ToHeight != 0
+}
+
+func (opts ExportOptions) validate() error {
+	if opts.isRange() && opts.RecentTipSets != 0 {
+		return errors.New("snapshot and range exports are mutually exclusive")
+	}
+	if opts.FromHeight > opts.ToHeight {
+		return errors.Errorf("invalid export range: from height %d is above to height %d", opts.FromHeight, opts.ToHeight)
+	}
+	return nil
+}
+
 // Export will export a chain (all blocks and their messages) to the writer `out`.
-func Export(ctx context.Context, headTS block.TipSet, cr carChainReader, mr carMessageReader, sr carStateReader, out io.Writer) error {
-	// ensure we don't duplicate writes to the car file. // e.g. only write EmptyMessageCID once.
-	filter := make(map[cid.Cid]bool)
+// The part of the chain exported may be restricted with `opts`.
+func Export(ctx context.Context, headTS block.TipSet, cr carChainReader, mr carMessageReader, sr carStateReader, opts ExportOptions, out io.Writer) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
 
 	// fail if headTS isn't in the store.
 	if _, err := cr.GetTipSet(headTS.Key()); err != nil {
 		return err
 	}
 
+	root := headTS
+	if opts.isRange() {
+		var err error
+		root, err = findExportRangeTop(ctx, cr, headTS, opts)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Write the car header
 	ch := carHeader{
-		Roots:   headTS.Key(),
+		Roots:   root.Key(),
 		Version: 1,
 	}
 	chb, err := encoding.Encode(ch)
@@ -56,92 +98,187 @@ func Export(ctx context.Context, headTS block.TipSet, cr carChainReader, mr carM
 		return err
 	}
 
-	logCar.Debugf("car file chain head: %s", headTS.Key())
+	logCar.Debugf("car file chain head: %s", root.Key())
 	if err := carutil.LdWrite(out, chb); err != nil {
 		return err
 	}
 
-	iter := IterAncestors(ctx, cr, headTS)
+	exp := &carExporter{
+		out: out,
+		// ensure we don't duplicate writes to the car file. // e.g. only write EmptyMessageCID once.
+		filter: make(map[cid.Cid]bool),
+		mr:     mr,
+		sr:     sr,
+	}
+
+	var exported uint64
+	var last block.TipSet
+	iter := IterAncestors(ctx, cr, root)
 	// accumulate TipSets in descending order.
 	for ; !iter.Complete(); err = iter.Next() {
 		if err != nil {
 			return err
 		}
 		tip := iter.Value()
-		// write blocks
-		for i := 0; i < tip.Len(); i++ {
-			hdr := tip.At(i)
-			logCar.Debugf("writing block: %s", hdr.Cid())
-
-			if !filter[hdr.Cid()] {
-				if err := carutil.LdWrite(out, hdr.Cid().Bytes(), hdr.ToNode().RawData()); err != nil {
-					return err
-				}
-				filter[hdr.Cid()] = true
-			}
+		h, err := tip.Height()
+		if err != nil {
+			return err
+		}
+		if opts.isRange() && h < opts.FromHeight {
+			break
+		}
 
-			meta, err := mr.LoadTxMeta(ctx, hdr.Messages.Cid)
-			if err != nil {
+		recent := opts.RecentTipSets == 0 || exported < opts.RecentTipSets
+		withState := h == 0 || (opts.RecentTipSets != 0 && recent)
+		if err := exp.writeTipSet(ctx, tip, recent || h == 0, withState); err != nil {
+			return err
+		}
+		exported++
+		last = tip
+	}
+
+	if opts.isRange() {
+		// The lowest tipset of the range is executed on top of this state.
+		for i := 0; i < last.Len(); i++ {
+			if err := exp.writeState(ctx, last.At(i)); err != nil {
 				return err
 			}
+		}
+	}
+	return nil
+}
 
-			if !filter[hdr.Messages.Cid] {
-				logCar.Debugf("writing txMeta: %s", hdr.Messages)
-				if err := exportTxMeta(ctx, out, meta); err != nil {
-					return err
-				}
-				filter[hdr.Messages.Cid] = true
-			}
+// findExportRangeTop returns the highest tipset at or below the top of the
+// export range on the chain ending in `headTS`.
+func findExportRangeTop(ctx context.Context, cr carChainReader, headTS block.TipSet, opts ExportOptions) (block.TipSet, error) {
+	var err error
+	for iter := IterAncestors(ctx, cr, headTS); !iter.Complete(); err = iter.Next() {
+		if err != nil {
+			return block.UndefTipSet, err
+		}
+		h, err := iter.Value().Height()
+		if err != nil {
+			return block.UndefTipSet, err
+		}
+		if h < opts.FromHeight {
+			break
+		}
+		if h <= opts.ToHeight {
+			return iter.Value(), nil
+		}
+	}
+	return block.UndefTipSet, errors.Errorf("no tipsets between heights %d and %d under %s", opts.FromHeight, opts.ToHeight, headTS.String())
+}
 
-			secpMsgs, blsMsgs, err := mr.LoadMessages(ctx, hdr.Messages.Cid)
-			if err != nil {
-				return err
-			}
+// carExporter writes the parts of tipsets selected for export to a car file.
+type carExporter struct {
+	out    io.Writer
+	filter map[cid.Cid]bool
+	mr     carMessageReader
+	sr     carStateReader
+}
 
-			if !filter[meta.SecpRoot.Cid] {
-				logCar.Debugf("writing secp message collection: %s", hdr.Messages)
-				if err := exportAMTSignedMessages(ctx, out, secpMsgs); err != nil {
-					return err
-				}
-				filter[meta.SecpRoot.Cid] = true
-			}
+// writeTipSet writes the headers of `tip` and, as requested, their messages,
+// receipts and state.
+func (exp *carExporter) writeTipSet(ctx context.Context, tip block.TipSet, withMessages, withState bool) error {
+	// write blocks
+	for i := 0; i < tip.Len(); i++ {
+		hdr := tip.At(i)
+		logCar.Debugf("writing block: %s", hdr.Cid())
 
-			if !filter[meta.BLSRoot.Cid] {
-				logCar.Debugf("writing bls message collection: %s", hdr.Messages)
-				if err := exportAMTUnsignedMessages(ctx, out, blsMsgs); err != nil {
-					return err
-				}
-				filter[meta.BLSRoot.Cid] = true
+		if !exp.filter[hdr.Cid()] {
+			if err := carutil.LdWrite(exp.out, hdr.Cid().Bytes(), hdr.ToNode().RawData()); err != nil {
+				return err
 			}
+			exp.filter[hdr.Cid()] = true
+		}
 
-			// TODO(#3473) we can remove MessageReceipts from the exported file once addressed.
-			rect, err := mr.LoadReceipts(ctx, hdr.MessageReceipts.Cid)
-			if err != nil {
+		if withMessages {
+			if err := exp.writeMessages(ctx, hdr); err != nil {
 				return err
 			}
-
-			if !filter[hdr.MessageReceipts.Cid] {
-				logCar.Debugf("writing message-receipt collection: %s", hdr.Messages)
-				if err := exportAMTReceipts(ctx, out, rect); err != nil {
-					return err
-				}
-				filter[hdr.MessageReceipts.Cid] = true
+		}
+		if withState {
+			if err := exp.writeState(ctx, hdr); err != nil {
+				return err
 			}
+		}
+	}
+	return nil
+}
 
-			if hdr.Height == 0 {
-				logCar.Debugf("writing state tree: %s", hdr.StateRoot)
-				stateRoots, err := sr.ChainStateTree(ctx, hdr.StateRoot.Cid)
-				if err != nil {
-					return err
-				}
-				for _, r := range stateRoots {
-					if err := carutil.LdWrite(out, r.Cid().Bytes(), r.RawData()); err != nil {
-						return err
-					}
-				}
-			}
+// writeMessages writes the messages and receipts referenced by `hdr`.
+func (exp *carExporter) writeMessages(ctx context.Context, hdr *block.Block) error {
+	meta, err := exp.mr.LoadTxMeta(ctx, hdr.Messages.Cid)
+	if err != nil {
+		return err
+	}
+
+	if !exp.filter[hdr.Messages.Cid] {
+		logCar.Debugf("writing txMeta: %s", hdr.Messages)
+		if err := exportTxMeta(ctx, exp.out, meta); err != nil {
+			return err
+		}
+		exp.filter[hdr.Messages.Cid] = true
+	}
+
+	secpMsgs, blsMsgs, err := exp.mr.LoadMessages(ctx, hdr.Messages.Cid)
+	if err != nil {
+		return err
+	}
+
+	if !exp.filter[meta.SecpRoot.Cid] {
+		logCar.Debugf("writing secp message collection: %s", hdr.Messages)
+		if err := exportAMTSignedMessages(ctx, exp.out, secpMsgs); err != nil {
+			return err
+		}
+		exp.filter[meta.SecpRoot.Cid] = true
+	}
+
+	if !exp.filter[meta.BLSRoot.Cid] {
+		logCar.Debugf("writing bls message collection: %s", hdr.Messages)
+		if err := exportAMTUnsignedMessages(ctx, exp.out, blsMsgs); err != nil {
+			return err
+		}
+		exp.filter[meta.BLSRoot.Cid] = true
+	}
+
+	// TODO(#3473) we can remove MessageReceipts from the exported file once addressed.
+	if !exp.filter[hdr.MessageReceipts.Cid] {
+		rect, err := exp.mr.LoadReceipts(ctx, hdr.MessageReceipts.Cid)
+		if err != nil {
+			return err
+		}
+
+		logCar.Debugf("writing message-receipt collection: %s", hdr.Messages)
+		if err := exportAMTReceipts(ctx, exp.out, rect); err != nil {
+			return err
+		}
+		exp.filter[hdr.MessageReceipts.Cid] = true
+	}
+	return nil
+}
+
+// writeState writes the state tree referenced by `hdr`.
+func (exp *carExporter) writeState(ctx context.Context, hdr *block.Block) error {
+	if exp.filter[hdr.StateRoot.Cid] {
+		return nil
+	}
+	logCar.Debugf("writing state tree: %s", hdr.StateRoot)
+	stateRoots, err := exp.sr.ChainStateTree(ctx, hdr.StateRoot.Cid)
+	if err != nil {
+		return err
+	}
+	for _, r := range stateRoots {
+		if exp.filter[r.Cid()] {
+			continue
+		}
+		if err := carutil.LdWrite(exp.out, r.Cid().Bytes(), r.RawData()); err != nil {
+			return err
 		}
+		exp.filter[r.Cid()] = true
 	}
+	exp.filter[hdr.StateRoot.Cid] = true
 	return nil
 }
 
@@ -222,7 +222,7 @@ func TestChainImportExportMultiTipSetWithMessages(t *testing.T) {
 }
 
 func mustExportToBuffer(ctx context.Context, t *testing.T, head block.TipSet, cb *chain.Builder, msr *mockStateReader, carW *bufio.Writer) {
-	err := chain.Export(ctx, head, cb, cb, msr, carW)
+	err := chain.Export(ctx, head, cb, cb, msr, chain.ExportOptions{}, carW)
 	assert.NoError(t, err)
 	require.NoError(t, carW.Flush())
 }
@@ -321,3 +321,122 @@ type mockStateReader struct{}
 func (mr *mockStateReader) ChainStateTree(ctx context.Context, c cid.Cid) ([]format.Node, error) {
 	return nil, nil
 }
+
+func TestChainExportSnapshot(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, gene, cb, carW, carR, bstore := setupDeps(t)
+
+	keys := types.MustGenerateKeyInfo(1, 42)
+	mm := types.NewMessageMaker(t, keys)
+	alice := mm.Addresses()[0]
+
+	oldMsg := mm.NewSignedMessage(alice, 1)
+	ts1 := cb.BuildOneOn(gene, func(b *chain.BlockBuilder) {
+		b.AddMessages([]*types.SignedMessage{oldMsg}, []*types.UnsignedMessage{})
+	})
+	ts2 := cb.AppendOn(ts1, 2)
+	ts3 := cb.BuildOneOn(ts2, func(b *chain.BlockBuilder) {
+		b.AddMessages([]*types.SignedMessage{mm.NewSignedMessage(alice, 2)}, []*types.UnsignedMessage{})
+	})
+
+	msr := &recordingStateReader{}
+	require.NoError(t, chain.Export(ctx, ts3, cb, cb, msr, chain.ExportOptions{RecentTipSets: 1}, carW))
+	require.NoError(t, carW.Flush())
+
+	importedKey := mustImportFromBuffer(ctx, t, bstore, carR)
+	assert.Equal(t, ts3.Key(), importedKey)
+
+	// the most recent tipset has its messages, older ones only their headers.
+	validateBlockstoreImport(ctx, t, ts3.Key(), ts3.Key(), bstore)
+	for _, ts := range []block.TipSet{ts2, ts1, gene} {
+		requireHasTipSet(t, bstore, ts)
+	}
+	has, err := bstore.Has(ts1.At(0).Messages.Cid)
+	require.NoError(t, err)
+	assert.False(t, has)
+	oldMsgCid, err := oldMsg.Cid()
+	require.NoError(t, err)
+	has, err = bstore.Has(oldMsgCid)
+	require.NoError(t, err)
+	assert.False(t, has)
+
+	// state is exported for the recent tipset and genesis.
+	assert.Contains(t, msr.roots, ts3.At(0).StateRoot.Cid)
+	assert.Contains(t, msr.roots, gene.At(0).StateRoot.Cid)
+}
+
+func TestChainExportRange(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, gene, cb, carW, carR, bstore := setupDeps(t)
+
+	keys := types.MustGenerateKeyInfo(1, 42)
+	mm := types.NewMessageMaker(t, keys)
+	alice := mm.Addresses()[0]
+
+	ts1 := cb.AppendOn(gene, 1)
+	ts2 := cb.BuildOneOn(ts1, func(b *chain.BlockBuilder) {
+		b.AddMessages([]*types.SignedMessage{mm.NewSignedMessage(alice, 1)}, []*types.UnsignedMessage{})
+	})
+	ts3 := cb.AppendOn(ts2, 2)
+	ts4 := cb.AppendOn(ts3, 1)
+
+	msr := &recordingStateReader{}
+	require.NoError(t, chain.Export(ctx, ts4, cb, cb, msr, chain.ExportOptions{FromHeight: 2, ToHeight: 3}, carW))
+	require.NoError(t, carW.Flush())
+
+	importedKey := mustImportFromBuffer(ctx, t, bstore, carR)
+	assert.Equal(t, ts3.Key(), importedKey)
+
+	validateBlockstoreImport(ctx, t, ts3.Key(), ts2.Key(), bstore)
+	for _, ts := range []block.TipSet{ts4, ts1, gene} {
+		has, err := bstore.Has(ts.At(0).Cid())
+		require.NoError(t, err)
+		assert.False(t, has, "tipset %s should not be exported", ts.String())
+	}
+
+	// the state the range is executed on is exported.
+	assert.Contains(t, msr.roots, ts2.At(0).StateRoot.Cid)
+}
+
+func TestChainExportRangeOfNullRounds(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, gene, cb, carW, _, _ := setupDeps(t)
+	ts1 := cb.AppendOn(gene, 1)
+	ts4 := cb.BuildOn(ts1, 1, func(bb *chain.BlockBuilder, i int) { bb.IncHeight(2) })
+
+	err := chain.Export(ctx, ts4, cb, cb, &recordingStateReader{}, chain.ExportOptions{FromHeight: 2, ToHeight: 3}, carW)
+	assert.Error(t, err)
+}
+
+func TestChainExportInvalidOptions(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, gene, cb, carW, _, _ := setupDeps(t)
+	head := cb.AppendManyOn(3, gene)
+
+	err := chain.Export(ctx, head, cb, cb, &recordingStateReader{}, chain.ExportOptions{RecentTipSets: 1, FromHeight: 1, ToHeight: 2}, carW)
+	assert.Error(t, err)
+	err = chain.Export(ctx, head, cb, cb, &recordingStateReader{}, chain.ExportOptions{FromHeight: 3, ToHeight: 2}, carW)
+	assert.Error(t, err)
+}
+
+func requireHasTipSet(t *testing.T, bstore blockstore.Blockstore, ts block.TipSet) {
+	for i := 0; i < ts.Len(); i++ {
+		has, err := bstore.Has(ts.At(i).Cid())
+		require.NoError(t, err)
+		require.True(t, has, "missing block %s", ts.At(i).Cid())
+	}
+}
+
+// recordingStateReader records the state roots exported.
+type recordingStateReader struct {
+	roots []cid.Cid
+}
+
+func (mr *recordingStateReader) ChainStateTree(ctx context.Context, c cid.Cid) ([]format.Node, error) {
+	mr.roots = append(mr.roots, c)
+	return nil, nil
+}
@@ -0,0 +1,107 @@
+package chain
+
+import (
+	"context"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+)
+
+// CheckpointKey is the key at which the key of the operator checkpointed
+// tipset is written in the datastore.
+var CheckpointKey = datastore.NewKey("/chain/checkpoint")
+
+// GetCheckpoint returns the key of the checkpointed tipset.  It returns an
+// empty key if no checkpoint is set.
+func (store *Store) GetCheckpoint() (block.TipSetKey, error) {
+	bb, err := store.ds.Get(CheckpointKey)
+	if err == datastore.ErrNotFound {
+		return block.TipSetKey{}, nil
+	}
+	if err != nil {
+		return block.TipSetKey{}, errors.Wrap(err, "failed to read checkpoint")
+	}
+	var key block.TipSetKey
+	if err := encoding.Decode(bb, &key); err != nil {
+		return block.TipSetKey{}, errors.Wrap(err, "failed to decode checkpoint")
+	}
+	return key, nil
+}
+
+// SetCheckpoint checkpoints the tipset identified by `key`.  Chains that do
+// not include a checkpointed tipset are never synced or set as the head.  The
+// tipset must be on the chain ending in the current head.
+func (store *Store) SetCheckpoint(ctx context.Context, key block.TipSetKey) error {
+	ts, err := store.GetTipSet(key)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load checkpoint tipset %s", key)
+	}
+	head, err := store.GetTipSet(store.GetHead())
+	if err != nil {
+		return err
+	}
+	included, err := store.includesTipSet(ctx, head, ts)
+	if err != nil {
+		return err
+	}
+	if !included {
+		return errors.Errorf("tipset %s is not on the chain of head %s", key, head.String())
+	}
+
+	val, err := encoding.Encode(key)
+	if err != nil {
+		return err
+	}
+	logStore.Infof("setting checkpoint %s", key)
+	return store.ds.Put(CheckpointKey, val)
+}
+
+// ClearCheckpoint removes the checkpoint if one is set.
+func (store *Store) ClearCheckpoint() error {
+	logStore.Infof("clearing checkpoint")
+	err := store.ds.Delete(CheckpointKey)
+	if err == datastore.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// IncludesCheckpoint returns true iff the chain ending in `head` includes the
+// checkpointed tipset or no checkpoint is set.
+func (store *Store) IncludesCheckpoint(ctx context.Context, head block.TipSet) (bool, error) {
+	key, err := store.GetCheckpoint()
+	if err != nil {
+		return false, err
+	}
+	if key.Empty() {
+		return true, nil
+	}
+	checkpoint, err := store.GetTipSet(key)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to load checkpoint tipset %s", key)
+	}
+	return store.includesTipSet(ctx, head, checkpoint)
+}
+
+// includesTipSet returns true iff `ts` is on the chain ending in `head`.
+func (store *Store) includesTipSet(ctx context.Context, head, ts block.TipSet) (bool, error) {
+	h, err := ts.Height()
+	if err != nil {
+		return false, err
+	}
+	headHeight, err := head.Height()
+	if err != nil {
+		return false, err
+	}
+	if headHeight < h {
+		return false, nil
+	}
+	at, err := store.GetTipSetAtHeight(ctx, head, h)
+	if err != nil {
+		return false, err
+	}
+	return at.Equals(ts), nil
+}
@@ -0,0 +1,71 @@
+package chain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func requireIncludesCheckpoint(ctx context.Context, t *testing.T, cs *chain.Store, head block.TipSet) bool {
+	included, err := cs.IncludesCheckpoint(ctx, head)
+	require.NoError(t, err)
+	return included
+}
+
+func TestCheckpoint(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	cs := newChainStore(r, genTS.At(0).Cid())
+
+	// genesis -> link1 -> link2 -> link3
+	//                  \-> fork2 -> fork3
+	link1 := builder.AppendOn(genTS, 1)
+	link2 := builder.AppendOn(link1, 2)
+	link3 := builder.AppendOn(link2, 1)
+	fork2 := builder.AppendOn(link1, 1)
+	fork3 := builder.AppendOn(fork2, 1)
+	requirePutTestChain(ctx, t, cs, link3.Key(), builder, 4)
+	requirePutTestChain(ctx, t, cs, fork3.Key(), builder, 2)
+	assertSetHead(t, cs, link3)
+
+	// Without a checkpoint every chain is acceptable.
+	cp, err := cs.GetCheckpoint()
+	require.NoError(t, err)
+	assert.True(t, cp.Empty())
+	assert.True(t, requireIncludesCheckpoint(ctx, t, cs, fork3))
+
+	// Tipsets off the head chain cannot be checkpointed.
+	assert.Error(t, cs.SetCheckpoint(ctx, fork2.Key()))
+
+	require.NoError(t, cs.SetCheckpoint(ctx, link2.Key()))
+	cp, err = cs.GetCheckpoint()
+	require.NoError(t, err)
+	assert.Equal(t, link2.Key(), cp)
+
+	assert.True(t, requireIncludesCheckpoint(ctx, t, cs, link3))
+	assert.True(t, requireIncludesCheckpoint(ctx, t, cs, link2))
+	assert.False(t, requireIncludesCheckpoint(ctx, t, cs, link1))
+	assert.False(t, requireIncludesCheckpoint(ctx, t, cs, fork3))
+
+	// The checkpoint is persisted.
+	rebooted := newChainStore(r, genTS.At(0).Cid())
+	cp, err = rebooted.GetCheckpoint()
+	require.NoError(t, err)
+	assert.Equal(t, link2.Key(), cp)
+
+	require.NoError(t, cs.ClearCheckpoint())
+	require.NoError(t, cs.ClearCheckpoint())
+	assert.True(t, requireIncludesCheckpoint(ctx, t, cs, fork3))
+}
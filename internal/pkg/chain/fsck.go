@@ -0,0 +1,172 @@
+package chain
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+)
+
+// Kinds of chain data checked by Fsck.
+const (
+	// FsckHeader is a block header that is missing or does not decode.
+	FsckHeader = "header"
+	// FsckMessages is a block's TxMeta or message AMT that is missing or
+	// does not decode.
+	FsckMessages = "messages"
+	// FsckMetadata is tipset metadata that is missing or disagrees with the
+	// headers of the tipset's child.
+	FsckMetadata = "metadata"
+	// FsckReceipts is a tipset's receipt AMT that is missing or does not
+	// decode.
+	FsckReceipts = "receipts"
+	// FsckState is a tipset's state root that is missing or does not decode.
+	FsckState = "state"
+)
+
+// FsckProblem is a piece of chain data found missing or corrupt by Fsck.
+type FsckProblem struct {
+	// TipSet is the key of the tipset the data belongs to.
+	TipSet block.TipSetKey
+	// Height is the height of the tipset, zero if none of its headers load.
+	Height uint64
+	// Kind is one of FsckHeader, FsckMessages, FsckMetadata, FsckReceipts or
+	// FsckState.
+	Kind string
+	// Cid is the CID of the missing or corrupt object, if there is one.
+	Cid cid.Cid
+	// Error describes the problem.
+	Error string
+}
+
+// FsckReport is the result of checking the chain.
+type FsckReport struct {
+	// TipSetsChecked is the number of tipsets checked.
+	TipSetsChecked uint64
+	// Problems are the problems found, ordered by decreasing height.
+	Problems []*FsckProblem
+	// Truncated is true if the check stopped above the requested depth
+	// because the headers of a tipset are missing.
+	Truncated bool
+	// PrunedHeight is the height below which the state and receipts of
+	// tipsets other than genesis were pruned and are not checked.
+	PrunedHeight uint64
+	// Repaired is true if all the problems were repaired.
+	Repaired bool
+}
+
+// Consistent returns true iff no problem was found.
+func (r *FsckReport) Consistent() bool {
+	return len(r.Problems) == 0
+}
+
+// Fsck checks the chain from the head down to genesis, or only its `depth`
+// most recent tipsets if `depth` is not zero.  Every block header, TxMeta,
+// message AMT, receipt AMT and state root must be present and decode, and
+// the persisted metadata of each tipset must agree with the state root and
+// receipts recorded in the headers of its child.  State and receipts pruned
+// by the StatePruner are not expected to be present.
+func (store *Store) Fsck(ctx context.Context, messages MessageProvider, depth uint64) (*FsckReport, error) {
+	prunedHeight, err := store.PrunedHeight()
+	if err != nil {
+		return nil, err
+	}
+	report := &FsckReport{PrunedHeight: prunedHeight}
+	key := store.GetHead()
+	var child block.TipSet
+	for !key.Empty() && (depth == 0 || report.TipSetsChecked < depth) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var blocks []*block.Block
+		var missing []cid.Cid
+		var errs []error
+		for _, c := range key.ToSlice() {
+			blk, err := store.stateAndBlockSource.GetBlock(ctx, c)
+			if err != nil {
+				missing = append(missing, c)
+				errs = append(errs, err)
+				continue
+			}
+			blocks = append(blocks, blk)
+		}
+		report.TipSetsChecked++
+		height := uint64(0)
+		if len(blocks) > 0 {
+			height = blocks[0].Height
+		}
+		for i, c := range missing {
+			report.Problems = append(report.Problems, &FsckProblem{TipSet: key, Height: height, Kind: FsckHeader, Cid: c, Error: errs[i].Error()})
+		}
+		if len(blocks) == 0 {
+			report.Truncated = true
+			return report, nil
+		}
+
+		for _, blk := range blocks {
+			if _, _, err := messages.LoadMessages(ctx, blk.Messages.Cid); err != nil {
+				report.Problems = append(report.Problems, &FsckProblem{TipSet: key, Height: height, Kind: FsckMessages, Cid: blk.Messages.Cid, Error: err.Error()})
+			}
+		}
+
+		if len(blocks) == key.Len() {
+			ts, err := block.NewTipSet(blocks...)
+			if err != nil {
+				return nil, err
+			}
+			pruned := height != 0 && height < prunedHeight
+			report.Problems = append(report.Problems, store.fsckMetadata(ctx, messages, ts, child, pruned)...)
+			child = ts
+		} else {
+			// The metadata of an incomplete tipset cannot be found, nor can its
+			// parent's be checked against it.
+			child = block.UndefTipSet
+		}
+		key = blocks[0].Parents
+	}
+	return report, nil
+}
+
+// fsckMetadata checks the metadata, receipts and state root of `ts` and that
+// they agree with the headers of its child `child` if it is defined.  The
+// receipts and state root are not loaded if they were `pruned`.
+func (store *Store) fsckMetadata(ctx context.Context, messages MessageProvider, ts, child block.TipSet, pruned bool) []*FsckProblem {
+	h, _ := ts.Height()
+	problem := func(kind string, c cid.Cid, err error) *FsckProblem {
+		return &FsckProblem{TipSet: ts.Key(), Height: h, Kind: kind, Cid: c, Error: err.Error()}
+	}
+
+	stateRoot, receipts, err := store.loadStateRootAndReceipts(ts)
+	if err != nil {
+		return []*FsckProblem{problem(FsckMetadata, cid.Undef, err)}
+	}
+	var problems []*FsckProblem
+	if child.Defined() {
+		if !child.At(0).StateRoot.Equals(stateRoot) {
+			problems = append(problems, problem(FsckMetadata, cid.Undef,
+				errors.Errorf("state root %s differs from %s in child headers", stateRoot, child.At(0).StateRoot)))
+		}
+		if !child.At(0).MessageReceipts.Equals(receipts) {
+			problems = append(problems, problem(FsckMetadata, cid.Undef,
+				errors.Errorf("receipts %s differ from %s in child headers", receipts, child.At(0).MessageReceipts)))
+		}
+	}
+	if pruned {
+		return problems
+	}
+	if _, err := messages.LoadReceipts(ctx, receipts); err != nil {
+		problems = append(problems, problem(FsckReceipts, receipts, err))
+	}
+	if _, err := store.stateTreeLoader.LoadStateTree(ctx, store.stateAndBlockSource.cborStore, stateRoot); err != nil {
+		problems = append(problems, problem(FsckState, stateRoot, err))
+	}
+	return problems
+}
+
+// PrunedHeight returns the height below which the state and receipts of
+// tipsets other than genesis were pruned, zero if they never were.
+func (store *Store) PrunedHeight() (uint64, error) {
+	return loadPrunedHeight(store.ds)
+}
@@ -0,0 +1,134 @@
+package chain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+// fakeTreeLoader loads an empty state tree for any root not marked missing.
+type fakeTreeLoader struct {
+	missing map[cid.Cid]struct{}
+}
+
+func (l *fakeTreeLoader) LoadStateTree(ctx context.Context, store cbor.IpldStore, c cid.Cid) (state.Tree, error) {
+	if _, ok := l.missing[c]; ok {
+		return nil, errors.Errorf("state %s not found", c)
+	}
+	return state.NewTree(store), nil
+}
+
+// newFsckChainStore puts a chain of `length` tipsets above genesis into a
+// new store and sets the head.  The metadata of each tipset agrees with the
+// headers of its child.
+func newFsckChainStore(ctx context.Context, t *testing.T, ds repo.Datastore, builder *chain.Builder, loader state.TreeLoader, length int) (*chain.Store, []block.TipSet) {
+	genTS := builder.NewGenesis()
+	// Each tipset has a message so that their state roots differ.
+	mm := types.NewMessageMaker(t, types.MustGenerateKeyInfo(1, 42))
+	alice := mm.Addresses()[0]
+	head := genTS
+	for i := 0; i < length; i++ {
+		head = builder.BuildOneOn(head, func(b *chain.BlockBuilder) {
+			b.AddMessages([]*types.SignedMessage{mm.NewSignedMessage(alice, uint64(i))}, []*types.UnsignedMessage{})
+		})
+	}
+	tss := builder.RequireTipSets(head.Key(), length+1)
+
+	receipts, err := builder.StoreReceipts(ctx, []*types.MessageReceipt{})
+	require.NoError(t, err)
+	require.Equal(t, types.EmptyReceiptsCID, receipts)
+
+	cst := cbor.NewMemCborStore()
+	cs := chain.NewStore(ds, cst, loader, chain.NewStatusReporter(), genTS.At(0).Cid())
+	for i, ts := range tss {
+		requirePutBlocksToCborStore(t, cst, ts.ToSlice()...)
+		stateRoot := ts.At(0).StateRoot.Cid
+		if i > 0 {
+			stateRoot = tss[i-1].At(0).StateRoot.Cid
+		}
+		require.NoError(t, cs.PutTipSetMetadata(ctx, &chain.TipSetMetadata{
+			TipSet:          ts,
+			TipSetStateRoot: stateRoot,
+			TipSetReceipts:  receipts,
+		}))
+	}
+	assertSetHead(t, cs, head)
+	return cs, tss
+}
+
+func TestFsck(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+
+	t.Run("consistent chain", func(t *testing.T) {
+		builder := chain.NewBuilder(t, address.Undef)
+		cs, _ := newFsckChainStore(ctx, t, repo.NewInMemoryRepo().ChainDatastore(), builder, &fakeTreeLoader{}, 5)
+
+		report, err := cs.Fsck(ctx, builder, 0)
+		require.NoError(t, err)
+		assert.True(t, report.Consistent())
+		assert.False(t, report.Truncated)
+		assert.Equal(t, uint64(6), report.TipSetsChecked)
+
+		report, err = cs.Fsck(ctx, builder, 2)
+		require.NoError(t, err)
+		assert.True(t, report.Consistent())
+		assert.Equal(t, uint64(2), report.TipSetsChecked)
+	})
+
+	t.Run("missing state", func(t *testing.T) {
+		builder := chain.NewBuilder(t, address.Undef)
+		loader := &fakeTreeLoader{missing: make(map[cid.Cid]struct{})}
+		cs, tss := newFsckChainStore(ctx, t, repo.NewInMemoryRepo().ChainDatastore(), builder, loader, 5)
+		// The state of tss[3] is the state root in the headers of tss[2].
+		missingRoot := tss[2].At(0).StateRoot.Cid
+		loader.missing[missingRoot] = struct{}{}
+
+		report, err := cs.Fsck(ctx, builder, 0)
+		require.NoError(t, err)
+		require.Len(t, report.Problems, 1)
+		problem := report.Problems[0]
+		assert.Equal(t, chain.FsckState, problem.Kind)
+		assert.Equal(t, tss[3].Key(), problem.TipSet)
+		assert.Equal(t, missingRoot, problem.Cid)
+		assert.Equal(t, uint64(6), report.TipSetsChecked)
+
+		// The problem is below the checked depth.
+		report, err = cs.Fsck(ctx, builder, 3)
+		require.NoError(t, err)
+		assert.True(t, report.Consistent())
+	})
+
+	t.Run("pruned state", func(t *testing.T) {
+		builder := chain.NewBuilder(t, address.Undef)
+		ds := repo.NewInMemoryRepo().ChainDatastore()
+		loader := &fakeTreeLoader{missing: make(map[cid.Cid]struct{})}
+		cs, tss := newFsckChainStore(ctx, t, ds, builder, loader, 5)
+		// The state of tss[3], at height 2, is pruned.
+		loader.missing[tss[2].At(0).StateRoot.Cid] = struct{}{}
+		prunedHeight, err := encoding.Encode(uint64(3))
+		require.NoError(t, err)
+		require.NoError(t, ds.Put(chain.PrunedHeightKey, prunedHeight))
+
+		report, err := cs.Fsck(ctx, builder, 0)
+		require.NoError(t, err)
+		assert.True(t, report.Consistent())
+		assert.Equal(t, uint64(3), report.PrunedHeight)
+		assert.Equal(t, uint64(6), report.TipSetsChecked)
+	})
+}
@@ -0,0 +1,230 @@
+package chain
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+)
+
+// HeadChangeBufferSize is the number of head changes buffered for each
+// subscriber.  A subscriber that falls further behind is dropped.
+const HeadChangeBufferSize = 16
+
+// ErrHeadChangeSubscriberTooSlow is the error of a subscription dropped because
+// it did not keep up with head changes.
+var ErrHeadChangeSubscriberTooSlow = errors.New("head change subscriber too slow")
+
+// ErrHeadChangeMissed is the error of a subscription dropped because the
+// store could not determine a head change.
+var ErrHeadChangeMissed = errors.New("failed to determine head change")
+
+// ErrStoreStopped is the error of a subscription dropped because the store
+// stopped.
+var ErrStoreStopped = errors.New("chain store stopped")
+
+// HeadChange describes the tipsets leaving and joining the head chain when
+// the head changes.  Reverting all tipsets in Revert and then applying all
+// tipsets in Apply moves a consumer from the old head to the new head.
+type HeadChange struct {
+	// Revert are the tipsets of the old head chain above the common ancestor
+	// of the old and new heads, ordered by decreasing height from the old head.
+	Revert []block.TipSet
+	// Apply are the tipsets of the new head chain above the common ancestor
+	// of the old and new heads, ordered by increasing height to the new head.
+	Apply []block.TipSet
+}
+
+// HeadChangeSubscription delivers head changes in the order they happen.
+// The first change delivered applies the head at the time of subscription
+// alone.  The channel of changes is closed when the subscription ends, after
+// which Err reports why.  A subscriber that misses a change is always dropped
+// so consumers that resubscribe never silently skip a reorg.
+type HeadChangeSubscription struct {
+	ch       chan *HeadChange
+	done     chan struct{}
+	doneOnce sync.Once
+	notifier *headChangeNotifier
+
+	// err is written before ch is closed.
+	err error
+}
+
+// Changes returns the channel of head changes.
+func (s *HeadChangeSubscription) Changes() <-chan *HeadChange {
+	return s.ch
+}
+
+// Err returns the reason the subscription ended.  It returns nil while the
+// subscription is active or if the subscriber cancelled it.
+func (s *HeadChangeSubscription) Err() error {
+	s.notifier.mu.Lock()
+	defer s.notifier.mu.Unlock()
+	return s.err
+}
+
+// Unsubscribe ends the subscription.
+func (s *HeadChangeSubscription) Unsubscribe() {
+	s.notifier.drop(s)
+}
+
+// headChangeNotifier fans head changes out to subscribers.  Changes are
+// queued in a bounded buffer for each subscriber.  Publishing never blocks:
+// head changes are published on the path setting the head, so a subscriber
+// whose buffer is full is dropped rather than holding up the store.
+type headChangeNotifier struct {
+	// mu protects subs and stopped and is held while sending to and closing
+	// subscriber channels.
+	mu      sync.Mutex
+	subs    map[*HeadChangeSubscription]struct{}
+	stopped bool
+}
+
+func newHeadChangeNotifier() *headChangeNotifier {
+	return &headChangeNotifier{
+		subs: make(map[*HeadChangeSubscription]struct{}),
+	}
+}
+
+// subscribe adds a subscriber whose first change is `first`.  The
+// subscription ends when `ctx` is done.
+func (n *headChangeNotifier) subscribe(ctx context.Context, first *HeadChange) *HeadChangeSubscription {
+	sub := &HeadChangeSubscription{
+		ch:       make(chan *HeadChange, HeadChangeBufferSize),
+		done:     make(chan struct{}),
+		notifier: n,
+	}
+	n.mu.Lock()
+	if n.stopped {
+		sub.err = ErrStoreStopped
+		close(sub.done)
+		close(sub.ch)
+		n.mu.Unlock()
+		return sub
+	}
+	if first != nil {
+		sub.ch <- first
+	}
+	n.subs[sub] = struct{}{}
+	n.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			n.drop(sub)
+		case <-sub.done:
+		}
+	}()
+	return sub
+}
+
+// drop ends a subscription cancelled by the subscriber.
+func (n *headChangeNotifier) drop(sub *HeadChangeSubscription) {
+	sub.doneOnce.Do(func() { close(sub.done) })
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.remove(sub, nil)
+}
+
+// remove deletes a subscriber and closes its channel.  It must be called with
+// mu held.
+func (n *headChangeNotifier) remove(sub *HeadChangeSubscription, err error) {
+	if _, ok := n.subs[sub]; !ok {
+		return
+	}
+	delete(n.subs, sub)
+	sub.doneOnce.Do(func() { close(sub.done) })
+	sub.err = err
+	close(sub.ch)
+}
+
+// publish delivers `change` to all subscribers.  Subscribers with a full
+// buffer are dropped with ErrHeadChangeSubscriberTooSlow.
+func (n *headChangeNotifier) publish(change *HeadChange) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for sub := range n.subs {
+		select {
+		case sub.ch <- change:
+		default:
+			logStore.Warnf("dropping head change subscriber %d changes behind", HeadChangeBufferSize)
+			n.remove(sub, ErrHeadChangeSubscriberTooSlow)
+		}
+	}
+}
+
+// dropAll ends all subscriptions with `err`.
+func (n *headChangeNotifier) dropAll(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.removeAll(err)
+}
+
+func (n *headChangeNotifier) removeAll(err error) {
+	for sub := range n.subs {
+		n.remove(sub, err)
+	}
+}
+
+// stop ends all subscriptions and refuses new ones.
+func (n *headChangeNotifier) stop() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.removeAll(ErrStoreStopped)
+	n.stopped = true
+}
+
+// SubscribeHeadChanges subscribes to changes of the head until `ctx` is done
+// or the subscription is cancelled.
+//
+// Head changes are not held back for slow subscribers.  A subscriber more than
+// HeadChangeBufferSize changes behind is dropped: its channel is closed and
+// Err returns ErrHeadChangeSubscriberTooSlow.  A dropped subscriber should
+// resubscribe, and treat the first change of the new subscription, which
+// applies the current head alone, as a reset of its view of the chain.
+func (store *Store) SubscribeHeadChanges(ctx context.Context) *HeadChangeSubscription {
+	// Holding headChangeMu keeps the head from changing until the subscriber
+	// is registered, so no change is lost between the two.
+	store.headChangeMu.Lock()
+	defer store.headChangeMu.Unlock()
+
+	var first *HeadChange
+	store.mu.RLock()
+	if store.head.Defined() {
+		first = &HeadChange{Apply: []block.TipSet{store.head}}
+	}
+	store.mu.RUnlock()
+	return store.headChanges.subscribe(ctx, first)
+}
+
+// publishHeadChange notifies subscribers of the head moving from `oldHead` to
+// `newHead`.  If the change cannot be determined all subscribers are dropped,
+// rather than delivering an incomplete change.
+func (store *Store) publishHeadChange(ctx context.Context, oldHead, newHead block.TipSet) {
+	change, err := store.headChange(ctx, oldHead, newHead)
+	if err != nil {
+		logStore.Errorf("failed to determine head change from %s to %s: %s", oldHead.String(), newHead.String(), err)
+		store.headChanges.dropAll(ErrHeadChangeMissed)
+		return
+	}
+	store.headChanges.publish(change)
+}
+
+func (store *Store) headChange(ctx context.Context, oldHead, newHead block.TipSet) (*HeadChange, error) {
+	if !oldHead.Defined() {
+		return &HeadChange{Apply: []block.TipSet{newHead}}, nil
+	}
+	oldTips, newTips, err := CollectTipsToCommonAncestor(ctx, store, oldHead, newHead)
+	if err != nil {
+		return nil, err
+	}
+	apply := make([]block.TipSet, len(newTips))
+	for i, ts := range newTips {
+		apply[len(newTips)-1-i] = ts
+	}
+	return &HeadChange{Revert: oldTips, Apply: apply}, nil
+}
@@ -0,0 +1,165 @@
+package chain_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func requireNextHeadChange(t *testing.T, sub *chain.HeadChangeSubscription) *chain.HeadChange {
+	select {
+	case change, ok := <-sub.Changes():
+		require.True(t, ok, "subscription ended: %v", sub.Err())
+		return change
+	case <-time.After(time.Second):
+		require.Fail(t, "no head change")
+		return nil
+	}
+}
+
+func requireSubscriptionEnded(t *testing.T, sub *chain.HeadChangeSubscription) {
+	for {
+		select {
+		case _, ok := <-sub.Changes():
+			if !ok {
+				return
+			}
+		case <-time.After(time.Second):
+			require.Fail(t, "subscription did not end")
+		}
+	}
+}
+
+func TestHeadChanges(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	cs := newChainStore(r, genTS.At(0).Cid())
+
+	// genesis -> link1 -> link2 -> link3
+	//                  \-> fork2 -> fork3 -> fork4
+	link1 := builder.AppendOn(genTS, 1)
+	link2 := builder.AppendOn(link1, 2)
+	link3 := builder.AppendOn(link2, 1)
+	fork2 := builder.AppendOn(link1, 1)
+	fork3 := builder.AppendOn(fork2, 1)
+	fork4 := builder.AppendOn(fork3, 1)
+	requirePutTestChain(ctx, t, cs, link3.Key(), builder, 4)
+	requirePutTestChain(ctx, t, cs, fork4.Key(), builder, 3)
+	assertSetHead(t, cs, link1)
+
+	sub := cs.SubscribeHeadChanges(ctx)
+	defer sub.Unsubscribe()
+
+	// The first change applies the current head.
+	change := requireNextHeadChange(t, sub)
+	assert.Empty(t, change.Revert)
+	assert.Equal(t, []block.TipSet{link1}, change.Apply)
+
+	assertSetHead(t, cs, link3)
+	change = requireNextHeadChange(t, sub)
+	assert.Empty(t, change.Revert)
+	assert.Equal(t, []block.TipSet{link2, link3}, change.Apply)
+
+	// Reverts are ordered from the old head down, applies up to the new head.
+	assertSetHead(t, cs, fork4)
+	change = requireNextHeadChange(t, sub)
+	assert.Equal(t, []block.TipSet{link3, link2}, change.Revert)
+	assert.Equal(t, []block.TipSet{fork2, fork3, fork4}, change.Apply)
+
+	// Moving back down only reverts.
+	assertSetHead(t, cs, fork2)
+	change = requireNextHeadChange(t, sub)
+	assert.Equal(t, []block.TipSet{fork4, fork3}, change.Revert)
+	assert.Empty(t, change.Apply)
+
+	// Setting the same head again is not a change.
+	assertSetHead(t, cs, fork2)
+	select {
+	case change := <-sub.Changes():
+		assert.Fail(t, "unexpected head change", "%v", change)
+	default:
+	}
+}
+
+func TestHeadChangesSubscriptionEnds(t *testing.T) {
+	tf.UnitTest(t)
+
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		cs := newChainStore(repo.NewInMemoryRepo(), genTS.At(0).Cid())
+		sub := cs.SubscribeHeadChanges(context.Background())
+		sub.Unsubscribe()
+		requireSubscriptionEnded(t, sub)
+		assert.NoError(t, sub.Err())
+	})
+
+	t.Run("context cancelled", func(t *testing.T) {
+		cs := newChainStore(repo.NewInMemoryRepo(), genTS.At(0).Cid())
+		ctx, cancel := context.WithCancel(context.Background())
+		sub := cs.SubscribeHeadChanges(ctx)
+		cancel()
+		requireSubscriptionEnded(t, sub)
+	})
+
+	t.Run("store stopped", func(t *testing.T) {
+		cs := newChainStore(repo.NewInMemoryRepo(), genTS.At(0).Cid())
+		sub := cs.SubscribeHeadChanges(context.Background())
+		cs.Stop()
+		requireSubscriptionEnded(t, sub)
+		assert.Equal(t, chain.ErrStoreStopped, sub.Err())
+
+		sub = cs.SubscribeHeadChanges(context.Background())
+		requireSubscriptionEnded(t, sub)
+		assert.Equal(t, chain.ErrStoreStopped, sub.Err())
+	})
+}
+
+func TestHeadChangesSlowSubscriber(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	cs := newChainStore(r, genTS.At(0).Cid())
+	count := chain.HeadChangeBufferSize + 1
+	head := builder.AppendManyOn(count, genTS)
+	requirePutTestChain(ctx, t, cs, head.Key(), builder, count+1)
+	assertSetHead(t, cs, genTS)
+
+	slow := cs.SubscribeHeadChanges(ctx)
+	fast := cs.SubscribeHeadChanges(ctx)
+	defer fast.Unsubscribe()
+	requireNextHeadChange(t, fast)
+
+	// The slow subscriber's buffer fills with the initial change and the
+	// next ones, then it is dropped rather than blocking the store.
+	tips := builder.RequireTipSets(head.Key(), count)
+	for i := len(tips) - 1; i >= 0; i-- {
+		assertSetHead(t, cs, tips[i])
+		change := requireNextHeadChange(t, fast)
+		assert.Equal(t, []block.TipSet{tips[i]}, change.Apply)
+	}
+
+	received := 0
+	for range slow.Changes() {
+		received++
+	}
+	assert.Equal(t, chain.HeadChangeBufferSize, received)
+	assert.Equal(t, chain.ErrHeadChangeSubscriberTooSlow, slow.Err())
+}
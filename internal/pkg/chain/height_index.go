@@ -0,0 +1,234 @@
+package chain
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// HeightIndexHeadKey is the key at which the key of the tipset heading the
+// height index is written in the datastore.
+var HeightIndexHeadKey = datastore.NewKey("/chain/heightIndexHead")
+
+// heightIndexPrefix is the datastore namespace under which the height index
+// maps a chain height to the key of the tipset at that height.  Heights of
+// null rounds have no entry.
+var heightIndexPrefix = datastore.NewKey("/chain/height")
+
+func heightIndexKey(h uint64) datastore.Key {
+	return heightIndexPrefix.ChildString(strconv.FormatUint(h, 10))
+}
+
+// GetTipSetAtHeight returns the tipset at height `h` on the chain ending in
+// `head`.  If `h` is a null round the highest tipset below `h` is returned.
+// Lookups are served from the persisted height index when `head` is on the
+// indexed chain and fall back to walking back from `head` otherwise.
+func (store *Store) GetTipSetAtHeight(ctx context.Context, head block.TipSet, h uint64) (block.TipSet, error) {
+	headHeight, err := head.Height()
+	if err != nil {
+		return block.UndefTipSet, err
+	}
+	if h > headHeight {
+		return block.UndefTipSet, errors.Errorf("height %d is above head height %d", h, headHeight)
+	}
+
+	indexed, err := store.isHeightIndexed(head, headHeight)
+	if err != nil {
+		return block.UndefTipSet, err
+	}
+	if indexed {
+		for height := h; ; height-- {
+			key, found, err := store.loadHeightIndexEntry(height)
+			if err != nil {
+				return block.UndefTipSet, err
+			}
+			if found {
+				return store.GetTipSet(key)
+			}
+			if height == 0 {
+				return block.UndefTipSet, errors.Errorf("height index has no tipset at or below height %d", h)
+			}
+		}
+	}
+
+	logStore.Debugf("tipset %s not height indexed, walking back to height %d", head.String(), h)
+	for iter := IterAncestors(ctx, store, head); !iter.Complete(); err = iter.Next() {
+		if err != nil {
+			return block.UndefTipSet, err
+		}
+		height, err := iter.Value().Height()
+		if err != nil {
+			return block.UndefTipSet, err
+		}
+		if height <= h {
+			return iter.Value(), nil
+		}
+	}
+	return block.UndefTipSet, errors.Errorf("no tipset at or below height %d under %s", h, head.String())
+}
+
+// isHeightIndexed returns true iff `head` is a member of the indexed chain.
+// The index always describes a single chain so all ancestors of an indexed
+// tipset are indexed too.
+func (store *Store) isHeightIndexed(head block.TipSet, headHeight uint64) (bool, error) {
+	key, found, err := store.loadHeightIndexEntry(headHeight)
+	if err != nil || !found {
+		return false, err
+	}
+	return key.Equals(head.Key()), nil
+}
+
+// updateHeightIndex moves the height index from the previously indexed chain
+// onto the chain ending in `newHead`.  Entries above the common ancestor of
+// the two chains are removed and replaced by those of the new chain, so the
+// work done is proportional to the depth of the reorg.
+func (store *Store) updateHeightIndex(ctx context.Context, newHead block.TipSet) error {
+	oldHeadKey, err := store.loadHeightIndexHead()
+	if err == datastore.ErrNotFound {
+		return store.rebuildHeightIndex(ctx, newHead)
+	}
+	if err != nil {
+		return err
+	}
+	if oldHeadKey.Equals(newHead.Key()) {
+		return nil
+	}
+
+	oldHead, err := store.GetTipSet(oldHeadKey)
+	if err != nil {
+		// The previously indexed head is unknown to the tip index, e.g. it
+		// was on a fork that was not reloaded at startup.
+		return store.rebuildHeightIndex(ctx, newHead)
+	}
+	oldTips, newTips, err := CollectTipsToCommonAncestor(ctx, store, oldHead, newHead)
+	if err != nil {
+		return err
+	}
+
+	batch, err := store.ds.Batch()
+	if err != nil {
+		return err
+	}
+	written, err := putHeightIndexEntries(batch, newTips)
+	if err != nil {
+		return err
+	}
+	for _, ts := range oldTips {
+		h, err := ts.Height()
+		if err != nil {
+			return err
+		}
+		if written[h] {
+			continue
+		}
+		if err := batch.Delete(heightIndexKey(h)); err != nil {
+			return err
+		}
+	}
+	if err := putHeightIndexHead(batch, newHead.Key()); err != nil {
+		return err
+	}
+	return batch.Commit()
+}
+
+// rebuildHeightIndex drops all height index entries and indexes the whole
+// chain from `head` back to genesis.
+func (store *Store) rebuildHeightIndex(ctx context.Context, head block.TipSet) error {
+	logStore.Infof("rebuilding height index from %s", head.String())
+	tips, err := CollectTipSetsOfHeightAtLeast(ctx, IterAncestors(ctx, store, head), types.NewBlockHeight(0))
+	if err != nil {
+		return err
+	}
+
+	res, err := store.ds.Query(query.Query{Prefix: heightIndexPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return err
+	}
+
+	batch, err := store.ds.Batch()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := batch.Delete(datastore.NewKey(entry.Key)); err != nil {
+			return err
+		}
+	}
+	if _, err := putHeightIndexEntries(batch, tips); err != nil {
+		return err
+	}
+	if err := putHeightIndexHead(batch, head.Key()); err != nil {
+		return err
+	}
+	return batch.Commit()
+}
+
+// putHeightIndexEntries writes an entry for each tipset to the batch and
+// returns the set of heights written.
+func putHeightIndexEntries(batch datastore.Batch, tips []block.TipSet) (map[uint64]bool, error) {
+	written := make(map[uint64]bool, len(tips))
+	for _, ts := range tips {
+		h, err := ts.Height()
+		if err != nil {
+			return nil, err
+		}
+		val, err := encoding.Encode(ts.Key())
+		if err != nil {
+			return nil, err
+		}
+		if err := batch.Put(heightIndexKey(h), val); err != nil {
+			return nil, err
+		}
+		written[h] = true
+	}
+	return written, nil
+}
+
+func putHeightIndexHead(batch datastore.Batch, key block.TipSetKey) error {
+	val, err := encoding.Encode(key)
+	if err != nil {
+		return err
+	}
+	return batch.Put(HeightIndexHeadKey, val)
+}
+
+// loadHeightIndexHead loads the key of the tipset heading the indexed chain.
+func (store *Store) loadHeightIndexHead() (block.TipSetKey, error) {
+	bb, err := store.ds.Get(HeightIndexHeadKey)
+	if err != nil {
+		return block.TipSetKey{}, err
+	}
+	var key block.TipSetKey
+	if err := encoding.Decode(bb, &key); err != nil {
+		return block.TipSetKey{}, errors.Wrap(err, "failed to decode height index head")
+	}
+	return key, nil
+}
+
+// loadHeightIndexEntry loads the key of the indexed tipset at height `h`.
+// It returns false if `h` is not indexed.
+func (store *Store) loadHeightIndexEntry(h uint64) (block.TipSetKey, bool, error) {
+	bb, err := store.ds.Get(heightIndexKey(h))
+	if err == datastore.ErrNotFound {
+		return block.TipSetKey{}, false, nil
+	}
+	if err != nil {
+		return block.TipSetKey{}, false, errors.Wrapf(err, "failed to read height index at %d", h)
+	}
+	var key block.TipSetKey
+	if err := encoding.Decode(bb, &key); err != nil {
+		return block.TipSetKey{}, false, errors.Wrapf(err, "failed to decode height index at %d", h)
+	}
+	return key, true, nil
+}
@@ -0,0 +1,98 @@
+package chain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func requireGetTipSetAtHeight(ctx context.Context, t *testing.T, chainStore *chain.Store, head block.TipSet, h uint64) block.TipSet {
+	ts, err := chainStore.GetTipSetAtHeight(ctx, head, h)
+	require.NoError(t, err)
+	return ts
+}
+
+func TestGetTipSetAtHeight(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	cs := newChainStore(r, genTS.At(0).Cid())
+
+	link1 := builder.AppendOn(genTS, 2)
+	link2 := builder.AppendOn(link1, 3)
+	link3 := builder.AppendOn(link2, 1)
+	link4 := builder.BuildOn(link3, 2, func(bb *chain.BlockBuilder, i int) { bb.IncHeight(2) })
+	requirePutTestChain(ctx, t, cs, link4.Key(), builder, 5)
+
+	t.Run("indexed head", func(t *testing.T) {
+		assertSetHead(t, cs, link4)
+
+		assert.Equal(t, genTS, requireGetTipSetAtHeight(ctx, t, cs, link4, 0))
+		assert.Equal(t, link1, requireGetTipSetAtHeight(ctx, t, cs, link4, 1))
+		assert.Equal(t, link2, requireGetTipSetAtHeight(ctx, t, cs, link4, 2))
+		assert.Equal(t, link3, requireGetTipSetAtHeight(ctx, t, cs, link4, 3))
+		// Null rounds resolve to the tipset below them.
+		assert.Equal(t, link3, requireGetTipSetAtHeight(ctx, t, cs, link4, 4))
+		assert.Equal(t, link3, requireGetTipSetAtHeight(ctx, t, cs, link4, 5))
+		assert.Equal(t, link4, requireGetTipSetAtHeight(ctx, t, cs, link4, 6))
+	})
+
+	t.Run("ancestor of head", func(t *testing.T) {
+		assertSetHead(t, cs, link4)
+
+		assert.Equal(t, link1, requireGetTipSetAtHeight(ctx, t, cs, link2, 1))
+		assert.Equal(t, link2, requireGetTipSetAtHeight(ctx, t, cs, link2, 2))
+	})
+
+	t.Run("height above head errors", func(t *testing.T) {
+		_, err := cs.GetTipSetAtHeight(ctx, link2, 3)
+		assert.Error(t, err)
+	})
+}
+
+func TestHeightIndexFollowsReorg(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	cs := newChainStore(r, genTS.At(0).Cid())
+
+	// genesis -> link1 -> link2 -> link3
+	//                  \-> (null) -> (null) -> fork4
+	link1 := builder.AppendOn(genTS, 1)
+	link2 := builder.AppendOn(link1, 1)
+	link3 := builder.AppendOn(link2, 1)
+	fork4 := builder.BuildOn(link1, 1, func(bb *chain.BlockBuilder, i int) { bb.IncHeight(2) })
+	requirePutTestChain(ctx, t, cs, link3.Key(), builder, 4)
+	requirePutTestChain(ctx, t, cs, fork4.Key(), builder, 1)
+
+	assertSetHead(t, cs, genTS)
+	assertSetHead(t, cs, link3)
+	assert.Equal(t, link2, requireGetTipSetAtHeight(ctx, t, cs, link3, 2))
+	// Tipsets off the indexed chain are resolved by walking their ancestors.
+	assert.Equal(t, link1, requireGetTipSetAtHeight(ctx, t, cs, fork4, 3))
+
+	// Reorg onto the fork, heights 2 and 3 are now null rounds.
+	assertSetHead(t, cs, fork4)
+	assert.Equal(t, link1, requireGetTipSetAtHeight(ctx, t, cs, fork4, 2))
+	assert.Equal(t, link1, requireGetTipSetAtHeight(ctx, t, cs, fork4, 3))
+	assert.Equal(t, fork4, requireGetTipSetAtHeight(ctx, t, cs, fork4, 4))
+
+	// And back again.
+	assertSetHead(t, cs, link3)
+	assert.Equal(t, link2, requireGetTipSetAtHeight(ctx, t, cs, link3, 2))
+	assert.Equal(t, link3, requireGetTipSetAtHeight(ctx, t, cs, link3, 3))
+}
@@ -0,0 +1,619 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+func init() {
+	encoding.RegisterIpldCborType(MessageInclusion{})
+}
+
+var logMessageIndex = logging.Logger("chain.msgindex")
+
+// MessageIndexHeadKey is the key at which the key of the tipset heading the
+// message index is written in the datastore.
+var MessageIndexHeadKey = datastore.NewKey("/chain/msgIndexHead")
+
+// messageIndexPrefix is the datastore namespace under which the message index
+// maps a message CID to its inclusion in the chain.
+var messageIndexPrefix = datastore.NewKey("/chain/msgIndex")
+
+// messageAddressIndexPrefix is the datastore namespace under which the message
+// index lists the messages sent from and to each address by height.
+var messageAddressIndexPrefix = datastore.NewKey("/chain/msgAddrIndex")
+
+// messageIndexBatchSize is the number of tipsets indexed in a single datastore
+// batch when catching up with the chain.
+const messageIndexBatchSize = 100
+
+// messageIndexRetryDelay is the time the index waits before following the
+// chain again after failing to index a head change.
+const messageIndexRetryDelay = 5 * time.Second
+
+// MessageInclusion records where a message was included in the chain and the
+// result of its execution.
+type MessageInclusion struct {
+	// TipSet is the key of the tipset including the message.
+	TipSet block.TipSetKey
+	// Height is the height of that tipset.
+	Height uint64
+	// Block is the CID of the first block of the tipset to include the message.
+	Block e.Cid
+	// Index is the position of the message in the execution order of the
+	// tipset's deduplicated messages, which is also the index of its receipt.
+	Index uint64
+	// Receipt is the receipt of the message's execution.
+	Receipt types.MessageReceipt
+	// From and To are the addresses under which the message is listed in the
+	// address index.
+	From address.Address
+	To   address.Address
+}
+
+// MessageParty is the part an address plays in a message.
+type MessageParty string
+
+const (
+	// MessageFrom selects the messages an address sent.
+	MessageFrom = MessageParty("from")
+	// MessageTo selects the messages sent to an address.
+	MessageTo = MessageParty("to")
+)
+
+type messageIndexChain interface {
+	GetTipSet(block.TipSetKey) (block.TipSet, error)
+	GetTipSetReceiptsRoot(block.TipSetKey) (cid.Cid, error)
+	SubscribeHeadChanges(context.Context) *HeadChangeSubscription
+}
+
+// addressResolver resolves addresses to the ID addresses of their actors in
+// the state of a tipset.
+type addressResolver interface {
+	ResolveAddress(context.Context, block.TipSetKey, address.Address) (address.Address, bool, error)
+}
+
+// MessageIndex maps the CIDs of messages on the head chain to the tipset
+// including them, their execution index and their receipt.  It follows head
+// changes, removing the messages of reverted tipsets and adding those of
+// applied tipsets, so it can lag the head for a short time.  Messages are
+// indexed by the CID they were included with, i.e. the signed CID of SECP
+// messages and the unsigned CID of BLS messages.  The index also lists the
+// messages sent from and to each address.  Addresses are listed by the ID
+// address of their actor, so an actor's messages are listed together whether
+// they name it by its ID or its public key address.
+type MessageIndex struct {
+	ds       repo.Datastore
+	chain    messageIndexChain
+	messages MessageProvider
+	resolver addressResolver
+}
+
+// NewMessageIndex constructs a MessageIndex on the chain datastore, listing
+// messages under the addresses resolved by `resolver`.
+func NewMessageIndex(ds repo.Datastore, chain messageIndexChain, messages MessageProvider, resolver addressResolver) *MessageIndex {
+	return &MessageIndex{
+		ds:       ds,
+		chain:    chain,
+		messages: messages,
+		resolver: resolver,
+	}
+}
+
+func messageIndexKey(c cid.Cid) datastore.Key {
+	return messageIndexPrefix.ChildString(c.String())
+}
+
+func messageAddressIndexPrefixKey(addr address.Address, party MessageParty) datastore.Key {
+	return messageAddressIndexPrefix.ChildString(addr.String()).ChildString(string(party))
+}
+
+// messageAddressIndexKey is the key listing the message with CID `c` for
+// `addr`.  Heights are zero-padded so keys sort by height.
+func messageAddressIndexKey(addr address.Address, party MessageParty, height uint64, c cid.Cid) datastore.Key {
+	return messageAddressIndexPrefixKey(addr, party).ChildString(fmt.Sprintf("%020d", height)).ChildString(c.String())
+}
+
+// Lookup returns the inclusion of the message with CID `msgCid` in the
+// indexed chain.  It returns false if the message is not indexed.
+func (mi *MessageIndex) Lookup(msgCid cid.Cid) (*MessageInclusion, bool, error) {
+	bb, err := mi.ds.Get(messageIndexKey(msgCid))
+	if err == datastore.ErrNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to read message index for %s", msgCid)
+	}
+	var inclusion MessageInclusion
+	if err := encoding.Decode(bb, &inclusion); err != nil {
+		return nil, false, errors.Wrapf(err, "failed to decode message index for %s", msgCid)
+	}
+	return &inclusion, true, nil
+}
+
+// AddressMessages returns the CIDs of the indexed messages for which `addr`
+// is the `party`, included at heights between `minHeight` and `maxHeight`
+// inclusive, ordered by increasing height.  `addr` is resolved in the state
+// of the index head, so messages naming the same actor by another address
+// are listed too.
+func (mi *MessageIndex) AddressMessages(ctx context.Context, addr address.Address, party MessageParty, minHeight, maxHeight uint64) ([]cid.Cid, error) {
+	head, err := mi.Head()
+	if err != nil {
+		return nil, err
+	}
+	// Messages indexed before the actor existed, or in tipsets whose state
+	// was unavailable, are listed under `addr` itself.
+	addrs := []address.Address{addr}
+	if !head.Empty() {
+		if canonical := mi.canonicalAddress(ctx, head, addr); canonical != addr {
+			addrs = append(addrs, canonical)
+		}
+	}
+
+	type listed struct {
+		height uint64
+		cid    cid.Cid
+	}
+	var msgs []listed
+	seen := make(map[cid.Cid]struct{})
+	for _, a := range addrs {
+		prefix := messageAddressIndexPrefixKey(a, party).String() + "/"
+		res, err := mi.ds.Query(query.Query{Prefix: prefix, KeysOnly: true})
+		if err != nil {
+			return nil, err
+		}
+		entries, err := res.Rest()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			parts := strings.Split(strings.TrimPrefix(entry.Key, prefix), "/")
+			if len(parts) != 2 {
+				return nil, errors.Errorf("malformed message address index key %s", entry.Key)
+			}
+			height, err := strconv.ParseUint(parts[0], 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "malformed message address index key %s", entry.Key)
+			}
+			if height < minHeight || height > maxHeight {
+				continue
+			}
+			c, err := cid.Decode(parts[1])
+			if err != nil {
+				return nil, errors.Wrapf(err, "malformed message address index key %s", entry.Key)
+			}
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			seen[c] = struct{}{}
+			msgs = append(msgs, listed{height: height, cid: c})
+		}
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		if msgs[i].height != msgs[j].height {
+			return msgs[i].height < msgs[j].height
+		}
+		return msgs[i].cid.String() < msgs[j].cid.String()
+	})
+
+	cids := make([]cid.Cid, len(msgs))
+	for i, msg := range msgs {
+		cids[i] = msg.cid
+	}
+	return cids, nil
+}
+
+// canonicalAddress returns the address under which the messages of `addr`
+// are listed for the tipset identified by `key`: the ID address of its actor
+// in the state of the tipset, or `addr` itself if it has no actor or the
+// state is unavailable, e.g. because it was pruned.
+func (mi *MessageIndex) canonicalAddress(ctx context.Context, key block.TipSetKey, addr address.Address) address.Address {
+	idAddr, found, err := mi.resolver.ResolveAddress(ctx, key, addr)
+	if err != nil {
+		logMessageIndex.Debugf("failed to resolve %s in tipset %s, indexing it as is: %s", addr, key, err)
+		return addr
+	}
+	if !found {
+		return addr
+	}
+	return idAddr
+}
+
+// Head returns the key of the tipset heading the indexed chain.  It is empty
+// if nothing is indexed yet.
+func (mi *MessageIndex) Head() (block.TipSetKey, error) {
+	bb, err := mi.ds.Get(MessageIndexHeadKey)
+	if err == datastore.ErrNotFound {
+		return block.TipSetKey{}, nil
+	}
+	if err != nil {
+		return block.TipSetKey{}, errors.Wrap(err, "failed to read message index head")
+	}
+	var key block.TipSetKey
+	if err := encoding.Decode(bb, &key); err != nil {
+		return block.TipSetKey{}, errors.Wrap(err, "failed to decode message index head")
+	}
+	return key, nil
+}
+
+// Run keeps the index on the head chain until `ctx` is done or the chain
+// store stops.  It catches up with the head first, then indexes each head
+// change.
+func (mi *MessageIndex) Run(ctx context.Context) {
+	for {
+		err := mi.follow(ctx)
+		if ctx.Err() != nil || err == ErrStoreStopped {
+			return
+		}
+		logMessageIndex.Errorf("message index stopped following the chain, retrying: %s", err)
+		select {
+		case <-time.After(messageIndexRetryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (mi *MessageIndex) follow(ctx context.Context) error {
+	sub := mi.chain.SubscribeHeadChanges(ctx)
+	defer sub.Unsubscribe()
+
+	// The first change applies the current head alone.
+	first := true
+	for change := range sub.Changes() {
+		var err error
+		if first {
+			err = mi.CatchUp(ctx, change.Apply[len(change.Apply)-1])
+			first = false
+		} else {
+			err = mi.applyHeadChange(ctx, change)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return sub.Err()
+}
+
+// CatchUp moves the index onto the chain ending in `head`.
+func (mi *MessageIndex) CatchUp(ctx context.Context, head block.TipSet) error {
+	indexHeadKey, err := mi.Head()
+	if err != nil {
+		return err
+	}
+	if indexHeadKey.Equals(head.Key()) {
+		return nil
+	}
+
+	var revert, apply []block.TipSet
+	indexHead, err := mi.chain.GetTipSet(indexHeadKey)
+	if indexHeadKey.Empty() || err != nil {
+		logMessageIndex.Infof("rebuilding message index from %s", head.String())
+		if err := mi.clear(); err != nil {
+			return err
+		}
+		apply, err = CollectTipSetsOfHeightAtLeast(ctx, IterAncestors(ctx, mi.chain, head), types.NewBlockHeight(0))
+		if err != nil {
+			return err
+		}
+	} else {
+		revert, apply, err = CollectTipsToCommonAncestor(ctx, mi.chain, indexHead, head)
+		if err != nil {
+			return err
+		}
+	}
+	Reverse(apply)
+	return mi.update(ctx, revert, apply)
+}
+
+// applyHeadChange indexes a head change.  If the change does not start from
+// the indexed head, e.g. because an earlier change failed, the index catches
+// up with the new head instead.
+func (mi *MessageIndex) applyHeadChange(ctx context.Context, change *HeadChange) error {
+	indexHeadKey, err := mi.Head()
+	if err != nil {
+		return err
+	}
+	var from block.TipSetKey
+	if len(change.Revert) > 0 {
+		from = change.Revert[0].Key()
+	} else if len(change.Apply) > 0 {
+		from, err = change.Apply[0].Parents()
+		if err != nil {
+			return err
+		}
+	}
+	if from.Equals(indexHeadKey) {
+		return mi.update(ctx, change.Revert, change.Apply)
+	}
+
+	var newHead block.TipSet
+	if len(change.Apply) > 0 {
+		newHead = change.Apply[len(change.Apply)-1]
+	} else {
+		parents, err := change.Revert[len(change.Revert)-1].Parents()
+		if err != nil {
+			return err
+		}
+		if newHead, err = mi.chain.GetTipSet(parents); err != nil {
+			return err
+		}
+	}
+	return mi.CatchUp(ctx, newHead)
+}
+
+// update removes the messages of the tipsets in `revert`, ordered by
+// decreasing height, and adds those of the tipsets in `apply`, ordered by
+// increasing height.  Applied tipsets are written in batches each of which
+// moves the index head, so an interrupted update resumes where it stopped.
+func (mi *MessageIndex) update(ctx context.Context, revert, apply []block.TipSet) error {
+	batch, err := mi.ds.Batch()
+	if err != nil {
+		return err
+	}
+	var head block.TipSetKey
+	for _, ts := range revert {
+		if err := mi.revertTipSet(ctx, batch, ts); err != nil {
+			return err
+		}
+		if head, err = ts.Parents(); err != nil {
+			return err
+		}
+	}
+
+	written := make(map[cid.Cid]struct{})
+	for i, ts := range apply {
+		if err := mi.applyTipSet(ctx, batch, ts, written); err != nil {
+			return err
+		}
+		head = ts.Key()
+		if (i+1)%messageIndexBatchSize != 0 {
+			continue
+		}
+		if err := mi.commit(batch, head); err != nil {
+			return err
+		}
+		h, _ := ts.Height()
+		logMessageIndex.Infof("indexed messages up to height %d", h)
+		if batch, err = mi.ds.Batch(); err != nil {
+			return err
+		}
+	}
+	if len(revert) == 0 && len(apply)%messageIndexBatchSize == 0 {
+		return nil
+	}
+	return mi.commit(batch, head)
+}
+
+func (mi *MessageIndex) commit(batch datastore.Batch, head block.TipSetKey) error {
+	val, err := encoding.Encode(head)
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(MessageIndexHeadKey, val); err != nil {
+		return err
+	}
+	return batch.Commit()
+}
+
+// applyTipSet adds an entry for each message of `ts` that is not already
+// indexed, either in the datastore or in `written`.
+func (mi *MessageIndex) applyTipSet(ctx context.Context, batch datastore.Batch, ts block.TipSet, written map[cid.Cid]struct{}) error {
+	h, err := ts.Height()
+	if err != nil {
+		return err
+	}
+	msgs, err := mi.tipSetMessages(ctx, ts)
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	receiptsRoot, err := mi.chain.GetTipSetReceiptsRoot(ts.Key())
+	if err != nil {
+		return err
+	}
+	receipts, err := mi.messages.LoadReceipts(ctx, receiptsRoot)
+	if err != nil {
+		return err
+	}
+
+	canonical := make(map[address.Address]address.Address)
+	resolve := func(addr address.Address) address.Address {
+		if resolved, ok := canonical[addr]; ok {
+			return resolved
+		}
+		resolved := mi.canonicalAddress(ctx, ts.Key(), addr)
+		canonical[addr] = resolved
+		return resolved
+	}
+
+	for _, msg := range msgs {
+		if _, ok := written[msg.cid]; ok {
+			continue
+		}
+		has, err := mi.ds.Has(messageIndexKey(msg.cid))
+		if err != nil {
+			return err
+		}
+		if has {
+			// The message was included by an earlier tipset.
+			continue
+		}
+		if msg.index >= uint64(len(receipts)) {
+			return errors.Errorf("no receipt at index %d for message %s in tipset %s", msg.index, msg.cid, ts.String())
+		}
+		from, to := resolve(msg.from), resolve(msg.to)
+		val, err := encoding.Encode(MessageInclusion{
+			TipSet:  ts.Key(),
+			Height:  h,
+			Block:   e.NewCid(msg.block),
+			Index:   msg.index,
+			Receipt: *receipts[msg.index],
+			From:    from,
+			To:      to,
+		})
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(messageIndexKey(msg.cid), val); err != nil {
+			return err
+		}
+		if err := batch.Put(messageAddressIndexKey(from, MessageFrom, h, msg.cid), []byte{}); err != nil {
+			return err
+		}
+		if err := batch.Put(messageAddressIndexKey(to, MessageTo, h, msg.cid), []byte{}); err != nil {
+			return err
+		}
+		written[msg.cid] = struct{}{}
+	}
+	return nil
+}
+
+// revertTipSet deletes the entries of messages included by `ts`.
+func (mi *MessageIndex) revertTipSet(ctx context.Context, batch datastore.Batch, ts block.TipSet) error {
+	msgs, err := mi.tipSetMessages(ctx, ts)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		inclusion, found, err := mi.Lookup(msg.cid)
+		if err != nil {
+			return err
+		}
+		if !found || !inclusion.TipSet.Equals(ts.Key()) {
+			continue
+		}
+		if err := batch.Delete(messageIndexKey(msg.cid)); err != nil {
+			return err
+		}
+		// The message is listed under the addresses resolved when it was
+		// applied, which entries written before they were recorded lack.
+		from, to := inclusion.From, inclusion.To
+		if from.Empty() || to.Empty() {
+			from, to = msg.from, msg.to
+		}
+		if err := batch.Delete(messageAddressIndexKey(from, MessageFrom, inclusion.Height, msg.cid)); err != nil {
+			return err
+		}
+		if err := batch.Delete(messageAddressIndexKey(to, MessageTo, inclusion.Height, msg.cid)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clear deletes all entries and the head of the index.
+func (mi *MessageIndex) clear() error {
+	batch, err := mi.ds.Batch()
+	if err != nil {
+		return err
+	}
+	for _, prefix := range []datastore.Key{messageIndexPrefix, messageAddressIndexPrefix} {
+		res, err := mi.ds.Query(query.Query{Prefix: prefix.String() + "/", KeysOnly: true})
+		if err != nil {
+			return err
+		}
+		entries, err := res.Rest()
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := batch.Delete(datastore.NewKey(entry.Key)); err != nil {
+				return err
+			}
+		}
+	}
+	// Some datastores fail to delete missing keys.
+	hasHead, err := mi.ds.Has(MessageIndexHeadKey)
+	if err != nil {
+		return err
+	}
+	if hasHead {
+		if err := batch.Delete(MessageIndexHeadKey); err != nil {
+			return err
+		}
+	}
+	return batch.Commit()
+}
+
+type indexedMessage struct {
+	cid   cid.Cid
+	block cid.Cid
+	index uint64
+	from  address.Address
+	to    address.Address
+}
+
+// tipSetMessages returns the messages of `ts` with the CIDs they were
+// included with and their execution index.  A message included by several
+// blocks of the tipset is executed once, at its first inclusion.
+func (mi *MessageIndex) tipSetMessages(ctx context.Context, ts block.TipSet) ([]indexedMessage, error) {
+	var msgs []indexedMessage
+	executed := make(map[cid.Cid]uint64)
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		secpMsgs, blsMsgs, err := mi.messages.LoadMessages(ctx, blk.Messages.Cid)
+		if err != nil {
+			return nil, err
+		}
+
+		add := func(included cid.Cid, unwrapped *types.UnsignedMessage) error {
+			unwrappedCid, err := unwrapped.Cid()
+			if err != nil {
+				return err
+			}
+			index, ok := executed[unwrappedCid]
+			if !ok {
+				index = uint64(len(executed))
+				executed[unwrappedCid] = index
+			}
+			msgs = append(msgs, indexedMessage{
+				cid:   included,
+				block: blk.Cid(),
+				index: index,
+				from:  unwrapped.From,
+				to:    unwrapped.To,
+			})
+			return nil
+		}
+		for _, msg := range blsMsgs {
+			c, err := msg.Cid()
+			if err != nil {
+				return nil, err
+			}
+			if err := add(c, msg); err != nil {
+				return nil, err
+			}
+		}
+		for _, msg := range secpMsgs {
+			c, err := msg.Cid()
+			if err != nil {
+				return nil, err
+			}
+			if err := add(c, &msg.Message); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return msgs, nil
+}
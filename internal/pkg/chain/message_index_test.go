@@ -0,0 +1,325 @@
+package chain_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// requirePutTipSetWithReceipts puts `ts` in the chain store with `count`
+// receipts, the receipt at index i returning i.
+func requirePutTipSetWithReceipts(ctx context.Context, t *testing.T, cs *chain.Store, builder *chain.Builder, ts block.TipSet, count int) {
+	receipts := make([]*types.MessageReceipt, count)
+	for i := range receipts {
+		receipts[i] = &types.MessageReceipt{Return: [][]byte{{byte(i)}}, GasAttoFIL: types.ZeroAttoFIL}
+	}
+	receiptsCid, err := builder.StoreReceipts(ctx, receipts)
+	require.NoError(t, err)
+	require.NoError(t, cs.PutTipSetMetadata(ctx, &chain.TipSetMetadata{
+		TipSet:          ts,
+		TipSetStateRoot: ts.At(0).StateRoot.Cid,
+		TipSetReceipts:  receiptsCid,
+	}))
+}
+
+func requireLookup(t *testing.T, index *chain.MessageIndex, msg *types.SignedMessage) (*chain.MessageInclusion, bool) {
+	c, err := msg.Cid()
+	require.NoError(t, err)
+	inclusion, found, err := index.Lookup(c)
+	require.NoError(t, err)
+	return inclusion, found
+}
+
+func assertIndexedIn(t *testing.T, index *chain.MessageIndex, msg *types.SignedMessage, ts block.TipSet) *chain.MessageInclusion {
+	inclusion, found := requireLookup(t, index, msg)
+	require.True(t, found)
+	assert.Equal(t, ts.Key(), inclusion.TipSet)
+	h, err := ts.Height()
+	require.NoError(t, err)
+	assert.Equal(t, h, inclusion.Height)
+	assert.Equal(t, [][]byte{{byte(inclusion.Index)}}, inclusion.Receipt.Return)
+	return inclusion
+}
+
+func assertNotIndexed(t *testing.T, index *chain.MessageIndex, msg *types.SignedMessage) {
+	_, found := requireLookup(t, index, msg)
+	assert.False(t, found)
+}
+
+func TestMessageIndex(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	cs := newChainStore(r, genTS.At(0).Cid())
+
+	keys := types.MustGenerateKeyInfo(1, 42)
+	mm := types.NewMessageMaker(t, keys)
+	alice := mm.Addresses()[0]
+	m1, m2, m3 := mm.NewSignedMessage(alice, 0), mm.NewSignedMessage(alice, 1), mm.NewSignedMessage(alice, 2)
+	m4, m5 := mm.NewSignedMessage(alice, 3), mm.NewSignedMessage(alice, 4)
+
+	// genesis -> link1 {m1, m2} {m2, m3} -> link2 {m4, m5}
+	//                                    \-> fork2 {m4}
+	link1 := builder.Build(genTS, 2, func(b *chain.BlockBuilder, i int) {
+		if i == 0 {
+			b.AddMessages([]*types.SignedMessage{m1, m2}, []*types.UnsignedMessage{})
+		} else {
+			b.AddMessages([]*types.SignedMessage{m2, m3}, []*types.UnsignedMessage{})
+		}
+	})
+	link2 := builder.BuildOneOn(link1, func(b *chain.BlockBuilder) {
+		b.AddMessages([]*types.SignedMessage{m4, m5}, []*types.UnsignedMessage{})
+	})
+	fork2 := builder.BuildOneOn(link1, func(b *chain.BlockBuilder) {
+		b.AddMessages([]*types.SignedMessage{m4}, []*types.UnsignedMessage{})
+	})
+	requirePutTestChain(ctx, t, cs, genTS.Key(), builder, 1)
+	requirePutTipSetWithReceipts(ctx, t, cs, builder, link1, 3)
+	requirePutTipSetWithReceipts(ctx, t, cs, builder, link2, 2)
+	requirePutTipSetWithReceipts(ctx, t, cs, builder, fork2, 1)
+
+	index := chain.NewMessageIndex(r.ChainDatastore(), cs, builder, fixedResolver{})
+	head, err := index.Head()
+	require.NoError(t, err)
+	assert.True(t, head.Empty())
+	assertNotIndexed(t, index, m1)
+
+	require.NoError(t, index.CatchUp(ctx, link2))
+	head, err = index.Head()
+	require.NoError(t, err)
+	assert.Equal(t, link2.Key(), head)
+
+	// Messages are executed once, in order of their first inclusion.
+	indices := make(map[uint64]struct{})
+	for _, msg := range []*types.SignedMessage{m1, m2, m3} {
+		inclusion := assertIndexedIn(t, index, msg, link1)
+		indices[inclusion.Index] = struct{}{}
+	}
+	assert.Len(t, indices, 3)
+	inclusion := assertIndexedIn(t, index, m2, link1)
+	assert.Equal(t, link1.At(0).Cid(), inclusion.Block.Cid)
+	assert.Equal(t, uint64(0), assertIndexedIn(t, index, m4, link2).Index)
+	assert.Equal(t, uint64(1), assertIndexedIn(t, index, m5, link2).Index)
+
+	// A reorg moves messages to the new chain and removes the others.
+	require.NoError(t, index.CatchUp(ctx, fork2))
+	assertIndexedIn(t, index, m1, link1)
+	assertIndexedIn(t, index, m4, fork2)
+	assertNotIndexed(t, index, m5)
+
+	// The index is persisted.
+	reopened := chain.NewMessageIndex(r.ChainDatastore(), cs, builder, fixedResolver{})
+	head, err = reopened.Head()
+	require.NoError(t, err)
+	assert.Equal(t, fork2.Key(), head)
+	assertIndexedIn(t, reopened, m4, fork2)
+}
+
+func TestMessageIndexFollowsHead(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	cs := newChainStore(r, genTS.At(0).Cid())
+
+	keys := types.MustGenerateKeyInfo(1, 42)
+	mm := types.NewMessageMaker(t, keys)
+	alice := mm.Addresses()[0]
+	m1, m2 := mm.NewSignedMessage(alice, 0), mm.NewSignedMessage(alice, 1)
+
+	// genesis -> link1 {m1} -> link2 {m2}
+	//                       \-> fork2 -> fork3
+	link1 := builder.BuildOneOn(genTS, func(b *chain.BlockBuilder) {
+		b.AddMessages([]*types.SignedMessage{m1}, []*types.UnsignedMessage{})
+	})
+	link2 := builder.BuildOneOn(link1, func(b *chain.BlockBuilder) {
+		b.AddMessages([]*types.SignedMessage{m2}, []*types.UnsignedMessage{})
+	})
+	fork3 := builder.AppendManyOn(2, link1)
+	requirePutTestChain(ctx, t, cs, genTS.Key(), builder, 1)
+	requirePutTipSetWithReceipts(ctx, t, cs, builder, link1, 1)
+	requirePutTipSetWithReceipts(ctx, t, cs, builder, link2, 1)
+	requirePutTestChain(ctx, t, cs, fork3.Key(), builder, 2)
+	assertSetHead(t, cs, link2)
+
+	index := chain.NewMessageIndex(r.ChainDatastore(), cs, builder, fixedResolver{})
+	go index.Run(ctx)
+
+	requireIndexHead(t, index, link2.Key())
+	assertIndexedIn(t, index, m1, link1)
+	assertIndexedIn(t, index, m2, link2)
+
+	assertSetHead(t, cs, fork3)
+	requireIndexHead(t, index, fork3.Key())
+	assertIndexedIn(t, index, m1, link1)
+	assertNotIndexed(t, index, m2)
+}
+
+func requireIndexHead(t *testing.T, index *chain.MessageIndex, key block.TipSetKey) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		head, err := index.Head()
+		require.NoError(t, err)
+		if head.Equals(key) {
+			return
+		}
+		require.True(t, time.Now().Before(deadline), "index head %s is not %s", head, key)
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMessageIndexAddressMessages(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	cs := newChainStore(r, genTS.At(0).Cid())
+
+	keys := types.MustGenerateKeyInfo(2, 42)
+	mm := types.NewMessageMaker(t, keys)
+	alice, bob := mm.Addresses()[0], mm.Addresses()[1]
+	a1, a2, b1 := mm.NewSignedMessage(alice, 0), mm.NewSignedMessage(alice, 1), mm.NewSignedMessage(bob, 0)
+	a3 := mm.NewSignedMessage(alice, 2)
+	destination := a1.Message.To
+
+	// genesis -> link1 {a1, b1} -> link2 {a2} -> link3 {a3}
+	//                                        \-> fork3
+	link1 := builder.BuildOneOn(genTS, func(b *chain.BlockBuilder) {
+		b.AddMessages([]*types.SignedMessage{a1, b1}, []*types.UnsignedMessage{})
+	})
+	link2 := builder.BuildOneOn(link1, func(b *chain.BlockBuilder) {
+		b.AddMessages([]*types.SignedMessage{a2}, []*types.UnsignedMessage{})
+	})
+	link3 := builder.BuildOneOn(link2, func(b *chain.BlockBuilder) {
+		b.AddMessages([]*types.SignedMessage{a3}, []*types.UnsignedMessage{})
+	})
+	fork3 := builder.AppendOn(link2, 1)
+	requirePutTestChain(ctx, t, cs, genTS.Key(), builder, 1)
+	requirePutTipSetWithReceipts(ctx, t, cs, builder, link1, 2)
+	requirePutTipSetWithReceipts(ctx, t, cs, builder, link2, 1)
+	requirePutTipSetWithReceipts(ctx, t, cs, builder, link3, 1)
+	requirePutTestChain(ctx, t, cs, fork3.Key(), builder, 1)
+
+	index := chain.NewMessageIndex(r.ChainDatastore(), cs, builder, fixedResolver{})
+	require.NoError(t, index.CatchUp(ctx, link3))
+
+	requireAddressMessages := func(addr address.Address, party chain.MessageParty, minHeight, maxHeight uint64) []*types.SignedMessage {
+		cids, err := index.AddressMessages(ctx, addr, party, minHeight, maxHeight)
+		require.NoError(t, err)
+		byCid := make(map[string]*types.SignedMessage)
+		for _, msg := range []*types.SignedMessage{a1, a2, a3, b1} {
+			c, err := msg.Cid()
+			require.NoError(t, err)
+			byCid[c.String()] = msg
+		}
+		var msgs []*types.SignedMessage
+		for _, c := range cids {
+			msgs = append(msgs, byCid[c.String()])
+		}
+		return msgs
+	}
+
+	assert.Equal(t, []*types.SignedMessage{a1, a2, a3}, requireAddressMessages(alice, chain.MessageFrom, 0, 10))
+	assert.Equal(t, []*types.SignedMessage{a2}, requireAddressMessages(alice, chain.MessageFrom, 2, 2))
+	assert.Equal(t, []*types.SignedMessage{b1}, requireAddressMessages(bob, chain.MessageFrom, 0, 10))
+	assert.Empty(t, requireAddressMessages(alice, chain.MessageTo, 0, 10))
+	assert.Len(t, requireAddressMessages(destination, chain.MessageTo, 0, 10), 4)
+
+	// Reverted messages are no longer listed.
+	require.NoError(t, index.CatchUp(ctx, fork3))
+	assert.Equal(t, []*types.SignedMessage{a1, a2}, requireAddressMessages(alice, chain.MessageFrom, 0, 10))
+	assert.Len(t, requireAddressMessages(destination, chain.MessageTo, 0, 10), 3)
+}
+
+// fixedResolver resolves addresses with a fixed map rather than in the state
+// of tipsets, which the test builder does not create.
+type fixedResolver map[address.Address]address.Address
+
+func (r fixedResolver) ResolveAddress(_ context.Context, _ block.TipSetKey, addr address.Address) (address.Address, bool, error) {
+	if addr.Protocol() == address.ID {
+		return addr, true, nil
+	}
+	id, ok := r[addr]
+	return id, ok, nil
+}
+
+func TestMessageIndexAddressMessagesResolvesAddresses(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	cs := newChainStore(r, genTS.At(0).Cid())
+
+	keys := types.MustGenerateKeyInfo(1, 42)
+	mm := types.NewMessageMaker(t, keys)
+	alice := mm.Addresses()[0]
+	aliceID, err := address.NewIDAddress(100)
+	require.NoError(t, err)
+	destinationID, err := address.NewIDAddress(101)
+	require.NoError(t, err)
+
+	// The first message names its sender by public key address, the second
+	// by ID address.
+	a1 := mm.NewSignedMessage(alice, 0)
+	a2 := mm.NewUnsignedMessage(aliceID, 1)
+	destination := a1.Message.To
+	a1Cid, err := a1.Cid()
+	require.NoError(t, err)
+	a2Cid, err := a2.Cid()
+	require.NoError(t, err)
+
+	// genesis -> link1 {a1} -> link2 {a2}
+	//                      \-> fork2
+	link1 := builder.BuildOneOn(genTS, func(b *chain.BlockBuilder) {
+		b.AddMessages([]*types.SignedMessage{a1}, []*types.UnsignedMessage{})
+	})
+	link2 := builder.BuildOneOn(link1, func(b *chain.BlockBuilder) {
+		b.AddMessages([]*types.SignedMessage{}, []*types.UnsignedMessage{a2})
+	})
+	fork2 := builder.AppendOn(link1, 1)
+	requirePutTestChain(ctx, t, cs, genTS.Key(), builder, 1)
+	requirePutTipSetWithReceipts(ctx, t, cs, builder, link1, 1)
+	requirePutTipSetWithReceipts(ctx, t, cs, builder, link2, 1)
+	requirePutTestChain(ctx, t, cs, fork2.Key(), builder, 1)
+
+	resolver := fixedResolver{alice: aliceID, destination: destinationID}
+	index := chain.NewMessageIndex(r.ChainDatastore(), cs, builder, resolver)
+	require.NoError(t, index.CatchUp(ctx, link2))
+
+	// Either address of an actor lists all its messages.
+	for _, addr := range []address.Address{alice, aliceID} {
+		cids, err := index.AddressMessages(ctx, addr, chain.MessageFrom, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []cid.Cid{a1Cid, a2Cid}, cids)
+	}
+	cids, err := index.AddressMessages(ctx, destinationID, chain.MessageTo, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []cid.Cid{a1Cid, a2Cid}, cids)
+
+	// Reverted messages are removed under the address they were listed by.
+	require.NoError(t, index.CatchUp(ctx, fork2))
+	cids, err = index.AddressMessages(ctx, alice, chain.MessageFrom, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []cid.Cid{a1Cid}, cids)
+}
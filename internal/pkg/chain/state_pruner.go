@@ -0,0 +1,227 @@
+package chain
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics/tracing"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+var logPruner = logging.Logger("chain.pruner")
+
+// PrunedHeightKey is the key at which the height below which chain state has
+// been pruned is written in the datastore.
+var PrunedHeightKey = datastore.NewKey("/chain/prunedHeight")
+
+// PruneResult reports the work done by a single run of the StatePruner.
+type PruneResult struct {
+	// PrunedHeight is the height below which state is no longer kept.
+	PrunedHeight uint64
+	// TipSetsPruned is the number of tipsets whose state was pruned in this run.
+	TipSetsPruned int
+	// BlocksDeleted is the number of blocks deleted from the blockstore.
+	BlocksDeleted int
+}
+
+// StatePruner deletes state trees, actor storage and receipt collections that
+// are no longer needed by the node from the blockstore.  It keeps the state
+// and receipts of all tipsets within finality of the head, of a further
+// number of finalized epochs below that, and of the genesis tipset.  Block
+// headers and messages are never pruned.
+//
+// Only the chain ending in the head passed to Prune is considered, state of
+// abandoned forks is left in place.  The StatePruner only reads the chain
+// datastore and blockstore so it can run against the repo of a stopped node.
+type StatePruner struct {
+	// ds is the chain datastore holding tipset metadata and the pruned height.
+	ds repo.Datastore
+	// bs is the blockstore holding chain state.
+	bs blockstore.Blockstore
+	// tipsets provides the tipsets of the chain being pruned.
+	tipsets TipSetProvider
+}
+
+// NewStatePruner constructs a StatePruner.
+func NewStatePruner(ds repo.Datastore, bs blockstore.Blockstore, tipsets TipSetProvider) *StatePruner {
+	return &StatePruner{
+		ds:      ds,
+		bs:      bs,
+		tipsets: tipsets,
+	}
+}
+
+// Prune deletes the state and receipts of tipsets on the chain ending in
+// `head` that are more than `keepEpochs` epochs below finality, except for
+// blocks that are still reachable from state that is kept.  State already
+// pruned by a previous run is not revisited.
+//
+// Pruning may run alongside state computation.  A block deleted here that is
+// re-added by a concurrently computed state is lost, so pruning keeps well
+// clear of the head.
+func (p *StatePruner) Prune(ctx context.Context, head block.TipSet, keepEpochs uint64) (_ *PruneResult, err error) {
+	ctx, span := trace.StartSpan(ctx, "StatePruner.Prune")
+	defer tracing.AddErrorEndSpan(ctx, span, &err)
+
+	headHeight, err := head.Height()
+	if err != nil {
+		return nil, err
+	}
+	prunedHeight, err := p.loadPrunedHeight()
+	if err != nil {
+		return nil, err
+	}
+	result := &PruneResult{PrunedHeight: prunedHeight}
+	if headHeight < consensus.FinalityEpochs+keepEpochs {
+		return result, nil
+	}
+	cutoff := headHeight - consensus.FinalityEpochs - keepEpochs
+	if cutoff <= prunedHeight {
+		return result, nil
+	}
+
+	// The empty AMT root is shared by empty message and receipt collections.
+	keepRoots := []cid.Cid{types.EmptyMessagesCID, types.EmptyReceiptsCID, types.EmptyTxMetaCID}
+	var pruneRoots []cid.Cid
+	for iter := IterAncestors(ctx, p.tipsets, head); !iter.Complete(); err = iter.Next() {
+		if err != nil {
+			return nil, err
+		}
+		ts := iter.Value()
+		h, err := ts.Height()
+		if err != nil {
+			return nil, err
+		}
+		if h != 0 && h < prunedHeight {
+			// Skip to genesis, everything in between is already pruned.
+			continue
+		}
+
+		stateRoot, receipts, err := loadStateRootAndReceipts(p.ds, ts)
+		if errors.Cause(err) == datastore.ErrNotFound {
+			// Blocks below the head of an imported chain have no metadata.
+			logPruner.Debugf("no state recorded for tipset %s", ts.String())
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h >= cutoff || h == 0 {
+			keepRoots = append(keepRoots, stateRoot, receipts, ts.At(0).StateRoot.Cid, ts.At(0).MessageReceipts.Cid)
+		} else {
+			pruneRoots = append(pruneRoots, stateRoot, receipts)
+			result.TipSetsPruned++
+		}
+	}
+
+	logPruner.Infof("pruning state of %d tipsets below height %d", result.TipSetsPruned, cutoff)
+	keep := cid.NewSet()
+	for _, root := range keepRoots {
+		if err := p.walk(ctx, root, keep, nil); err != nil {
+			return nil, errors.Wrapf(err, "failed to traverse kept state %s", root)
+		}
+	}
+	garbage := cid.NewSet()
+	for _, root := range pruneRoots {
+		if err := p.walk(ctx, root, garbage, keep); err != nil {
+			return nil, errors.Wrapf(err, "failed to traverse pruned state %s", root)
+		}
+	}
+
+	err = garbage.ForEach(func(c cid.Cid) error {
+		if err := p.bs.DeleteBlock(c); err != nil {
+			return err
+		}
+		result.BlocksDeleted++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.writePrunedHeight(cutoff); err != nil {
+		return nil, err
+	}
+	result.PrunedHeight = cutoff
+	logPruner.Infof("pruned %d blocks of state below height %d", result.BlocksDeleted, cutoff)
+	return result, nil
+}
+
+// walk adds `root` and every block reachable from it to `visited`.  Blocks in
+// `skip` are not visited and neither are blocks only reachable through them.
+// Links to blocks missing from the blockstore, such as builtin actor code,
+// are ignored.
+func (p *StatePruner) walk(ctx context.Context, root cid.Cid, visited, skip *cid.Set) error {
+	stack := []cid.Cid{root}
+	for len(stack) > 0 {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !c.Defined() || visited.Has(c) || (skip != nil && skip.Has(c)) {
+			continue
+		}
+
+		blk, err := p.bs.Get(c)
+		if err == blockstore.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		visited.Add(c)
+
+		if c.Prefix().Codec != cid.DagCBOR {
+			continue
+		}
+		node, err := cbor.DecodeBlock(blk)
+		if err != nil {
+			return errors.Wrapf(err, "failed to decode %s", c)
+		}
+		for _, link := range node.Links() {
+			stack = append(stack, link.Cid)
+		}
+	}
+	return nil
+}
+
+func (p *StatePruner) loadPrunedHeight() (uint64, error) {
+	return loadPrunedHeight(p.ds)
+}
+
+// loadPrunedHeight reads the height below which the state of tipsets other
+// than genesis was pruned from the chain datastore `ds`, zero if it never was.
+func loadPrunedHeight(ds repo.Datastore) (uint64, error) {
+	bb, err := ds.Get(PrunedHeightKey)
+	if err == datastore.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read pruned height")
+	}
+	var h uint64
+	if err := encoding.Decode(bb, &h); err != nil {
+		return 0, errors.Wrap(err, "failed to decode pruned height")
+	}
+	return h, nil
+}
+
+func (p *StatePruner) writePrunedHeight(h uint64) error {
+	val, err := encoding.Encode(h)
+	if err != nil {
+		return err
+	}
+	return p.ds.Put(PrunedHeightKey, val)
+}
@@ -0,0 +1,85 @@
+package chain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+func requirePutCborNode(t *testing.T, bs bstore.Blockstore, obj interface{}) cid.Cid {
+	nd, err := cbor.WrapObject(obj, multihash.SHA2_256, -1)
+	require.NoError(t, err)
+	require.NoError(t, bs.Put(nd))
+	return nd.Cid()
+}
+
+func TestStatePrunerPrune(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	length := int(consensus.FinalityEpochs) + 10
+	head := builder.AppendManyOn(length, genTS)
+
+	r := repo.NewInMemoryRepo()
+	bs := bstore.NewBlockstore(r.Datastore())
+	cs := chain.NewStore(r.ChainDatastore(), cborutil.NewIpldStore(bs), state.NewTreeLoader(), chain.NewStatusReporter(), genTS.At(0).Cid())
+
+	// Every tipset gets its own state root linking to a block shared by all of them.
+	shared := requirePutCborNode(t, bs, map[string]interface{}{"shared": true})
+	roots := make(map[uint64]cid.Cid)
+	for _, ts := range builder.RequireTipSets(head.Key(), length+1) {
+		h, err := ts.Height()
+		require.NoError(t, err)
+		leaf := requirePutCborNode(t, bs, map[string]interface{}{"leaf": h})
+		roots[h] = requirePutCborNode(t, bs, map[string]interface{}{"height": h, "leaf": leaf, "shared": shared})
+		require.NoError(t, cs.PutTipSetMetadata(ctx, &chain.TipSetMetadata{
+			TipSet:          ts,
+			TipSetStateRoot: roots[h],
+			TipSetReceipts:  types.EmptyReceiptsCID,
+		}))
+	}
+
+	pruner := chain.NewStatePruner(r.ChainDatastore(), bs, cs)
+	res, err := pruner.Prune(ctx, head, 5)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), res.PrunedHeight)
+	assert.Equal(t, 4, res.TipSetsPruned)
+	assert.Equal(t, 8, res.BlocksDeleted)
+
+	requireHas := func(c cid.Cid) bool {
+		has, err := bs.Has(c)
+		require.NoError(t, err)
+		return has
+	}
+	for h := uint64(1); h < 5; h++ {
+		assert.False(t, requireHas(roots[h]), "state at height %d should be pruned", h)
+	}
+	for h := uint64(5); h <= uint64(length); h++ {
+		assert.True(t, requireHas(roots[h]), "state at height %d should be kept", h)
+	}
+	assert.True(t, requireHas(roots[0]))
+	assert.True(t, requireHas(shared))
+
+	// A second run with the same head has nothing left to do.
+	res, err = pruner.Prune(ctx, head, 5)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), res.PrunedHeight)
+	assert.Equal(t, 0, res.BlocksDeleted)
+}
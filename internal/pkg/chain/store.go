@@ -96,6 +96,13 @@ type Store struct {
 	// on decisions made around the FC node notification system.
 	headEvents *pubsub.PubSub
 
+	// headChanges notifies subscribers of the tipsets reverted and applied by
+	// each head change.
+	headChanges *headChangeNotifier
+	// headChangeMu serializes head changes so that they are published in
+	// the order they are made.
+	headChangeMu sync.Mutex
+
 	// Tracks tipsets by height/parentset for use by expected consensus.
 	tipIndex *TipIndex
 
@@ -110,14 +117,18 @@ func NewStore(ds repo.Datastore, cst cbor.IpldStore, stl state.TreeLoader, sr Re
 		stateTreeLoader:     stl,
 		ds:                  ds,
 		headEvents:          pubsub.New(128),
+		headChanges:         newHeadChangeNotifier(),
 		tipIndex:            NewTipIndex(),
 		genesis:             genesisCid,
 		reporter:            sr,
 	}
 }
 
-// Load rebuilds the Store's caches by traversing backwards from the
-// most recent best head as stored in its datastore.  Because Load uses a
+// Load rebuilds the Store's caches from the most recent best head as stored
+// in its datastore.  If the persisted tip index has a usable checkpoint only
+// the metadata of recent tipsets is loaded, older metadata is loaded on
+// demand.  Otherwise Load rebuilds the index by traversing backwards from the
+// head to genesis, as described below.  Because Load uses a
 // content addressed datastore it guarantees that parent blocks are correctly
 // resolved from the datastore.  Furthermore Load ensures that all tipsets
 // references correctly have the same parent height, weight and parent set.
@@ -148,6 +159,17 @@ func (store *Store) Load(ctx context.Context) (err error) {
 		return errors.Wrap(err, "error loading head tipset")
 	}
 	startHeight := headTs.At(0).Height
+
+	if _, err := store.loadTipIndexCheckpoint(); err != nil {
+		logStore.Infof("rebuilding tip index: %s", err)
+	} else if err := store.loadRecentTipSets(ctx, headTs); err != nil {
+		logStore.Warnf("failed to load tip index from checkpoint, rebuilding: %s", err)
+		store.tipIndex = NewTipIndex()
+	} else {
+		logStore.Infof("loaded recent tipsets of chain at tipset: %s, height: %d", headTsKey.String(), startHeight)
+		return store.SetHead(ctx, headTs)
+	}
+
 	logStore.Infof("start loading chain at tipset: %s, height: %d", headTsKey.String(), startHeight)
 	// Ensure we only produce 10 log messages regardless of the chain height.
 	logStatusEvery := startHeight / 10
@@ -195,7 +217,12 @@ func (store *Store) Load(ctx context.Context) (err error) {
 
 	logStore.Infof("finished loading %d tipsets from %s", startHeight, headTs.String())
 	// Set actual head.
-	return store.SetHead(ctx, headTs)
+	if err := store.SetHead(ctx, headTs); err != nil {
+		return err
+	}
+	// The head may have been unchanged, in which case SetHead did not write
+	// the checkpoint.
+	return store.writeTipIndexCheckpoint(headTs.Key())
 }
 
 // loadHead loads the latest known head from disk.
@@ -216,12 +243,18 @@ func (store *Store) loadHead() (block.TipSetKey, error) {
 }
 
 func (store *Store) loadStateRootAndReceipts(ts block.TipSet) (cid.Cid, cid.Cid, error) {
+	return loadStateRootAndReceipts(store.ds, ts)
+}
+
+// loadStateRootAndReceipts reads the state root and receipts root persisted
+// for a tipset by writeTipSetMetadata from the chain datastore.
+func loadStateRootAndReceipts(ds repo.Datastore, ts block.TipSet) (cid.Cid, cid.Cid, error) {
 	h, err := ts.Height()
 	if err != nil {
 		return cid.Undef, cid.Undef, err
 	}
 	key := datastore.NewKey(makeKey(ts.String(), h))
-	bb, err := store.ds.Get(key)
+	bb, err := ds.Get(key)
 	if err != nil {
 		return cid.Undef, cid.Undef, errors.Wrapf(err, "failed to read tipset key %s", ts.String())
 	}
@@ -252,16 +285,20 @@ func (store *Store) PutTipSetMetadata(ctx context.Context, tsm *TipSetMetadata)
 
 // GetTipSet returns the tipset identified by `key`.
 func (store *Store) GetTipSet(key block.TipSetKey) (block.TipSet, error) {
-	return store.tipIndex.GetTipSet(key)
+	tsm, err := store.getTipSetMetadata(key)
+	if err != nil {
+		return block.UndefTipSet, err
+	}
+	return tsm.TipSet, nil
 }
 
 // GetTipSetState returns the aggregate state of the tipset identified by `key`.
 func (store *Store) GetTipSetState(ctx context.Context, key block.TipSetKey) (state.Tree, error) {
-	stateCid, err := store.tipIndex.GetTipSetStateRoot(key)
+	tsm, err := store.getTipSetMetadata(key)
 	if err != nil {
 		return nil, err
 	}
-	return store.stateTreeLoader.LoadStateTree(ctx, store.stateAndBlockSource.cborStore, stateCid)
+	return store.stateTreeLoader.LoadStateTree(ctx, store.stateAndBlockSource.cborStore, tsm.TipSetStateRoot)
 }
 
 // GetGenesisState returns the state tree at genesis to retrieve initialization parameters.
@@ -283,34 +320,66 @@ func (store *Store) GetGenesisBlock(ctx context.Context) (*block.Block, error) {
 
 // GetTipSetStateRoot returns the aggregate state root CID of the tipset identified by `key`.
 func (store *Store) GetTipSetStateRoot(key block.TipSetKey) (cid.Cid, error) {
-	return store.tipIndex.GetTipSetStateRoot(key)
+	tsm, err := store.getTipSetMetadata(key)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return tsm.TipSetStateRoot, nil
 }
 
 // GetTipSetReceiptsRoot returns the root CID of the message receipts for the tipset identified by `key`.
 func (store *Store) GetTipSetReceiptsRoot(key block.TipSetKey) (cid.Cid, error) {
-	return store.tipIndex.GetTipSetReceiptsRoot(key)
+	tsm, err := store.getTipSetMetadata(key)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return tsm.TipSetReceipts, nil
 }
 
 // HasTipSetAndState returns true iff the default store's tipindex is indexing
 // the tipset identified by `key`.
 func (store *Store) HasTipSetAndState(ctx context.Context, key block.TipSetKey) bool {
-	return store.tipIndex.Has(key)
+	_, err := store.getTipSetMetadata(key)
+	return err == nil
 }
 
 // GetTipSetAndStatesByParentsAndHeight returns the the tipsets and states tracked by
 // the default store's tipIndex that have parents identified by `parentKey`.
 func (store *Store) GetTipSetAndStatesByParentsAndHeight(parentKey block.TipSetKey, h uint64) ([]*TipSetMetadata, error) {
-	return store.tipIndex.GetByParentsAndHeight(parentKey, h)
+	keys, err := store.loadTipIndexEntries(parentKey, h)
+	if err != nil {
+		return nil, err
+	}
+	var ret []*TipSetMetadata
+	for _, key := range keys {
+		tsm, err := store.getTipSetMetadata(key)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, tsm)
+	}
+	if len(ret) == 0 {
+		return nil, ErrNotFound
+	}
+	return ret, nil
 }
 
 // HasTipSetAndStatesWithParentsAndHeight returns true if the default store's tipindex
 // contains any tipset identified by `parentKey`.
 func (store *Store) HasTipSetAndStatesWithParentsAndHeight(parentKey block.TipSetKey, h uint64) bool {
-	return store.tipIndex.HasByParentsAndHeight(parentKey, h)
+	if store.tipIndex.HasByParentsAndHeight(parentKey, h) {
+		return true
+	}
+	_, err := store.GetTipSetAndStatesByParentsAndHeight(parentKey, h)
+	return err == nil
 }
 
 // HeadEvents returns a pubsub interface the pushes events each time the
-// default store's head is reset.
+// default store's head is reset.  Consumers that need the tipsets reverted
+// and applied by each head change should use SubscribeHeadChanges.
 func (store *Store) HeadEvents() *pubsub.PubSub {
 	return store.headEvents
 }
@@ -325,6 +394,13 @@ func (store *Store) SetHead(ctx context.Context, ts block.TipSet) error {
 		logStore.Error(debug.Stack())
 	}
 
+	store.headChangeMu.Lock()
+	defer store.headChangeMu.Unlock()
+
+	store.mu.RLock()
+	oldHead := store.head
+	store.mu.RUnlock()
+
 	noop, err := store.setHeadPersistent(ctx, ts)
 	if err != nil {
 		return err
@@ -341,6 +417,7 @@ func (store *Store) SetHead(ctx context.Context, ts block.TipSet) error {
 	store.reporter.UpdateStatus(validateHead(ts.Key()), validateHeight(h))
 	// Publish an event that we have a new head.
 	store.HeadEvents().Pub(ts, NewHeadTopic)
+	store.publishHeadChange(ctx, oldHead, ts)
 
 	return nil
 }
@@ -366,6 +443,18 @@ func (store *Store) setHeadPersistent(ctx context.Context, ts block.TipSet) (boo
 
 	store.head = ts
 
+	// Every tipset's index entries are written before it can become the head
+	// so the persisted index now covers the new head's chain.
+	if errInner := store.writeTipIndexCheckpoint(ts.Key()); errInner != nil {
+		return false, errors.Wrap(errInner, "failed to write tip index checkpoint")
+	}
+
+	// Move the height index onto the new head's chain.  The index is only a
+	// lookup accelerator so failing to update it does not fail the head change.
+	if errInner := store.updateHeightIndex(ctx, ts); errInner != nil {
+		logStore.Warnf("failed to update height index to %s: %s", ts.String(), errInner)
+	}
+
 	return false, nil
 }
 
@@ -406,7 +495,19 @@ func (store *Store) writeTipSetMetadata(tsm *TipSetMetadata) error {
 		return err
 	}
 	key := datastore.NewKey(makeKey(tsm.TipSet.String(), h))
-	return store.ds.Put(key, val)
+
+	// Write the metadata and its tip index entry together.
+	batch, err := store.ds.Batch()
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(key, val); err != nil {
+		return err
+	}
+	if err := putTipIndexEntry(batch, tsm.TipSet); err != nil {
+		return err
+	}
+	return batch.Commit()
 }
 
 // GetHead returns the current head tipset cids.
@@ -431,4 +532,5 @@ func (store *Store) GenesisCid() cid.Cid {
 // Stop stops all activities and cleans up.
 func (store *Store) Stop() {
 	store.headEvents.Shutdown()
+	store.headChanges.stop()
 }
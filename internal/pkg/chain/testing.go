@@ -445,6 +445,11 @@ func (e *FakeStateEvaluator) RunStateTransition(ctx context.Context, tip block.T
 	return e.ComputeState(stateID, blsMessages, secpMessages)
 }
 
+// ValidateSyntax is a stub that always returns no error
+func (e *FakeStateEvaluator) ValidateSyntax(_ context.Context, _ *block.Block) error {
+	return nil
+}
+
 // ValidateSemantic is a stub that always returns no error
 func (e *FakeStateEvaluator) ValidateSemantic(_ context.Context, _ *block.Block, _ block.TipSet) error {
 	return nil
@@ -0,0 +1,303 @@
+package chain
+
+import (
+	"context"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+)
+
+func init() {
+	encoding.RegisterIpldCborType(tipIndexCheckpoint{})
+}
+
+// TipIndexCheckpointKey is the key at which the tip index checkpoint is
+// written in the datastore.
+var TipIndexCheckpointKey = datastore.NewKey("/chain/tipIndexCheckpoint")
+
+// tipIndexParentsPrefix is the datastore namespace under which the keys of
+// all tipsets with metadata are indexed by parents and height.
+var tipIndexParentsPrefix = datastore.NewKey("/chain/tipIndex")
+
+// tipIndexVersion is the version of the persisted tip index format.  A
+// checkpoint of any other version causes the index to be rebuilt.
+const tipIndexVersion = 1
+
+// recentTipSetsLoaded is the number of tipsets below the head whose metadata
+// is loaded into memory at startup.  Older metadata is loaded on demand.
+const recentTipSetsLoaded = consensus.FinalityEpochs
+
+// tipIndexCheckpoint records that the persisted tip index describes every
+// tipset on the chain ending in Head.  Metadata and parent index entries are
+// written with each tipset before it can become the head, so a checkpoint
+// that lags the persisted head is still valid.
+type tipIndexCheckpoint struct {
+	Version uint64
+	Genesis e.Cid
+	Head    block.TipSetKey
+}
+
+// TipIndexReport describes the consistency of the persisted tip index with
+// the chain ending in the head.
+type TipIndexReport struct {
+	// TipSetsChecked is the number of tipsets on the chain checked.
+	TipSetsChecked int
+	// MissingMetadata are tipsets on the chain with no state recorded.  They
+	// cannot be repaired from the index alone.
+	MissingMetadata []block.TipSetKey
+	// MissingEntries are tipsets on the chain absent from the parents index.
+	MissingEntries []block.TipSetKey
+	// DanglingEntries is the number of parents index entries for tipsets with
+	// no metadata or blocks.
+	DanglingEntries int
+	// CheckpointValid is false if the checkpoint is missing or unusable.
+	CheckpointValid bool
+	// Repaired is true if the index entries and checkpoint were rewritten.
+	Repaired bool
+}
+
+// Consistent returns true iff no inconsistency was found.
+func (r *TipIndexReport) Consistent() bool {
+	return len(r.MissingMetadata) == 0 && len(r.MissingEntries) == 0 && r.DanglingEntries == 0 && r.CheckpointValid
+}
+
+func tipIndexParentsKey(parents block.TipSetKey, h uint64) datastore.Key {
+	return tipIndexParentsPrefix.ChildString(makeKey(parents.String(), h))
+}
+
+func tipIndexEntryKey(ts block.TipSet) (datastore.Key, error) {
+	parents, err := ts.Parents()
+	if err != nil {
+		return datastore.Key{}, err
+	}
+	h, err := ts.Height()
+	if err != nil {
+		return datastore.Key{}, err
+	}
+	return tipIndexParentsKey(parents, h).ChildString(ts.String()), nil
+}
+
+// putTipIndexEntry writes the parents index entry of `ts` to the batch.
+func putTipIndexEntry(batch datastore.Batch, ts block.TipSet) error {
+	key, err := tipIndexEntryKey(ts)
+	if err != nil {
+		return err
+	}
+	val, err := encoding.Encode(ts.Key())
+	if err != nil {
+		return err
+	}
+	return batch.Put(key, val)
+}
+
+// loadTipIndexEntries returns the keys of all persisted tipsets with parents
+// `parents` at height `h`.
+func (store *Store) loadTipIndexEntries(parents block.TipSetKey, h uint64) ([]block.TipSetKey, error) {
+	prefix := tipIndexParentsKey(parents, h)
+	res, err := store.ds.Query(query.Query{Prefix: prefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+	var keys []block.TipSetKey
+	for _, entry := range entries {
+		// The prefix also matches siblings sharing a string prefix, such as
+		// height 10 for height 1.
+		if !datastore.NewKey(entry.Key).Parent().Equal(prefix) {
+			continue
+		}
+		var key block.TipSetKey
+		if err := encoding.Decode(entry.Value, &key); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode tip index entry %s", entry.Key)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// getTipSetMetadata returns the metadata of the tipset identified by `key`
+// from the in-memory tip index, loading it from the datastore if it is not
+// yet there.  It returns ErrNotFound if no metadata is recorded.
+func (store *Store) getTipSetMetadata(key block.TipSetKey) (*TipSetMetadata, error) {
+	tsm, err := store.tipIndex.Get(key)
+	if err != ErrNotFound {
+		return tsm, err
+	}
+
+	ts, err := LoadTipSetBlocks(context.Background(), store.stateAndBlockSource, key)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	stateRoot, receipts, err := store.loadStateRootAndReceipts(ts)
+	if errors.Cause(err) == datastore.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	tsm = &TipSetMetadata{
+		TipSet:          ts,
+		TipSetStateRoot: stateRoot,
+		TipSetReceipts:  receipts,
+	}
+	if err := store.tipIndex.Put(tsm); err != nil {
+		return nil, err
+	}
+	return tsm, nil
+}
+
+// loadTipIndexCheckpoint reads the checkpoint and returns an error if it is
+// missing or does not describe the persisted index of this chain.
+func (store *Store) loadTipIndexCheckpoint() (*tipIndexCheckpoint, error) {
+	bb, err := store.ds.Get(TipIndexCheckpointKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read tip index checkpoint")
+	}
+	var cp tipIndexCheckpoint
+	if err := encoding.Decode(bb, &cp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode tip index checkpoint")
+	}
+	if cp.Version != tipIndexVersion {
+		return nil, errors.Errorf("tip index checkpoint version %d, expected %d", cp.Version, tipIndexVersion)
+	}
+	if !cp.Genesis.Equals(store.genesis) {
+		return nil, errors.Errorf("tip index checkpoint genesis %s, expected %s", cp.Genesis, store.genesis)
+	}
+	return &cp, nil
+}
+
+// writeTipIndexCheckpoint records that the persisted index covers the chain
+// ending in `head`.
+func (store *Store) writeTipIndexCheckpoint(head block.TipSetKey) error {
+	val, err := encoding.Encode(tipIndexCheckpoint{
+		Version: tipIndexVersion,
+		Genesis: e.NewCid(store.genesis),
+		Head:    head,
+	})
+	if err != nil {
+		return err
+	}
+	return store.ds.Put(TipIndexCheckpointKey, val)
+}
+
+// loadRecentTipSets loads the metadata of the most recent tipsets of the
+// chain ending in `head` into the in-memory tip index.
+func (store *Store) loadRecentTipSets(ctx context.Context, head block.TipSet) error {
+	tipsetProvider := TipSetProviderFromBlocks(ctx, store.stateAndBlockSource)
+	var err error
+	loaded := uint64(0)
+	for iter := IterAncestors(ctx, tipsetProvider, head); !iter.Complete() && loaded <= recentTipSetsLoaded; err = iter.Next() {
+		if err != nil {
+			return err
+		}
+		stateRoot, receipts, err := store.loadStateRootAndReceipts(iter.Value())
+		if err != nil {
+			return err
+		}
+		err = store.tipIndex.Put(&TipSetMetadata{
+			TipSet:          iter.Value(),
+			TipSetStateRoot: stateRoot,
+			TipSetReceipts:  receipts,
+		})
+		if err != nil {
+			return err
+		}
+		loaded++
+	}
+	return nil
+}
+
+// CheckTipIndex checks the persisted tip index against the chain ending in
+// the head.  Every tipset on the chain must have metadata and a parents
+// index entry, every parents index entry must refer to a tipset with
+// metadata and the checkpoint must be usable.  If `repair` is true missing
+// entries are written, dangling entries deleted and the checkpoint rewritten.
+// Missing metadata is reported but cannot be repaired here.
+func (store *Store) CheckTipIndex(ctx context.Context, repair bool) (*TipIndexReport, error) {
+	report := &TipIndexReport{}
+	_, err := store.loadTipIndexCheckpoint()
+	report.CheckpointValid = err == nil
+
+	head, err := store.GetTipSet(store.GetHead())
+	if err != nil {
+		return nil, err
+	}
+
+	batch, err := store.ds.Batch()
+	if err != nil {
+		return nil, err
+	}
+	tipsetProvider := TipSetProviderFromBlocks(ctx, store.stateAndBlockSource)
+	for iter := IterAncestors(ctx, tipsetProvider, head); !iter.Complete(); err = iter.Next() {
+		if err != nil {
+			return nil, err
+		}
+		ts := iter.Value()
+		report.TipSetsChecked++
+
+		_, _, err := store.loadStateRootAndReceipts(ts)
+		if errors.Cause(err) == datastore.ErrNotFound {
+			report.MissingMetadata = append(report.MissingMetadata, ts.Key())
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		key, err := tipIndexEntryKey(ts)
+		if err != nil {
+			return nil, err
+		}
+		has, err := store.ds.Has(key)
+		if err != nil {
+			return nil, err
+		}
+		if !has {
+			report.MissingEntries = append(report.MissingEntries, ts.Key())
+			if err := putTipIndexEntry(batch, ts); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	res, err := store.ds.Query(query.Query{Prefix: tipIndexParentsPrefix.String() + "/"})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		var key block.TipSetKey
+		if err := encoding.Decode(entry.Value, &key); err == nil {
+			if _, err := store.getTipSetMetadata(key); err == nil {
+				continue
+			}
+		}
+		report.DanglingEntries++
+		if err := batch.Delete(datastore.NewKey(entry.Key)); err != nil {
+			return nil, err
+		}
+	}
+
+	if !repair || report.Consistent() {
+		return report, nil
+	}
+	if err := batch.Commit(); err != nil {
+		return nil, err
+	}
+	if err := store.writeTipIndexCheckpoint(head.Key()); err != nil {
+		return nil, err
+	}
+	report.Repaired = true
+	return report, nil
+}
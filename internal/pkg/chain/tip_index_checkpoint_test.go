@@ -0,0 +1,138 @@
+package chain_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+// newPersistedChainStore puts a chain of `length` tipsets above genesis into
+// a new store backed by a blockstore on the repo's datastore and sets the head.
+func newPersistedChainStore(ctx context.Context, t *testing.T, r repo.Repo, length int) (*chain.Builder, block.TipSet, block.TipSet, *cborutil.IpldStore) {
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	head := builder.AppendManyOn(length, genTS)
+
+	cst := cborutil.NewIpldStore(bstore.NewBlockstore(r.Datastore()))
+	tss := builder.RequireTipSets(head.Key(), length+1)
+	for _, ts := range tss {
+		requirePutBlocksToCborStore(t, cst, ts.ToSlice()...)
+	}
+
+	cs := chain.NewStore(r.ChainDatastore(), cst, state.NewTreeLoader(), chain.NewStatusReporter(), genTS.At(0).Cid())
+	requirePutTestChain(ctx, t, cs, head.Key(), builder, length+1)
+	assertSetHead(t, cs, genTS)
+	assertSetHead(t, cs, head)
+	cs.Stop()
+	return builder, genTS, head, cst
+}
+
+func TestLoadFromTipIndexCheckpoint(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	r := repo.NewInMemoryRepo()
+	length := int(consensus.FinalityEpochs) + 10
+	builder, genTS, head, cst := newPersistedChainStore(ctx, t, r, length)
+
+	// Remove the metadata of an old tipset.  A store loading from the
+	// checkpoint never reads it at startup.
+	old := builder.RequireTipSets(head.Key(), length)[length-1]
+	oldHeight, err := old.Height()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), oldHeight)
+	require.NoError(t, r.ChainDatastore().Delete(datastore.NewKey(fmt.Sprintf("p-%s h-%d", old.String(), oldHeight))))
+
+	rebooted := chain.NewStore(r.ChainDatastore(), cst, state.NewTreeLoader(), chain.NewStatusReporter(), genTS.At(0).Cid())
+	require.NoError(t, rebooted.Load(ctx))
+	assert.Equal(t, head.Key(), rebooted.GetHead())
+
+	// Older metadata is loaded on demand.
+	assert.Equal(t, genTS, requireGetTipSet(ctx, t, rebooted, genTS.Key()))
+	assert.False(t, rebooted.HasTipSetAndState(ctx, old.Key()))
+	_, err = rebooted.GetTipSetAndStatesByParentsAndHeight(genTS.Key(), 1)
+	assert.Error(t, err)
+
+	// Without the checkpoint the whole chain is walked and the missing
+	// metadata is found.
+	require.NoError(t, r.ChainDatastore().Delete(chain.TipIndexCheckpointKey))
+	rebooted = chain.NewStore(r.ChainDatastore(), cst, state.NewTreeLoader(), chain.NewStatusReporter(), genTS.At(0).Cid())
+	assert.Error(t, rebooted.Load(ctx))
+}
+
+func TestLoadRebuildsTipIndexWithoutCheckpoint(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	r := repo.NewInMemoryRepo()
+	builder, genTS, head, cst := newPersistedChainStore(ctx, t, r, 5)
+	require.NoError(t, r.ChainDatastore().Delete(chain.TipIndexCheckpointKey))
+
+	rebooted := chain.NewStore(r.ChainDatastore(), cst, state.NewTreeLoader(), chain.NewStatusReporter(), genTS.At(0).Cid())
+	require.NoError(t, rebooted.Load(ctx))
+	for _, ts := range builder.RequireTipSets(head.Key(), 6) {
+		assert.True(t, rebooted.HasTipSetAndState(ctx, ts.Key()))
+	}
+
+	has, err := r.ChainDatastore().Has(chain.TipIndexCheckpointKey)
+	require.NoError(t, err)
+	assert.True(t, has)
+}
+
+func TestCheckTipIndex(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	r := repo.NewInMemoryRepo()
+	_, genTS, _, cst := newPersistedChainStore(ctx, t, r, 5)
+	cs := chain.NewStore(r.ChainDatastore(), cst, state.NewTreeLoader(), chain.NewStatusReporter(), genTS.At(0).Cid())
+	require.NoError(t, cs.Load(ctx))
+
+	report, err := cs.CheckTipIndex(ctx, false)
+	require.NoError(t, err)
+	assert.True(t, report.Consistent())
+	assert.Equal(t, 6, report.TipSetsChecked)
+
+	// Drop an index entry and add one for an unknown tipset.
+	res, err := r.ChainDatastore().Query(query.Query{Prefix: "/chain/tipIndex/"})
+	require.NoError(t, err)
+	entries, err := res.Rest()
+	require.NoError(t, err)
+	require.Len(t, entries, 6)
+	require.NoError(t, r.ChainDatastore().Delete(datastore.NewKey(entries[0].Key)))
+	unknown, err := encoding.Encode(block.NewTipSetKey(types.CidFromString(t, "unknown")))
+	require.NoError(t, err)
+	require.NoError(t, r.ChainDatastore().Put(datastore.NewKey("/chain/tipIndex/unknown/unknown"), unknown))
+
+	report, err = cs.CheckTipIndex(ctx, false)
+	require.NoError(t, err)
+	assert.False(t, report.Consistent())
+	assert.Len(t, report.MissingEntries, 1)
+	assert.Equal(t, 1, report.DanglingEntries)
+	assert.False(t, report.Repaired)
+
+	report, err = cs.CheckTipIndex(ctx, true)
+	require.NoError(t, err)
+	assert.True(t, report.Repaired)
+
+	report, err = cs.CheckTipIndex(ctx, false)
+	require.NoError(t, err)
+	assert.True(t, report.Consistent())
+}
@@ -58,6 +58,19 @@ func (m *Manager) TransitionChannel() chan bool {
 	return m.transitionCh
 }
 
+// HandleImportedChain validates the chain of imported `tipsets` and stages
+// its head if it is the heaviest.  Tipsets at or below `trustedHeight` are
+// not executed.
+func (m *Manager) HandleImportedChain(ctx context.Context, tipsets []block.TipSet, trustedHeight uint64) error {
+	return m.syncer.HandleImportedChain(ctx, tipsets, trustedHeight)
+}
+
+// RepairChain repairs the problems found by a chain check by fetching missing
+// data and recomputing state.
+func (m *Manager) RepairChain(ctx context.Context, report *chain.FsckReport) error {
+	return m.syncer.RepairChain(ctx, report)
+}
+
 // Status returns the block proposer.
 func (m *Manager) Status() status.Status {
 	return m.syncer.Status()
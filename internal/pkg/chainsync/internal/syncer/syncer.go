@@ -2,6 +2,7 @@ package syncer
 
 import (
 	"context"
+	"sync"
 
 	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log"
@@ -34,6 +35,8 @@ import (
 // tipset in the incoming chain, and assumptions regarding the existence of
 // grandparent state in the store.
 type Syncer struct {
+	// mu protects the chain store and staged tipset from concurrent syncs.
+	mu sync.Mutex
 	// fetcher is the networked block fetching service for fetching blocks
 	// and messages.
 	fetcher Fetcher
@@ -77,7 +80,9 @@ type Fetcher interface {
 // ChainReaderWriter reads and writes the chain store.
 type ChainReaderWriter interface {
 	GetHead() block.TipSetKey
+	GetCheckpoint() (block.TipSetKey, error)
 	GetTipSet(tsKey block.TipSetKey) (block.TipSet, error)
+	GetTipSetAtHeight(ctx context.Context, head block.TipSet, h uint64) (block.TipSet, error)
 	GetTipSetStateRoot(tsKey block.TipSetKey) (cid.Cid, error)
 	GetTipSetReceiptsRoot(tsKey block.TipSetKey) (cid.Cid, error)
 	HasTipSetAndState(ctx context.Context, tsKey block.TipSetKey) bool
@@ -102,8 +107,10 @@ type ChainSelector interface {
 	Weight(ctx context.Context, ts block.TipSet, stRoot cid.Cid) (fbig.Int, error)
 }
 
-// HeaderValidator does semanitc validation on headers
+// HeaderValidator does syntactic and semanitc validation on headers
 type HeaderValidator interface {
+	// ValidateSyntax validates a single block header is correctly formed.
+	ValidateSyntax(ctx context.Context, header *block.Block) error
 	// ValidateSemantic validates conditions on a block header that can be
 	// checked with the parent header but not parent state.
 	ValidateSemantic(ctx context.Context, header *block.Block, parents block.TipSet) error
@@ -133,6 +140,8 @@ var (
 	ErrChainHasBadTipSet = errors.New("input chain contains a cached bad tipset")
 	// ErrNewChainTooLong is returned when processing a fork that split off from the main chain too many blocks ago.
 	ErrNewChainTooLong = errors.New("input chain forked from best chain past finality limit")
+	// ErrChainMissesCheckpoint is returned when the syncer traverses a chain that does not include the checkpointed tipset.
+	ErrChainMissesCheckpoint = errors.New("input chain does not include the checkpointed tipset")
 	// ErrUnexpectedStoreState indicates that the syncer's chain store is violating expected invariants.
 	ErrUnexpectedStoreState = errors.New("the chain store is in an unexpected state")
 )
@@ -246,7 +255,12 @@ func (syncer *Syncer) syncOne(ctx context.Context, grandParent, parent, next blo
 
 	stopwatch := syncOneTimer.Start(ctx)
 	defer stopwatch.Stop(ctx)
+	return syncer.runTipSet(ctx, grandParent, parent, next)
+}
 
+// runTipSet runs the state transition of `next` on the state of `parent` and
+// stores the resulting state root and receipts as the metadata of `next`.
+func (syncer *Syncer) runTipSet(ctx context.Context, grandParent, parent, next block.TipSet) error {
 	// Lookup parent state and receipt root. It is guaranteed by the syncer that it is in the chainStore.
 	stateRoot, err := syncer.chainStore.GetTipSetStateRoot(parent.Key())
 	if err != nil {
@@ -455,6 +469,8 @@ func (syncer *Syncer) widen(ctx context.Context, ts block.TipSet) (block.TipSet,
 // HandleNewTipSet validates and syncs the chain rooted at the provided tipset
 // to a chain store.  Iff catchup is false then the syncer will set the head.
 func (syncer *Syncer) HandleNewTipSet(ctx context.Context, ci *block.ChainInfo, catchup bool) error {
+	syncer.mu.Lock()
+	defer syncer.mu.Unlock()
 	err := syncer.handleNewTipSet(ctx, ci)
 	if err != nil {
 		return err
@@ -478,6 +494,9 @@ func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo)
 	if syncer.chainStore.HasTipSetAndState(ctx, ci.Head) {
 		return nil
 	}
+	if syncer.badTipSets.Has(ci.Head.String()) {
+		return ErrChainHasBadTipSet
+	}
 
 	syncer.reporter.UpdateStatus(status.SyncingStarted(syncer.clock.Now().Unix()), status.SyncHead(ci.Head), status.SyncHeight(ci.Height), status.SyncComplete(false))
 	defer syncer.reporter.UpdateStatus(status.SyncComplete(true))
@@ -487,6 +506,9 @@ func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo)
 	if err != nil {
 		return err
 	}
+	if err := syncer.checkCheckpoint(ctx, tipsets); err != nil {
+		return err
+	}
 
 	// Once headers check out, fetch messages
 	_, err = syncer.fetcher.FetchTipSets(ctx, ci.Head, ci.Sender, func(t block.TipSet) (bool, error) {
@@ -568,9 +590,230 @@ func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo)
 	return syncer.stageIfHeaviest(ctx, parent)
 }
 
+// HandleImportedChain validates a chain of tipsets whose blocks and messages
+// are already in the local blockstore, e.g. after a CAR file import, and
+// stages its head if it is the heaviest tipset seen.  The tipsets must be in
+// height order and the parent of the first must already be in the chain store
+// with its state.
+//
+// Tipsets at or below `trustedHeight` are not executed, their state root and
+// receipts are taken from the headers of their children in the imported
+// chain.  The state they refer to must be present in the blockstore.  All
+// other tipsets are run through the state transition, which fails unless the
+// state roots and receipts recorded in their headers match those computed.
+func (syncer *Syncer) HandleImportedChain(ctx context.Context, tipsets []block.TipSet, trustedHeight uint64) (err error) {
+	syncer.mu.Lock()
+	defer syncer.mu.Unlock()
+
+	ctx, span := trace.StartSpan(ctx, "Syncer.HandleImportedChain")
+	defer tracing.AddErrorEndSpan(ctx, span, &err)
+
+	if len(tipsets) == 0 {
+		return nil
+	}
+	if err := syncer.checkCheckpoint(ctx, tipsets); err != nil {
+		return err
+	}
+	parent, grandParent, err := syncer.ancestorsFromStore(tipsets[0])
+	if err != nil {
+		return err
+	}
+
+	for i, ts := range tipsets {
+		for j := 0; j < ts.Len(); j++ {
+			if err := syncer.headerValidator.ValidateSyntax(ctx, ts.At(j)); err != nil {
+				syncer.badTipSets.AddChain(tipsets[i:])
+				return errors.Wrapf(err, "invalid block %s", ts.At(j).Cid())
+			}
+			if err := syncer.headerValidator.ValidateSemantic(ctx, ts.At(j), parent); err != nil {
+				syncer.badTipSets.AddChain(tipsets[i:])
+				return errors.Wrapf(err, "invalid block %s", ts.At(j).Cid())
+			}
+		}
+
+		h, err := ts.Height()
+		if err != nil {
+			return err
+		}
+		// The state of the last tipset is recorded in no header so it is always
+		// computed.
+		if h <= trustedHeight && i+1 < len(tipsets) {
+			err = syncer.trustTipSet(ctx, ts, tipsets[i+1])
+		} else {
+			err = syncer.syncOne(ctx, grandParent, parent, ts)
+		}
+		if err != nil {
+			syncer.badTipSets.AddChain(tipsets[i:])
+			return err
+		}
+
+		if i%500 == 0 {
+			logSyncer.Infof("processing imported block %d of %v", i, len(tipsets))
+		}
+		grandParent = parent
+		parent = ts
+	}
+	return syncer.stageIfHeaviest(ctx, parent)
+}
+
+// RepairChain repairs the problems found by a chain check.  Missing headers
+// and messages are fetched from peers.  The state and receipts of tipsets
+// with missing or corrupt metadata, receipts or state are recomputed by
+// running their state transition on the state of their parent, so problems
+// are repaired from the lowest tipset up.  State below the pruned height of
+// the report is never recomputed, which would undo the pruning.
+func (syncer *Syncer) RepairChain(ctx context.Context, report *chain.FsckReport) error {
+	syncer.mu.Lock()
+	defer syncer.mu.Unlock()
+
+	problems := report.Problems
+
+	type repair struct {
+		key   block.TipSetKey
+		fetch bool
+		run   bool
+	}
+	var repairs []*repair
+	byKey := make(map[string]*repair)
+	for i := len(problems) - 1; i >= 0; i-- {
+		p := problems[i]
+		r, ok := byKey[p.TipSet.String()]
+		if !ok {
+			r = &repair{key: p.TipSet}
+			byKey[p.TipSet.String()] = r
+			repairs = append(repairs, r)
+		}
+		switch p.Kind {
+		case chain.FsckHeader, chain.FsckMessages:
+			r.fetch = true
+		default:
+			r.run = true
+		}
+	}
+
+	for _, r := range repairs {
+		ts, err := syncer.chainStore.GetTipSet(r.key)
+		if r.fetch || err != nil {
+			logSyncer.Infof("fetching tipset %s to repair the chain", r.key)
+			fetched, err := syncer.fetcher.FetchTipSets(ctx, r.key, peer.ID(""), func(block.TipSet) (bool, error) {
+				return true, nil
+			})
+			if err != nil {
+				return errors.Wrapf(err, "failed to fetch tipset %s", r.key)
+			}
+			ts = fetched[0]
+		}
+		if !r.run {
+			continue
+		}
+		h, err := ts.Height()
+		if err != nil {
+			return err
+		}
+		if h != 0 && h < report.PrunedHeight {
+			logSyncer.Warnf("not recomputing the pruned state of tipset %s at height %d", r.key, h)
+			continue
+		}
+
+		parent, grandParent, err := syncer.ancestorsFromStore(ts)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load ancestors to recompute the state of tipset %s", r.key)
+		}
+		logSyncer.Infof("recomputing the state of tipset %s to repair the chain", r.key)
+		if err := syncer.runTipSet(ctx, grandParent, parent, ts); err != nil {
+			return errors.Wrapf(err, "failed to recompute the state of tipset %s", r.key)
+		}
+	}
+	return nil
+}
+
+// trustTipSet adds `ts` to the chain store with the state root and receipts
+// recorded in the headers of its child `child` without executing it.
+func (syncer *Syncer) trustTipSet(ctx context.Context, ts, child block.TipSet) error {
+	stateRoot := child.At(0).StateRoot.Cid
+	receipts := child.At(0).MessageReceipts.Cid
+	for i := 1; i < child.Len(); i++ {
+		if !child.At(i).StateRoot.Cid.Equals(stateRoot) || !child.At(i).MessageReceipts.Cid.Equals(receipts) {
+			return errors.Errorf("blocks of tipset %s disagree on the state of their parent", child.String())
+		}
+	}
+	return syncer.chainStore.PutTipSetMetadata(ctx, &chain.TipSetMetadata{
+		TipSet:          ts,
+		TipSetStateRoot: stateRoot,
+		TipSetReceipts:  receipts,
+	})
+}
+
+// checkCheckpoint returns ErrChainMissesCheckpoint and adds `tipsets` to the
+// bad tipset cache if the chain they extend does not include the checkpointed
+// tipset.  The tipsets must be in height order and the parent of the first
+// must be in the chain store.
+func (syncer *Syncer) checkCheckpoint(ctx context.Context, tipsets []block.TipSet) error {
+	cpKey, err := syncer.chainStore.GetCheckpoint()
+	if err != nil {
+		return err
+	}
+	if cpKey.Empty() {
+		return nil
+	}
+	cp, err := syncer.chainStore.GetTipSet(cpKey)
+	if err != nil {
+		return err
+	}
+	cpHeight, err := cp.Height()
+	if err != nil {
+		return err
+	}
+
+	included, err := syncer.includesCheckpoint(ctx, tipsets, cp, cpHeight)
+	if err != nil {
+		return err
+	}
+	if !included {
+		syncer.badTipSets.AddChain(tipsets)
+		return ErrChainMissesCheckpoint
+	}
+	return nil
+}
+
+func (syncer *Syncer) includesCheckpoint(ctx context.Context, tipsets []block.TipSet, cp block.TipSet, cpHeight uint64) (bool, error) {
+	// The checkpoint is either the highest new tipset at or below its height
+	// or an ancestor in the store.
+	for i := len(tipsets) - 1; i >= 0; i-- {
+		h, err := tipsets[i].Height()
+		if err != nil {
+			return false, err
+		}
+		if h <= cpHeight {
+			return tipsets[i].Equals(cp), nil
+		}
+	}
+	parent, _, err := syncer.ancestorsFromStore(tipsets[0])
+	if err != nil {
+		return false, err
+	}
+	parentHeight, err := parent.Height()
+	if err != nil {
+		return false, err
+	}
+	if parentHeight < cpHeight {
+		// The checkpoint height is a null round on this chain.
+		return false, nil
+	}
+	ts, err := syncer.chainStore.GetTipSetAtHeight(ctx, parent, cpHeight)
+	if err != nil {
+		return false, err
+	}
+	return ts.Equals(cp), nil
+}
+
 func (syncer *Syncer) stageIfHeaviest(ctx context.Context, candidate block.TipSet) error {
 	// stageIfHeaviest sets the provided candidates to the staging head of the chain if they
 	// are heavier. Precondtion: candidates are validated and added to the store.
+	// Candidates whose chain does not include the checkpoint are never staged.
+	if err := syncer.checkCheckpoint(ctx, []block.TipSet{candidate}); err != nil {
+		return err
+	}
 	parentKey, err := candidate.Parents()
 	if err != nil {
 		return err
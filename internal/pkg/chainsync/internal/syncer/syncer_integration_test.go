@@ -256,6 +256,10 @@ func (n *integrationStateEvaluator) RunStateTransition(_ context.Context, ts blo
 	return ts.At(0).StateRoot.Cid, []*types.MessageReceipt{}, nil
 }
 
+func (n *integrationStateEvaluator) ValidateSyntax(_ context.Context, _ *block.Block) error {
+	return nil
+}
+
 func (n *integrationStateEvaluator) ValidateSemantic(_ context.Context, _ *block.Block, _ block.TipSet) error {
 	return nil
 }
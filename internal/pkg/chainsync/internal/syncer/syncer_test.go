@@ -208,6 +208,59 @@ func TestAcceptHeavierFork(t *testing.T) {
 	verifyHead(t, store, fork3)
 }
 
+func TestRejectForkMissingCheckpoint(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	builder, store, s := setup(ctx, t)
+	genesis := builder.RequireTipSet(store.GetHead())
+
+	forkbase := builder.AppendOn(genesis, 1)
+	main1 := builder.AppendOn(forkbase, 1)
+	main2 := builder.AppendOn(main1, 1)
+	main3 := builder.AppendOn(main2, 1)
+
+	assert.NoError(t, s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", main3.Key(), heightFromTip(t, main3)), false))
+	verifyHead(t, store, main3)
+	require.NoError(t, store.SetCheckpoint(ctx, main1.Key()))
+
+	// A heavier fork below the checkpoint is rejected.
+	fork1 := builder.AppendOn(forkbase, 3)
+	fork2 := builder.AppendOn(fork1, 1)
+	fork3 := builder.AppendOn(fork2, 1)
+	err := s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", fork3.Key(), heightFromTip(t, fork3)), false)
+	assert.Equal(t, syncer.ErrChainMissesCheckpoint, err)
+	assert.False(t, store.HasTipSetAndState(ctx, fork1.Key()))
+	verifyHead(t, store, main3)
+
+	// Its tipsets are remembered as bad.
+	err = s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", fork3.Key(), heightFromTip(t, fork3)), false)
+	assert.Equal(t, syncer.ErrChainHasBadTipSet, err)
+
+	// A heavier fork above the checkpoint is accepted.
+	above1 := builder.AppendOn(main1, 3)
+	above2 := builder.AppendOn(above1, 1)
+	assert.NoError(t, s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", above2.Key(), heightFromTip(t, above2)), false))
+	verifyHead(t, store, above2)
+}
+
+func TestRejectImportedChainMissingCheckpoint(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	builder, store, s := setup(ctx, t)
+	genesis := builder.RequireTipSet(store.GetHead())
+
+	main1 := builder.AppendOn(genesis, 1)
+	main2 := builder.AppendOn(main1, 1)
+	require.NoError(t, s.HandleImportedChain(ctx, []block.TipSet{main1, main2}, 0))
+	require.NoError(t, s.SetStagedHead(ctx))
+	require.NoError(t, store.SetCheckpoint(ctx, main1.Key()))
+
+	fork1 := builder.AppendOn(genesis, 3)
+	fork2 := builder.AppendOn(fork1, 1)
+	assert.Equal(t, syncer.ErrChainMissesCheckpoint, s.HandleImportedChain(ctx, []block.TipSet{fork1, fork2}, 0))
+	assert.False(t, store.HasTipSetAndState(ctx, fork1.Key()))
+}
+
 func TestRejectFinalityFork(t *testing.T) {
 	tf.UnitTest(t)
 	ctx := context.Background()
@@ -427,6 +480,10 @@ func (pv *poisonValidator) RunStateTransition(_ context.Context, ts block.TipSet
 	return cid.Undef, nil, nil
 }
 
+func (pv *poisonValidator) ValidateSyntax(_ context.Context, _ *block.Block) error {
+	return nil
+}
+
 func (pv *poisonValidator) ValidateSemantic(_ context.Context, header *block.Block, _ block.TipSet) error {
 	if pv.headerFailureTS == header.Timestamp {
 		return errors.New("val semantic fails on poison timestamp")
@@ -538,6 +595,153 @@ func TestStoresMessageReceipts(t *testing.T) {
 	assert.Len(t, receipts, 4)
 }
 
+func TestHandleImportedChain(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	builder, store, syncer := setup(ctx, t)
+	genesis := builder.RequireTipSet(store.GetHead())
+
+	t1 := builder.AppendOn(genesis, 2)
+	t2 := builder.AppendOn(t1, 1)
+	t3 := builder.AppendOn(t2, 3)
+
+	require.NoError(t, syncer.HandleImportedChain(ctx, []block.TipSet{t1, t2, t3}, 0))
+	for _, ts := range []block.TipSet{t1, t2, t3} {
+		verifyTip(t, store, ts, builder.StateForKey(ts.Key()))
+	}
+	require.NoError(t, syncer.SetStagedHead(ctx))
+	verifyHead(t, store, t3)
+}
+
+// recordingEvaluator records the tipsets it runs state transitions for.
+type recordingEvaluator struct {
+	chain.FakeStateEvaluator
+	executed []block.TipSet
+}
+
+func (e *recordingEvaluator) RunStateTransition(ctx context.Context, ts block.TipSet, blsMessages [][]*types.UnsignedMessage, secpMessages [][]*types.SignedMessage, ancestors []block.TipSet, parentWeight fbig.Int, stateID cid.Cid, receiptCid cid.Cid) (cid.Cid, []*types.MessageReceipt, error) {
+	e.executed = append(e.executed, ts)
+	return e.FakeStateEvaluator.RunStateTransition(ctx, ts, blsMessages, secpMessages, ancestors, parentWeight, stateID, receiptCid)
+}
+
+func TestHandleImportedChainTrustsStateUpToHeight(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	t.Run("trusted tipsets take their state from their children", func(t *testing.T) {
+		eval := &recordingEvaluator{}
+		builder, store, syncer := setupWithValidator(ctx, t, eval, eval)
+		genesis := builder.RequireTipSet(store.GetHead())
+		t1 := builder.AppendOn(genesis, 1)
+		t2 := builder.AppendOn(t1, 2)
+		t3 := builder.AppendOn(t2, 1)
+		t4 := builder.AppendOn(t3, 1)
+
+		require.NoError(t, syncer.HandleImportedChain(ctx, []block.TipSet{t1, t2, t3, t4}, 2))
+		assert.Equal(t, []block.TipSet{t3, t4}, eval.executed)
+		verifyTip(t, store, t1, t2.At(0).StateRoot.Cid)
+		verifyTip(t, store, t2, t3.At(0).StateRoot.Cid)
+		verifyTip(t, store, t4, builder.StateForKey(t4.Key()))
+		require.NoError(t, syncer.SetStagedHead(ctx))
+		verifyHead(t, store, t4)
+	})
+
+	t.Run("the head is always executed", func(t *testing.T) {
+		eval := &recordingEvaluator{}
+		builder, store, syncer := setupWithValidator(ctx, t, eval, eval)
+		genesis := builder.RequireTipSet(store.GetHead())
+		t1 := builder.AppendOn(genesis, 1)
+		t2 := builder.AppendOn(t1, 1)
+
+		require.NoError(t, syncer.HandleImportedChain(ctx, []block.TipSet{t1, t2}, 10))
+		assert.Equal(t, []block.TipSet{t2}, eval.executed)
+	})
+}
+
+func TestHandleImportedChainRejectsInvalidChain(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	eval := newPoisonValidator(t, 98, 99)
+	builder, store, syncer := setupWithValidator(ctx, t, eval, eval)
+	genesis := builder.RequireTipSet(store.GetHead())
+
+	t1 := builder.BuildOneOn(genesis, func(bb *chain.BlockBuilder) {
+		bb.SetTimestamp(99) // poison state transition
+	})
+	t2 := builder.AppendOn(t1, 1)
+	t3 := builder.AppendOn(t2, 1)
+
+	err := syncer.HandleImportedChain(ctx, []block.TipSet{t1, t2, t3}, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "run state transition fails")
+
+	assert.False(t, store.HasTipSetAndState(ctx, t1.Key()))
+	assert.False(t, store.HasTipSetAndState(ctx, t3.Key()))
+	require.NoError(t, syncer.SetStagedHead(ctx))
+	verifyHead(t, store, genesis)
+}
+
+// recordingFetcher records the keys of the tipsets fetched through it.
+type recordingFetcher struct {
+	*chain.Builder
+	fetched []block.TipSetKey
+}
+
+func (f *recordingFetcher) FetchTipSets(ctx context.Context, key block.TipSetKey, from peer.ID, done func(block.TipSet) (bool, error)) ([]block.TipSet, error) {
+	f.fetched = append(f.fetched, key)
+	return f.Builder.FetchTipSets(ctx, key, from, done)
+}
+
+func TestRepairChain(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	eval := &recordingEvaluator{}
+	builder, store, s := setupWithValidator(ctx, t, eval, eval)
+	genesis := builder.RequireTipSet(store.GetHead())
+	t1 := builder.AppendOn(genesis, 1)
+	t2 := builder.AppendOn(t1, 2)
+	t3 := builder.AppendOn(t2, 1)
+	require.NoError(t, s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", t3.Key(), heightFromTip(t, t3)), false))
+
+	fetcher := &recordingFetcher{Builder: builder}
+	repairer, err := syncer.NewSyncer(eval, eval, &chain.FakeChainSelector{}, store, builder, fetcher, status.NewReporter(), th.NewFakeClock(time.Unix(1234567890, 0)), &noopFaultDetector{})
+	require.NoError(t, err)
+	require.NoError(t, repairer.InitStaged())
+
+	t.Run("missing messages are fetched", func(t *testing.T) {
+		eval.executed, fetcher.fetched = nil, nil
+		report := &chain.FsckReport{Problems: []*chain.FsckProblem{
+			{TipSet: t2.Key(), Height: 2, Kind: chain.FsckMessages},
+		}}
+		require.NoError(t, repairer.RepairChain(ctx, report))
+		assert.Equal(t, []block.TipSetKey{t2.Key()}, fetcher.fetched)
+		assert.Empty(t, eval.executed)
+	})
+
+	t.Run("broken state is recomputed from the lowest tipset up", func(t *testing.T) {
+		eval.executed, fetcher.fetched = nil, nil
+		report := &chain.FsckReport{Problems: []*chain.FsckProblem{
+			{TipSet: t3.Key(), Height: 3, Kind: chain.FsckState},
+			{TipSet: t1.Key(), Height: 1, Kind: chain.FsckReceipts},
+		}}
+		require.NoError(t, repairer.RepairChain(ctx, report))
+		assert.Empty(t, fetcher.fetched)
+		assert.Equal(t, []block.TipSet{t1, t3}, eval.executed)
+		verifyTip(t, store, t1, builder.StateForKey(t1.Key()))
+		verifyTip(t, store, t3, builder.StateForKey(t3.Key()))
+	})
+
+	t.Run("pruned state is not recomputed", func(t *testing.T) {
+		eval.executed, fetcher.fetched = nil, nil
+		report := &chain.FsckReport{PrunedHeight: 3, Problems: []*chain.FsckProblem{
+			{TipSet: t3.Key(), Height: 3, Kind: chain.FsckState},
+			{TipSet: t1.Key(), Height: 1, Kind: chain.FsckState},
+		}}
+		require.NoError(t, repairer.RepairChain(ctx, report))
+		assert.Equal(t, []block.TipSet{t3}, eval.executed)
+	})
+}
+
 ///// Set-up /////
 
 // Initializes a chain builder, store and syncer.
@@ -25,6 +25,8 @@ type Config struct {
 	Mpool         *MessagePoolConfig   `json:"mpool"`
 	Observability *ObservabilityConfig `json:"observability"`
 	SectorBase    *SectorBaseConfig    `json:"sectorbase"`
+	Slashing      *SlashingConfig      `json:"slashing"`
+	StateGC       *StateGCConfig       `json:"stategc"`
 	Swarm         *SwarmConfig         `json:"swarm"`
 	Wallet        *WalletConfig        `json:"wallet"`
 }
@@ -206,12 +208,39 @@ type MessagePoolConfig struct {
 	MaxPoolSize uint `json:"maxPoolSize"`
 	// MaxNonceGap is the maximum nonce of a message past the last received on chain
 	MaxNonceGap types.Uint64 `json:"maxNonceGap"`
+	// ReplaceByFeePercent is the minimum percentage by which the gas price of a message must
+	// exceed that of a pending message with the same sender and nonce to replace it
+	ReplaceByFeePercent uint `json:"replaceByFeePercent"`
+	// MaxPendingPerSender is the maximum number of pending messages from a sender other than
+	// the node's own addresses, zero for no limit
+	MaxPendingPerSender uint `json:"maxPendingPerSender"`
+	// PeerMessageRate is the number of messages per second a peer may relay to us on the message
+	// topic once its burst is used, zero for no limit
+	PeerMessageRate uint `json:"peerMessageRate"`
+	// PeerMessageBurst is the number of messages a peer may relay at once on the message topic
+	PeerMessageBurst uint `json:"peerMessageBurst"`
+	// MaxPeerInvalidMessages is the number of invalid messages in excess of valid ones after which
+	// a peer is dropped, zero to never drop peers
+	MaxPeerInvalidMessages uint `json:"maxPeerInvalidMessages"`
+	// RebroadcastInterval is how long the node waits for a message it sent to be mined before
+	// broadcasting it again. The wait doubles after each rebroadcast.
+	RebroadcastInterval string `json:"rebroadcastInterval"`
+	// MaxRebroadcasts is the number of times a message the node sent is broadcast again while
+	// it is not mined, zero to never rebroadcast
+	MaxRebroadcasts uint `json:"maxRebroadcasts"`
 }
 
 func newDefaultMessagePoolConfig() *MessagePoolConfig {
 	return &MessagePoolConfig{
-		MaxPoolSize: 10000,
-		MaxNonceGap: 100,
+		MaxPoolSize:            10000,
+		MaxNonceGap:            100,
+		ReplaceByFeePercent:    25,
+		MaxPendingPerSender:    1000,
+		PeerMessageRate:        100,
+		PeerMessageBurst:       1000,
+		MaxPeerInvalidMessages: 100,
+		RebroadcastInterval:    "1m",
+		MaxRebroadcasts:        5,
 	}
 }
 
@@ -229,6 +258,40 @@ func newDefaultSectorbaseConfig() *SectorBaseConfig {
 	}
 }
 
+// SlashingConfig holds all configuration options related to reporting consensus faults.
+type SlashingConfig struct {
+	// ReporterAddress is the wallet address sending the reports of consensus faults detected
+	// by the node. Faults are only recorded if it is empty.
+	ReporterAddress address.Address `json:"reporterAddress"`
+}
+
+func newDefaultSlashingConfig() *SlashingConfig {
+	return &SlashingConfig{
+		ReporterAddress: address.Undef,
+	}
+}
+
+// StateGCConfig holds all configuration options related to pruning old chain
+// state from the node's blockstore.
+type StateGCConfig struct {
+	// AutoPrune enables periodic pruning of chain state while the node runs.
+	AutoPrune bool `json:"autoPrune"`
+	// KeepEpochs is the number of finalized epochs below the finality
+	// threshold for which full state is kept.
+	KeepEpochs uint64 `json:"keepEpochs"`
+	// Period represents how frequently automatic pruning runs.
+	// Golang duration units are accepted.
+	Period string `json:"period"`
+}
+
+func newDefaultStateGCConfig() *StateGCConfig {
+	return &StateGCConfig{
+		AutoPrune:  false,
+		KeepEpochs: 1000,
+		Period:     "1h",
+	}
+}
+
 // NewDefaultConfig returns a config object with all the fields filled out to
 // their default values
 func NewDefaultConfig() *Config {
@@ -242,6 +305,8 @@ func NewDefaultConfig() *Config {
 		Heartbeat:     newDefaultHeartbeatConfig(),
 		Mpool:         newDefaultMessagePoolConfig(),
 		SectorBase:    newDefaultSectorbaseConfig(),
+		Slashing:      newDefaultSlashingConfig(),
+		StateGC:       newDefaultStateGCConfig(),
 		Observability: newDefaultObservabilityConfig(),
 	}
 }
@@ -79,7 +79,14 @@ func TestWriteFile(t *testing.T) {
 	},
 	"mpool": {
 		"maxPoolSize": 10000,
-		"maxNonceGap": "100"
+		"maxNonceGap": "100",
+		"replaceByFeePercent": 25,
+		"maxPendingPerSender": 1000,
+		"peerMessageRate": 100,
+		"peerMessageBurst": 1000,
+		"maxPeerInvalidMessages": 100,
+		"rebroadcastInterval": "1m",
+		"maxRebroadcasts": 5
 	},
 	"observability": {
 		"metrics": {
@@ -96,6 +103,14 @@ func TestWriteFile(t *testing.T) {
 	"sectorbase": {
 		"rootdir": ""
 	},
+	"slashing": {
+		"reporterAddress": "\u003cempty\u003e"
+	},
+	"stategc": {
+		"autoPrune": false,
+		"keepEpochs": 1000,
+		"period": "1h"
+	},
 	"swarm": {
 		"address": "/ip4/0.0.0.0/tcp/6000"
 	},
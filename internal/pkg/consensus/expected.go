@@ -122,13 +122,16 @@ type Expected struct {
 
 	// postVerifier verifies PoSt proofs and associated data
 	postVerifier verification.PoStVerifier
+
+	// sigVerifier verifies the signatures of the messages in blocks
+	sigVerifier *SignatureVerifier
 }
 
 // Ensure Expected satisfies the Protocol interface at compile time.
 var _ Protocol = (*Expected)(nil)
 
 // NewExpected is the constructor for the Expected consenus.Protocol module.
-func NewExpected(cs cbor.IpldStore, bs blockstore.Blockstore, processor Processor, actorState SnapshotGenerator, bt time.Duration, ev ElectionValidator, tv TicketValidator, pv verification.PoStVerifier) *Expected {
+func NewExpected(cs cbor.IpldStore, bs blockstore.Blockstore, processor Processor, actorState SnapshotGenerator, bt time.Duration, ev ElectionValidator, tv TicketValidator, pv verification.PoStVerifier, sv *SignatureVerifier) *Expected {
 	return &Expected{
 		cstore:            cs,
 		blockTime:         bt,
@@ -138,6 +141,7 @@ func NewExpected(cs cbor.IpldStore, bs blockstore.Blockstore, processor Processo
 		ElectionValidator: ev,
 		TicketValidator:   tv,
 		postVerifier:      pv,
+		sigVerifier:       sv,
 	}
 }
 
@@ -163,9 +167,14 @@ func (c *Expected) RunStateTransition(ctx context.Context, ts block.TipSet, blsM
 		return cid.Undef, []*types.MessageReceipt{}, err
 	}
 
-	vms := vm.NewStorage(c.bstore)
-	var st state.Tree
-	st, receipts, err = c.runMessages(ctx, priorState, vms, ts, blsMessages, secpMessages, ancestors)
+	return applyTipSet(ctx, c.processor, c.bstore, priorState, ts, blsMessages, secpMessages)
+}
+
+// applyTipSet runs the messages of a tipset on the prior state and persists the new state,
+// returning its root.
+func applyTipSet(ctx context.Context, processor Processor, bs blockstore.Blockstore, priorState state.Tree, ts block.TipSet, blsMessages [][]*types.UnsignedMessage, secpMessages [][]*types.SignedMessage) (cid.Cid, []*types.MessageReceipt, error) {
+	vms := vm.NewStorage(bs)
+	st, receipts, err := runMessages(ctx, processor, priorState, vms, ts, blsMessages, secpMessages)
 	if err != nil {
 		return cid.Undef, []*types.MessageReceipt{}, err
 	}
@@ -174,7 +183,7 @@ func (c *Expected) RunStateTransition(ctx context.Context, ts block.TipSet, blsM
 		return cid.Undef, []*types.MessageReceipt{}, err
 	}
 
-	root, err = st.Flush(ctx)
+	root, err := st.Flush(ctx)
 	if err != nil {
 		return cid.Undef, []*types.MessageReceipt{}, err
 	}
@@ -235,18 +244,6 @@ func (c *Expected) validateMining(
 			return errors.New("block signature invalid")
 		}
 
-		// Verify that the BLS signature is correct
-		if err := verifyBLSMessageAggregate(blk.BLSAggregateSig, blsMsgs[i]); err != nil {
-			return errors.Wrapf(err, "bls message verification failed for block %s", blk.Cid())
-		}
-
-		// Verify that all secp message signatures are correct
-		for i, msg := range secpMsgs[i] {
-			if !msg.VerifySignature() {
-				return errors.Errorf("secp message signature invalid for message, %d, in block %s", i, blk.Cid())
-			}
-		}
-
 		// Verify PoStRandomness
 		nullBlkCount := blk.Height - prevHeight - 1
 		if !c.VerifyPoStRandomness(blk.EPoStInfo.PoStRandomness, electionTicket, workerAddr, nullBlkCount) {
@@ -292,7 +289,9 @@ func (c *Expected) validateMining(
 			return errors.Errorf("invalid ticket: %s in block %s", blk.Ticket.String(), blk.Cid().String())
 		}
 	}
-	return nil
+
+	// Verify that the BLS aggregate signatures and all secp message signatures are correct
+	return c.sigVerifier.VerifyTipSetMessages(ctx, ts, blsMsgs, secpMsgs)
 }
 
 // runMessages applies the messages of all blocks within the input
@@ -301,7 +300,7 @@ func (c *Expected) validateMining(
 // for the entire tipset. The output state must be flushed after calling to
 // guarantee that the state transitions propagate.
 // Messages that fail to apply are dropped on the floor (and no receipt is emitted).
-func (c *Expected) runMessages(ctx context.Context, st state.Tree, vms vm.Storage, ts block.TipSet, blsMessages [][]*types.UnsignedMessage, secpMessages [][]*types.SignedMessage, ancestors []block.TipSet) (state.Tree, []*types.MessageReceipt, error) {
+func runMessages(ctx context.Context, processor Processor, st state.Tree, vms vm.Storage, ts block.TipSet, blsMessages [][]*types.UnsignedMessage, secpMessages [][]*types.SignedMessage) (state.Tree, []*types.MessageReceipt, error) {
 	msgs := []vm.BlockMessagesInfo{}
 
 	// build message information per block
@@ -318,7 +317,7 @@ func (c *Expected) runMessages(ctx context.Context, st state.Tree, vms vm.Storag
 	}
 
 	// process tipset
-	receipts, err := c.processor.ProcessTipSet(ctx, st, vms, ts, msgs)
+	receipts, err := processor.ProcessTipSet(ctx, st, vms, ts, msgs)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "error validating tipset")
 	}
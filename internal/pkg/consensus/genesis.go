@@ -15,6 +15,7 @@ import (
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
 	typegen "github.com/whyrusleeping/cbor-gen"
 
 	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
@@ -60,6 +61,7 @@ type Config struct {
 	network          string
 	proofsMode       types.ProofsMode
 	genesisTimestamp time.Time
+	instantSeal      *InstantSealParams
 }
 
 // GenOption is a configuration option for the GenesisInitFunction.
@@ -134,6 +136,22 @@ func GenesisTime(genesisTimestamp time.Time) GenOption {
 	}
 }
 
+// InstantSeal selects instant-seal consensus for the network, with blocks signed by `signer`
+// and sealed every `interval` when no messages arrive, or only when messages arrive if the
+// interval is zero.
+func InstantSeal(signer address.Address, interval time.Duration) GenOption {
+	return func(gc *Config) error {
+		if signer.Empty() {
+			return errors.New("instant-seal consensus requires a signer")
+		}
+		if interval < 0 || interval%time.Second != 0 {
+			return errors.Errorf("invalid instant-seal interval %s, must be a whole number of seconds", interval)
+		}
+		gc.instantSeal = &InstantSealParams{Signer: signer, Interval: interval}
+		return nil
+	}
+}
+
 var defaultGenesisTimestamp = time.Unix(123456789, 0)
 
 // NewEmptyConfig inits and returns an empty config
@@ -172,6 +190,11 @@ func MakeGenesisFunc(opts ...GenOption) GenesisInitFunc {
 		if err := SetupDefaultActors(ctx, vm, &store, st, genCfg.proofsMode, genCfg.network); err != nil {
 			return nil, err
 		}
+		if genCfg.instantSeal != nil {
+			if err := recordInstantSeal(ctx, &store, st, genCfg.instantSeal); err != nil {
+				return nil, err
+			}
+		}
 
 		// sort addresses so genesis generation will be stable
 		sortedAddresses := []string{}
@@ -269,6 +292,30 @@ func MakeGenesisFunc(opts ...GenOption) GenesisInitFunc {
 	}
 }
 
+// recordInstantSeal records the instant-seal consensus parameters in the init actor state, so
+// that every node of the network agrees on them.
+func recordInstantSeal(ctx context.Context, store *vm.Storage, st state.Tree, params *InstantSealParams) error {
+	initActor, err := st.GetActor(ctx, vmaddr.InitAddress)
+	if err != nil {
+		return errors.Wrap(err, "failed to get init actor")
+	}
+	var initState initactor.State
+	if err := store.Get(initActor.Head.Cid, &initState); err != nil {
+		return errors.Wrap(err, "failed to load init actor state")
+	}
+
+	initState.Consensus = InstantSealProtocol
+	initState.SealSigner = params.Signer.Bytes()
+	initState.SealInterval = types.Uint64(params.Interval / time.Second)
+
+	head, err := store.Put(initState)
+	if err != nil {
+		return errors.Wrap(err, "failed to store init actor state")
+	}
+	initActor.Head = e.NewCid(head)
+	return st.SetActor(ctx, vmaddr.InitAddress, initActor)
+}
+
 // SetupDefaultActors inits the builtin actors that are required to run filecoin.
 func SetupDefaultActors(ctx context.Context, vm GenesisVM, store *vm.Storage, st state.Tree, storeType types.ProofsMode, network string) error {
 	createActor := func(addr address.Address, codeCid cid.Cid, state interface{}) *actor.Actor {
@@ -0,0 +1,160 @@
+package consensus
+
+// Instant-seal is a consensus protocol for development networks. A single authorized signer
+// produces every block, as soon as messages arrive or at a fixed interval, without elections
+// or proofs of spacetime. It is selected in the genesis block so every node of the network
+// agrees on it.
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics/tracing"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin/initactor"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+)
+
+// InstantSealProtocol is the name recorded in genesis for instant-seal consensus.
+const InstantSealProtocol = "instant-seal"
+
+// InstantSealBlockTime is the duration of an epoch under instant-seal consensus. At most one
+// block is sealed per epoch, so it bounds the delay before pending messages are included.
+const InstantSealBlockTime = time.Second
+
+// InstantSealParams are the parameters of an instant-seal network.
+type InstantSealParams struct {
+	// Signer is the only address allowed to sign blocks.
+	Signer address.Address
+	// Interval is the time between blocks sealed when no messages arrive. Zero means blocks
+	// are only sealed when messages arrive.
+	Interval time.Duration
+}
+
+// LoadInstantSealParams reads the instant-seal parameters recorded in the genesis block. It
+// returns nil if the network runs expected consensus.
+func LoadInstantSealParams(ctx context.Context, cst cbor.IpldStore, genesis *block.Block) (*InstantSealParams, error) {
+	st, err := state.NewTreeLoader().LoadStateTree(ctx, cst, genesis.StateRoot.Cid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load genesis state %s", genesis.StateRoot.Cid)
+	}
+	initActor, err := st.GetActor(ctx, vmaddr.InitAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get init actor")
+	}
+	var initState initactor.State
+	if err := cst.Get(ctx, initActor.Head.Cid, &initState); err != nil {
+		return nil, errors.Wrap(err, "failed to load init actor state")
+	}
+
+	switch initState.Consensus {
+	case "":
+		return nil, nil
+	case InstantSealProtocol:
+		signer, err := address.NewFromBytes(initState.SealSigner)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid instant-seal signer in genesis")
+		}
+		return &InstantSealParams{
+			Signer:   signer,
+			Interval: time.Duration(initState.SealInterval) * time.Second,
+		}, nil
+	default:
+		return nil, errors.Errorf("unknown consensus protocol %q in genesis", initState.Consensus)
+	}
+}
+
+// InstantSeal implements instant-seal consensus: a tipset is valid if each of its blocks is
+// signed by the authorized signer, with a ticket derived from the parent's by that signer.
+type InstantSeal struct {
+	TicketValidator
+
+	cstore      cbor.IpldStore
+	bstore      blockstore.Blockstore
+	processor   Processor
+	signer      address.Address
+	sigVerifier *SignatureVerifier
+}
+
+// Ensure InstantSeal satisfies the Protocol interface at compile time.
+var _ Protocol = (*InstantSeal)(nil)
+
+// NewInstantSeal is the constructor for the instant-seal consensus.Protocol module.
+func NewInstantSeal(cs cbor.IpldStore, bs blockstore.Blockstore, processor Processor, signer address.Address, tv TicketValidator, sv *SignatureVerifier) *InstantSeal {
+	return &InstantSeal{
+		TicketValidator: tv,
+		cstore:          cs,
+		bstore:          bs,
+		processor:       processor,
+		signer:          signer,
+		sigVerifier:     sv,
+	}
+}
+
+// BlockTime returns the block time used by the consensus protocol.
+func (c *InstantSeal) BlockTime() time.Duration {
+	return InstantSealBlockTime
+}
+
+// RunStateTransition applies the messages in a tipset to a state, and persists that new state.
+// It errors if a block of the tipset was not sealed by the authorized signer, or if any of the
+// messages in the tipset results in an error.
+func (c *InstantSeal) RunStateTransition(ctx context.Context, ts block.TipSet, blsMessages [][]*types.UnsignedMessage, secpMessages [][]*types.SignedMessage, ancestors []block.TipSet, parentWeight fbig.Int, parentStateRoot cid.Cid, parentReceiptRoot cid.Cid) (root cid.Cid, receipts []*types.MessageReceipt, err error) {
+	ctx, span := trace.StartSpan(ctx, "InstantSeal.RunStateTransition")
+	span.AddAttributes(trace.StringAttribute("tipset", ts.String()))
+	defer tracing.AddErrorEndSpan(ctx, span, &err)
+
+	if err := c.validateSeal(ctx, ts, ancestors[0], blsMessages, secpMessages, parentWeight, parentStateRoot, parentReceiptRoot); err != nil {
+		return cid.Undef, []*types.MessageReceipt{}, err
+	}
+
+	priorState, err := state.NewTreeLoader().LoadStateTree(ctx, c.cstore, parentStateRoot)
+	if err != nil {
+		return cid.Undef, []*types.MessageReceipt{}, err
+	}
+	return applyTipSet(ctx, c.processor, c.bstore, priorState, ts, blsMessages, secpMessages)
+}
+
+// validateSeal checks the signer, signature and ticket of every block in the tipset, and the
+// signatures of their messages.
+func (c *InstantSeal) validateSeal(ctx context.Context, ts block.TipSet, parentTs block.TipSet, blsMsgs [][]*types.UnsignedMessage, secpMsgs [][]*types.SignedMessage, parentWeight fbig.Int, parentStateRoot cid.Cid, parentReceiptRoot cid.Cid) error {
+	prevTicket, err := parentTs.MinTicket()
+	if err != nil {
+		return errors.Wrap(err, "failed to read parent min ticket")
+	}
+
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+
+		if !parentStateRoot.Equals(blk.StateRoot.Cid) {
+			return ErrStateRootMismatch
+		}
+		if !parentReceiptRoot.Equals(blk.MessageReceipts.Cid) {
+			return ErrReceiptRootMismatch
+		}
+		if !parentWeight.Equals(blk.ParentWeight) {
+			return errors.Errorf("block %s has invalid parent weight %d", blk.Cid().String(), parentWeight)
+		}
+
+		if blk.Miner != c.signer {
+			return errors.Errorf("block %s sealed by %s, not the authorized signer %s", blk.Cid().String(), blk.Miner, c.signer)
+		}
+		if valid := types.IsValidSignature(blk.SignatureData(), c.signer, blk.BlockSig); !valid {
+			return errors.New("block signature invalid")
+		}
+		if !c.IsValidTicket(prevTicket, blk.Ticket, c.signer) {
+			return errors.Errorf("invalid ticket: %s in block %s", blk.Ticket.String(), blk.Cid().String())
+		}
+	}
+	return c.sigVerifier.VerifyTipSetMessages(ctx, ts, blsMsgs, secpMsgs)
+}
@@ -0,0 +1,84 @@
+package consensus_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+func TestLoadInstantSealParams(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer, _ := types.NewMockSignersAndKeyInfo(1)
+
+	t.Run("expected consensus by default", func(t *testing.T) {
+		cst, bs := setupCborBlockstore()
+		genesis, err := consensus.MakeGenesisFunc()(cst, bs)
+		require.NoError(t, err)
+
+		params, err := consensus.LoadInstantSealParams(ctx, cst, genesis)
+		require.NoError(t, err)
+		assert.Nil(t, params)
+	})
+
+	t.Run("recorded in genesis", func(t *testing.T) {
+		cst, bs := setupCborBlockstore()
+		genesis, err := consensus.MakeGenesisFunc(consensus.InstantSeal(signer.Addresses[0], 5*time.Second))(cst, bs)
+		require.NoError(t, err)
+
+		params, err := consensus.LoadInstantSealParams(ctx, cst, genesis)
+		require.NoError(t, err)
+		assert.Equal(t, &consensus.InstantSealParams{Signer: signer.Addresses[0], Interval: 5 * time.Second}, params)
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		cst, bs := setupCborBlockstore()
+		_, err := consensus.MakeGenesisFunc(consensus.InstantSeal(signer.Addresses[0], 1500*time.Millisecond))(cst, bs)
+		assert.Error(t, err)
+	})
+}
+
+func TestInstantSeal_RunStateTransition(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer, _ := types.NewMockSignersAndKeyInfo(2)
+	sealer, other := signer.Addresses[0], signer.Addresses[1]
+
+	cst, bs := setupCborBlockstore()
+	genesis, err := consensus.MakeGenesisFunc(consensus.InstantSeal(sealer, 0))(cst, bs)
+	require.NoError(t, err)
+	parent := th.RequireNewTipSet(t, genesis)
+	protocol := consensus.NewInstantSeal(cst, bs, th.NewFakeProcessor(), sealer, &consensus.FakeTicketMachine{}, consensus.NewSignatureVerifier(nil, 1))
+
+	runStateTransition := func(blk *block.Block) error {
+		blsMsgs, secpMsgs := emptyMessages(1)
+		_, _, err := protocol.RunStateTransition(ctx, th.RequireNewTipSet(t, blk), blsMsgs, secpMsgs, []block.TipSet{parent}, blk.ParentWeight, genesis.StateRoot.Cid, genesis.MessageReceipts.Cid)
+		return err
+	}
+
+	t.Run("accepts a block sealed by the signer", func(t *testing.T) {
+		blk := th.RequireSignedTestBlockFromTipSet(t, parent, genesis.StateRoot.Cid, genesis.MessageReceipts.Cid, 1, sealer, sealer, signer)
+		assert.NoError(t, runStateTransition(blk))
+	})
+
+	t.Run("rejects a block from another miner", func(t *testing.T) {
+		blk := th.RequireSignedTestBlockFromTipSet(t, parent, genesis.StateRoot.Cid, genesis.MessageReceipts.Cid, 1, other, other, signer)
+		assert.Error(t, runStateTransition(blk))
+	})
+
+	t.Run("rejects a block not signed by the signer", func(t *testing.T) {
+		blk := th.RequireSignedTestBlockFromTipSet(t, parent, genesis.StateRoot.Cid, genesis.MessageReceipts.Cid, 1, sealer, other, signer)
+		assert.Error(t, runStateTransition(blk))
+	})
+}
@@ -0,0 +1,182 @@
+package consensus
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// DefaultSignatureCacheSize is the number of verified messages a node remembers.
+const DefaultSignatureCacheSize = 1 << 16
+
+// DefaultSignatureWorkers is the number of signatures a node verifies concurrently.
+var DefaultSignatureWorkers = runtime.NumCPU()
+
+var signatureCacheHitCt *metrics.Int64Counter
+var signatureCacheMissCt *metrics.Int64Counter
+var tipSetSignaturesTimer *metrics.Float64Timer
+
+func init() {
+	signatureCacheHitCt = metrics.NewInt64Counter("consensus/signature_cache_hit", "Number of message signatures found already verified in the signature cache")
+	signatureCacheMissCt = metrics.NewInt64Counter("consensus/signature_cache_miss", "Number of message signatures not found in the signature cache")
+	tipSetSignaturesTimer = metrics.NewTimerMs("consensus/tipset_signature_verification", "Duration of verifying the message signatures of a tipset in milliseconds")
+}
+
+// SignatureCache remembers the signed messages whose signatures are valid, by CID. The CID of a
+// signed message covers its signature, so a message found in the cache needs no verification.
+// This lets messages verified when entering the message pool be trusted when they are included
+// in a block. It holds a bounded number of messages, forgetting the oldest first.
+//
+// A nil cache remembers nothing.
+type SignatureCache struct {
+	lk       sync.Mutex
+	verified map[cid.Cid]struct{}
+	// order holds the cached CIDs as a ring, next being the oldest once full.
+	order []cid.Cid
+	next  int
+}
+
+// NewSignatureCache creates a cache of the signatures of up to `size` messages.
+func NewSignatureCache(size int) *SignatureCache {
+	return &SignatureCache{
+		verified: make(map[cid.Cid]struct{}, size),
+		order:    make([]cid.Cid, 0, size),
+	}
+}
+
+// Verified returns whether the signature of the message with CID `c` is known to be valid.
+func (sc *SignatureCache) Verified(ctx context.Context, c cid.Cid) bool {
+	if sc == nil {
+		return false
+	}
+	sc.lk.Lock()
+	_, found := sc.verified[c]
+	sc.lk.Unlock()
+
+	if found {
+		signatureCacheHitCt.Inc(ctx, 1)
+	} else {
+		signatureCacheMissCt.Inc(ctx, 1)
+	}
+	return found
+}
+
+// Add records the signature of the message with CID `c` as valid.
+func (sc *SignatureCache) Add(c cid.Cid) {
+	if sc == nil {
+		return
+	}
+	sc.lk.Lock()
+	defer sc.lk.Unlock()
+
+	if _, found := sc.verified[c]; found {
+		return
+	}
+	if len(sc.order) < cap(sc.order) {
+		sc.order = append(sc.order, c)
+	} else if len(sc.order) > 0 {
+		delete(sc.verified, sc.order[sc.next])
+		sc.order[sc.next] = c
+		sc.next = (sc.next + 1) % len(sc.order)
+	} else {
+		return
+	}
+	sc.verified[c] = struct{}{}
+}
+
+// verifySignedMessage checks the signature of a signed message, trusting the cache.
+func (sc *SignatureCache) verifySignedMessage(ctx context.Context, msg *types.SignedMessage) bool {
+	c, err := msg.Cid()
+	if err != nil {
+		return msg.VerifySignature()
+	}
+	if sc.Verified(ctx, c) {
+		return true
+	}
+	if !msg.VerifySignature() {
+		return false
+	}
+	sc.Add(c)
+	return true
+}
+
+// SignatureVerifier verifies the message signatures of the blocks of a tipset with a bounded
+// number of workers.
+type SignatureVerifier struct {
+	cache   *SignatureCache
+	workers int
+}
+
+// NewSignatureVerifier creates a verifier running up to `workers` verifications at once and
+// skipping messages found in `cache`, which may be nil.
+func NewSignatureVerifier(cache *SignatureCache, workers int) *SignatureVerifier {
+	if workers < 1 {
+		workers = 1
+	}
+	return &SignatureVerifier{
+		cache:   cache,
+		workers: workers,
+	}
+}
+
+// VerifyTipSetMessages checks, for every block of the tipset, that the block's BLS aggregate
+// signature is valid for its BLS messages and that each of its secp messages is validly signed.
+// The signatures of all the blocks are verified concurrently.
+func (sv *SignatureVerifier) VerifyTipSetMessages(ctx context.Context, ts block.TipSet, blsMsgs [][]*types.UnsignedMessage, secpMsgs [][]*types.SignedMessage) error {
+	sw := tipSetSignaturesTimer.Start(ctx)
+	defer sw.Stop(ctx)
+
+	var checks []func() error
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		blkBLSMsgs := blsMsgs[i]
+		checks = append(checks, func() error {
+			if err := verifyBLSMessageAggregate(blk.BLSAggregateSig, blkBLSMsgs); err != nil {
+				return errors.Wrapf(err, "bls message verification failed for block %s", blk.Cid())
+			}
+			return nil
+		})
+		for j, msg := range secpMsgs[i] {
+			j, msg := j, msg
+			checks = append(checks, func() error {
+				if !sv.cache.verifySignedMessage(ctx, msg) {
+					return errors.Errorf("secp message signature invalid for message, %d, in block %s", j, blk.Cid())
+				}
+				return nil
+			})
+		}
+	}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	pending := make(chan func() error)
+	for w := 0; w < sv.workers; w++ {
+		group.Go(func() error {
+			for check := range pending {
+				if err := check(); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	group.Go(func() error {
+		defer close(pending)
+		for _, check := range checks {
+			select {
+			case pending <- check:
+			case <-groupCtx.Done():
+				return nil
+			}
+		}
+		return nil
+	})
+	return group.Wait()
+}
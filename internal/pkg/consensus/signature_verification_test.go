@@ -0,0 +1,127 @@
+package consensus_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+func TestSignatureCache(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	c1 := types.CidFromString(t, "first")
+	c2 := types.CidFromString(t, "second")
+	c3 := types.CidFromString(t, "third")
+
+	t.Run("remembers added messages", func(t *testing.T) {
+		cache := consensus.NewSignatureCache(2)
+		assert.False(t, cache.Verified(ctx, c1))
+
+		cache.Add(c1)
+		assert.True(t, cache.Verified(ctx, c1))
+		assert.False(t, cache.Verified(ctx, c2))
+	})
+
+	t.Run("forgets the oldest message when full", func(t *testing.T) {
+		cache := consensus.NewSignatureCache(2)
+		cache.Add(c1)
+		cache.Add(c2)
+		cache.Add(c1)
+		cache.Add(c3)
+
+		assert.False(t, cache.Verified(ctx, c1))
+		assert.True(t, cache.Verified(ctx, c2))
+		assert.True(t, cache.Verified(ctx, c3))
+	})
+
+	t.Run("nil cache remembers nothing", func(t *testing.T) {
+		var cache *consensus.SignatureCache
+		cache.Add(c1)
+		assert.False(t, cache.Verified(ctx, c1))
+	})
+}
+
+func TestSignatureVerifier_VerifyTipSetMessages(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer, _ := types.NewMockSignersAndKeyInfo(2)
+	from, to := signer.Addresses[0], signer.Addresses[1]
+
+	cst, bs := setupCborBlockstore()
+	genesis, err := consensus.MakeGenesisFunc()(cst, bs)
+	require.NoError(t, err)
+	parent := th.RequireNewTipSet(t, genesis)
+
+	// makeTipSet creates a tipset of n blocks with two signed messages each.
+	makeTipSet := func(n int) (block.TipSet, [][]*types.UnsignedMessage, [][]*types.SignedMessage) {
+		var blks []*block.Block
+		blsMsgs, secpMsgs := emptyMessages(n)
+		nonce := uint64(0)
+		for i := 0; i < n; i++ {
+			blk := th.RequireSignedTestBlockFromTipSet(t, parent, genesis.StateRoot.Cid, genesis.MessageReceipts.Cid, 1, from, from, signer)
+			blk.Timestamp = uint64(i)
+			blks = append(blks, blk)
+			for j := 0; j < 2; j++ {
+				msg := types.NewMeteredMessage(from, to, nonce, types.ZeroAttoFIL, types.SendMethodID, nil, types.NewGasPrice(1), types.NewGasUnits(100))
+				smsg, err := types.NewSignedMessage(*msg, signer)
+				require.NoError(t, err)
+				secpMsgs[i] = append(secpMsgs[i], smsg)
+				nonce++
+			}
+		}
+		return th.RequireNewTipSet(t, blks...), blsMsgs, secpMsgs
+	}
+
+	t.Run("accepts valid signatures", func(t *testing.T) {
+		ts, blsMsgs, secpMsgs := makeTipSet(3)
+		verifier := consensus.NewSignatureVerifier(nil, 2)
+		assert.NoError(t, verifier.VerifyTipSetMessages(ctx, ts, blsMsgs, secpMsgs))
+	})
+
+	t.Run("rejects an invalid secp signature", func(t *testing.T) {
+		ts, blsMsgs, secpMsgs := makeTipSet(3)
+		secpMsgs[2][1].Signature = secpMsgs[2][0].Signature
+
+		verifier := consensus.NewSignatureVerifier(nil, 2)
+		err := verifier.VerifyTipSetMessages(ctx, ts, blsMsgs, secpMsgs)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "secp message signature invalid")
+	})
+
+	t.Run("caches verified messages", func(t *testing.T) {
+		ts, blsMsgs, secpMsgs := makeTipSet(2)
+		cache := consensus.NewSignatureCache(consensus.DefaultSignatureCacheSize)
+		verifier := consensus.NewSignatureVerifier(cache, 2)
+		require.NoError(t, verifier.VerifyTipSetMessages(ctx, ts, blsMsgs, secpMsgs))
+
+		for _, msgs := range secpMsgs {
+			for _, msg := range msgs {
+				c, err := msg.Cid()
+				require.NoError(t, err)
+				assert.True(t, cache.Verified(ctx, c))
+			}
+		}
+	})
+
+	t.Run("trusts messages found in the cache", func(t *testing.T) {
+		ts, blsMsgs, secpMsgs := makeTipSet(1)
+		secpMsgs[0][1].Signature = secpMsgs[0][0].Signature
+		c, err := secpMsgs[0][1].Cid()
+		require.NoError(t, err)
+
+		cache := consensus.NewSignatureCache(consensus.DefaultSignatureCacheSize)
+		cache.Add(c)
+		verifier := consensus.NewSignatureVerifier(cache, 1)
+		assert.NoError(t, verifier.VerifyTipSetMessages(ctx, ts, blsMsgs, secpMsgs))
+	})
+}
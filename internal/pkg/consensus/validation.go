@@ -131,14 +131,17 @@ type IngestionValidator struct {
 	api       ingestionValidatorAPI
 	cfg       *config.MessagePoolConfig
 	validator *DefaultMessageValidator
+	cache     *SignatureCache
 }
 
-// NewIngestionValidator creates a new validator with an api
-func NewIngestionValidator(api ingestionValidatorAPI, cfg *config.MessagePoolConfig) *IngestionValidator {
+// NewIngestionValidator creates a new validator with an api. Messages with valid signatures
+// are recorded in the signature cache, which may be nil.
+func NewIngestionValidator(api ingestionValidatorAPI, cfg *config.MessagePoolConfig, cache *SignatureCache) *IngestionValidator {
 	return &IngestionValidator{
 		api:       api,
 		cfg:       cfg,
 		validator: &DefaultMessageValidator{allowHighNonce: true},
+		cache:     cache,
 	}
 }
 
@@ -146,7 +149,7 @@ func NewIngestionValidator(api ingestionValidatorAPI, cfg *config.MessagePoolCon
 // Errors probably mean the validation failed, but possibly indicate a failure to retrieve state
 func (v *IngestionValidator) Validate(ctx context.Context, smsg *types.SignedMessage) error {
 	// ensure message is properly signed
-	if !smsg.VerifySignature() {
+	if !v.cache.verifySignedMessage(ctx, smsg) {
 		return errInvalidSignature
 	}
 
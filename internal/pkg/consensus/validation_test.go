@@ -107,7 +107,7 @@ func TestBLSSignatureValidationConfiguration(t *testing.T) {
 		api.Actor = actor
 
 		mpoolCfg := config.NewDefaultConfig().Mpool
-		validator := consensus.NewIngestionValidator(api, mpoolCfg)
+		validator := consensus.NewIngestionValidator(api, mpoolCfg, nil)
 
 		err := validator.Validate(ctx, unsigned)
 		require.Error(t, err)
@@ -144,7 +144,7 @@ func TestIngestionValidator(t *testing.T) {
 	api.Actor = act
 
 	mpoolCfg := config.NewDefaultConfig().Mpool
-	validator := consensus.NewIngestionValidator(api, mpoolCfg)
+	validator := consensus.NewIngestionValidator(api, mpoolCfg, nil)
 	ctx := context.Background()
 
 	t.Run("Validates extreme nonce gaps", func(t *testing.T) {
@@ -36,13 +36,13 @@ func TestNewHeadHandlerIntegration(t *testing.T) {
 	gasUnits := types.NewGasUnits(1000)
 
 	makeHandler := func(provider *message.FakeProvider, root block.TipSet) *message.HeadHandler {
-		mpool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		mpool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 		inbox := message.NewInbox(mpool, maxAge, provider, provider)
 		queue := message.NewQueue()
 		publisher := message.NewDefaultPublisher(&message.MockNetworkPublisher{}, mpool)
 		policy := message.NewMessageQueuePolicy(provider, maxAge)
 		outbox := message.NewOutbox(signer, &message.FakeValidator{}, queue, publisher, policy,
-			provider, provider, objournal)
+			provider, provider, objournal, th.NewFakeClock(time.Unix(1234567890, 0)))
 
 		return message.NewHeadHandler(inbox, outbox, provider, root)
 	}
@@ -111,7 +111,7 @@ func (ib *Inbox) HandleNewHead(ctx context.Context, oldChain, newChain []block.T
 		}
 	}
 	for _, c := range removeCids {
-		ib.pool.Remove(c)
+		ib.pool.RemoveIncluded(c)
 	}
 
 	// prune all messages that have been in the pool too long
@@ -30,7 +30,7 @@ func TestUpdateMessagePool(t *testing.T) {
 		// to
 		// Msg pool: [m0],     Chain: b[m1]
 		chainProvider, parent := newProviderWithGenesis(t)
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 		ib := message.NewInbox(p, 10, chainProvider, chainProvider)
 
 		m := types.NewSignedMsgs(2, mockSigner)
@@ -49,7 +49,7 @@ func TestUpdateMessagePool(t *testing.T) {
 		// to
 		// Msg pool: [m0, m1], Chain: b[m2]
 		chainProvider, parent := newProviderWithGenesis(t)
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 		ib := message.NewInbox(p, 10, chainProvider, chainProvider)
 
 		m := types.NewSignedMsgs(3, mockSigner)
@@ -66,7 +66,7 @@ func TestUpdateMessagePool(t *testing.T) {
 		// to
 		// Msg pool: [m1],         Chain: b[m2, m3] -> b[m4] -> b[m0] -> b[] -> b[m5, m6]
 		chainProvider, parent := newProviderWithGenesis(t)
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 		ib := message.NewInbox(p, 10, chainProvider, chainProvider)
 
 		m := types.NewSignedMsgs(7, mockSigner)
@@ -91,7 +91,7 @@ func TestUpdateMessagePool(t *testing.T) {
 		// to
 		// Msg pool: [m1],         Chain: b[m2, m3] -> {b[m4], b[m0], b[], b[]} -> {b[], b[m6,m5]}
 		chainProvider, parent := newProviderWithGenesis(t)
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 		ib := message.NewInbox(p, 10, chainProvider, chainProvider)
 
 		m := types.NewSignedMsgs(7, mockSigner)
@@ -114,7 +114,7 @@ func TestUpdateMessagePool(t *testing.T) {
 		// to
 		// Msg pool: [m1, m2],     Chain: b[m0] -> b[m3] -> b[m4, m5]
 		chainProvider, parent := newProviderWithGenesis(t)
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 		ib := message.NewInbox(p, 10, chainProvider, chainProvider)
 
 		m := types.NewSignedMsgs(6, mockSigner)
@@ -140,7 +140,7 @@ func TestUpdateMessagePool(t *testing.T) {
 		// to
 		// Msg pool: [m6],         Chain: b[m0] -> b[m3] -> b[m4] -> b[m5] -> b[m1, m2]
 		chainProvider, parent := newProviderWithGenesis(t)
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 		ib := message.NewInbox(p, 10, chainProvider, chainProvider)
 
 		m := types.NewSignedMsgs(7, mockSigner)
@@ -167,7 +167,7 @@ func TestUpdateMessagePool(t *testing.T) {
 		// to
 		// Msg pool: [m6],         Chain: {b[m0], b[m1]} -> b[m3] -> b[m4] -> {b[m5], b[m1, m2]}
 		chainProvider, parent := newProviderWithGenesis(t)
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 		ib := message.NewInbox(p, 10, chainProvider, chainProvider)
 
 		m := types.NewSignedMsgs(7, mockSigner)
@@ -193,7 +193,7 @@ func TestUpdateMessagePool(t *testing.T) {
 		// to
 		// Msg pool: [m3, m5],     Chain: {b[m0], b[m1], b[m2]}
 		chainProvider, parent := newProviderWithGenesis(t)
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 		ib := message.NewInbox(p, 10, chainProvider, chainProvider)
 
 		m := types.NewSignedMsgs(6, mockSigner)
@@ -218,7 +218,7 @@ func TestUpdateMessagePool(t *testing.T) {
 		// to
 		// Msg pool: [m2, m3],         Chain: b[m0] -> b[m1]
 		chainProvider, parent := newProviderWithGenesis(t)
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 		ib := message.NewInbox(p, 10, chainProvider, chainProvider)
 		m := types.NewSignedMsgs(4, mockSigner)
 
@@ -238,7 +238,7 @@ func TestUpdateMessagePool(t *testing.T) {
 		// to
 		// Msg pool: [m0],     Chain: b[] -> b[m1, m2]
 		chainProvider, parent := newProviderWithGenesis(t)
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 		ib := message.NewInbox(p, 10, chainProvider, chainProvider)
 
 		m := types.NewSignedMsgs(3, mockSigner)
@@ -257,7 +257,7 @@ func TestUpdateMessagePool(t *testing.T) {
 		// to
 		// Msg pool: [],           Chain: b[m0] -> b[m1] -> b[m2, m3] -> b[m4] -> b[m5, m6]
 		chainProvider, parent := newProviderWithGenesis(t)
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 		ib := message.NewInbox(p, 10, chainProvider, chainProvider)
 
 		m := types.NewSignedMsgs(7, mockSigner)
@@ -283,7 +283,7 @@ func TestUpdateMessagePool(t *testing.T) {
 		// to
 		// Msg pool: [],           Chain: b[m0] -> b[m1] -> b[m2, m3] -> b[m4] -> b[m5, m6]
 		chainProvider, parent := newProviderWithGenesis(t)
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 		ib := message.NewInbox(p, 5, chainProvider, chainProvider)
 
 		m := types.NewSignedMsgs(1, mockSigner)
@@ -306,7 +306,7 @@ func TestUpdateMessagePool(t *testing.T) {
 
 	t.Run("Times out old messages", func(t *testing.T) {
 		chainProvider, parent := newProviderWithGenesis(t)
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 		maxAge := uint(10)
 		ib := message.NewInbox(p, maxAge, chainProvider, chainProvider)
 
@@ -351,7 +351,7 @@ func TestUpdateMessagePool(t *testing.T) {
 
 	t.Run("UnsignedMessage timeout is unaffected by null tipsets", func(t *testing.T) {
 		chainProvider, parent := newProviderWithGenesis(t)
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 		maxAge := uint(10)
 		ib := message.NewInbox(p, maxAge, chainProvider, chainProvider)
 
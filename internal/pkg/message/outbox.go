@@ -2,6 +2,7 @@ package message
 
 import (
 	"context"
+	"math/big"
 	"sync"
 
 	"github.com/filecoin-project/go-address"
@@ -9,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
@@ -41,6 +43,8 @@ type Outbox struct {
 	nonceLock sync.Mutex
 
 	journal journal.Writer
+	// Times the broadcasts recorded in the queue.
+	clock clock.Clock
 }
 
 type messageValidator interface {
@@ -61,7 +65,7 @@ var msgSendErrCt = metrics.NewInt64Counter("message_sender_error", "Number of er
 
 // NewOutbox creates a new outbox
 func NewOutbox(signer types.Signer, validator messageValidator, queue *Queue,
-	publisher publisher, policy QueuePolicy, chains chainProvider, actors actorProvider, jw journal.Writer) *Outbox {
+	publisher publisher, policy QueuePolicy, chains chainProvider, actors actorProvider, jw journal.Writer, clk clock.Clock) *Outbox {
 	return &Outbox{
 		signer:    signer,
 		validator: validator,
@@ -71,6 +75,7 @@ func NewOutbox(signer types.Signer, validator messageValidator, queue *Queue,
 		chains:    chains,
 		actors:    actors,
 		journal:   jw,
+		clock:     clk,
 	}
 }
 
@@ -131,6 +136,133 @@ func (ob *Outbox) Send(ctx context.Context, from, to address.Address, value type
 	return sendSignedMsg(ctx, ob, signed, bcast)
 }
 
+// NextNonce returns the nonce of the next message from `from`, following both the actor's
+// state at the head and the messages queued for it.
+func (ob *Outbox) NextNonce(ctx context.Context, from address.Address) (uint64, error) {
+	ob.nonceLock.Lock()
+	defer ob.nonceLock.Unlock()
+
+	fromActor, err := ob.actors.GetActorAt(ctx, ob.chains.GetHead(), from)
+	if err != nil {
+		return 0, errors.Wrapf(err, "no actor at address %s", from)
+	}
+	return nextNonce(fromActor, ob.queue, from)
+}
+
+// BatchMessage is a message to send as part of a batch.
+type BatchMessage struct {
+	To     address.Address
+	Value  types.AttoFIL
+	Method types.MethodID
+	// ABI encoded method parameters
+	Params []byte
+}
+
+// SendBatch signs and sends messages from `from` with a contiguous range of nonces, retaining
+// them in the outbound message queue. Either all the messages are queued or none is: the batch
+// is rejected if the actor's balance cannot cover the value and gas of all its messages after
+// those already queued.
+// If bcast is true, the publisher broadcasts the messages to the network at the current block height.
+// The returned channel receives the first error publishing the messages, or nil, once all are published.
+func (ob *Outbox) SendBatch(ctx context.Context, from address.Address, msgs []*BatchMessage,
+	gasPrice types.AttoFIL, gasLimit types.GasUnits, bcast bool) (out []cid.Cid, pubErrCh chan error, err error) {
+	defer func() {
+		if err != nil {
+			msgSendErrCt.Inc(ctx, 1)
+		}
+		cids := make([]string, len(out))
+		for i, c := range out {
+			cids[i] = c.String()
+		}
+		ob.journal.Write("SendBatch",
+			"from", from.String(), "count", len(msgs), "gasPrice", gasPrice.AsBigInt().Uint64(),
+			"gasLimit", uint64(gasLimit), "bcast", bcast, "error", err, "cids", cids)
+	}()
+
+	if len(msgs) == 0 {
+		return nil, nil, errors.New("no messages to send")
+	}
+
+	// Lock so that the nonces of the batch are not interleaved with those of other sends.
+	ob.nonceLock.Lock()
+	defer ob.nonceLock.Unlock()
+
+	head := ob.chains.GetHead()
+
+	fromActor, err := ob.actors.GetActorAt(ctx, head, from)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "no actor at address %s", from)
+	}
+
+	nonce, err := nextNonce(fromActor, ob.queue, from)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed calculating nonce for actor at %s", from)
+	}
+
+	// The state of the actor after the queued messages and those of the batch validated so far,
+	// so that a batch is rejected whole rather than failing partway through on chain.
+	simulated := *fromActor
+	simulated.CallSeqNum = types.Uint64(nonce)
+	for _, queued := range ob.queue.List(from) {
+		if queued.Msg.Message.CallSeqNum >= fromActor.CallSeqNum {
+			simulated.Balance = simulated.Balance.Sub(maxMessageCost(&queued.Msg.Message))
+		}
+	}
+
+	signed := make([]*types.SignedMessage, len(msgs))
+	for i, msg := range msgs {
+		rawMsg := types.NewMeteredMessage(from, msg.To, nonce+uint64(i), msg.Value, msg.Method, msg.Params, gasPrice, gasLimit)
+		signed[i], err = types.NewSignedMessage(*rawMsg, ob.signer)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to sign message %d", i)
+		}
+		if err := ob.validator.Validate(ctx, &signed[i].Message, &simulated); err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid message %d", i)
+		}
+		cost := maxMessageCost(rawMsg)
+		if simulated.Balance.LessThan(cost) {
+			return nil, nil, errors.Errorf("insufficient balance for message %d: %s remains after the previous messages, but it may spend %s",
+				i, simulated.Balance, cost)
+		}
+		simulated.Balance = simulated.Balance.Sub(cost)
+		simulated.CallSeqNum++
+	}
+
+	height, err := tipsetHeight(ob.chains, head)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get block height")
+	}
+
+	cids := make([]cid.Cid, len(signed))
+	for i, msg := range signed {
+		if cids[i], err = msg.Cid(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := ob.queue.EnqueueAll(ctx, signed, height); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to add messages to outbound queue")
+	}
+
+	// Publish in nonce order so that the message pool accepts each message.
+	pubErrCh = make(chan error, 1)
+	go func() {
+		var pubErr error
+		for i, msg := range signed {
+			if err := ob.publish(ctx, msg, height, bcast); err != nil {
+				log.Errorf("error: %s publishing message %s", err, cids[i])
+				if pubErr == nil {
+					pubErr = err
+				}
+			}
+		}
+		pubErrCh <- pubErr
+		close(pubErrCh)
+	}()
+
+	return cids, pubErrCh, nil
+}
+
 // SignedSend send a signed message, retaining it in the outbound message queue.
 // If bcast is true, the publisher broadcasts the message to the network at the current block height.
 func (ob *Outbox) SignedSend(ctx context.Context, signed *types.SignedMessage, bcast bool) (out cid.Cid, pubErrCh chan error, err error) {
@@ -143,6 +275,57 @@ func (ob *Outbox) SignedSend(ctx context.Context, signed *types.SignedMessage, b
 	return sendSignedMsg(ctx, ob, signed, bcast)
 }
 
+// Replace re-signs the queued message with CID `c` at a higher gas price and publishes it in
+// place of the original, which the message pool evicts. The pool rejects the replacement
+// unless the gas price beats the original's by its minimum bump. Returns the replacement's CID.
+func (ob *Outbox) Replace(ctx context.Context, c cid.Cid, gasPrice types.AttoFIL, bcast bool) (out cid.Cid, err error) {
+	defer func() {
+		if err != nil {
+			msgSendErrCt.Inc(ctx, 1)
+		}
+		ob.journal.Write("Replace",
+			"replaced", c.String(), "gasPrice", gasPrice.AsBigInt().Uint64(), "bcast", bcast,
+			"error", err, "cid", out.String())
+	}()
+
+	// Lock so that a concurrent send cannot enqueue a message between lookup and replacement.
+	ob.nonceLock.Lock()
+	defer ob.nonceLock.Unlock()
+
+	queued, found := ob.queue.Get(c)
+	if !found {
+		return cid.Undef, errors.Errorf("message %s is not in the outbox", c)
+	}
+	if !gasPrice.GreaterThan(queued.Msg.Message.GasPrice) {
+		return cid.Undef, errors.Errorf("gas price %s does not exceed the current %s", gasPrice, queued.Msg.Message.GasPrice)
+	}
+
+	rawMsg := queued.Msg.Message
+	rawMsg.GasPrice = gasPrice
+	signed, err := types.NewSignedMessage(rawMsg, ob.signer)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to sign message")
+	}
+
+	height, err := tipsetHeight(ob.chains, ob.chains.GetHead())
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to get block height")
+	}
+
+	// Publish before replacing in the queue so that a replacement rejected by the pool leaves
+	// the original queued.
+	if err := ob.publisher.Publish(ctx, signed, height, bcast); err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to publish replacement message")
+	}
+	if _, err := ob.queue.Replace(ctx, signed, height); err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to replace message in outbound queue")
+	}
+	if bcast {
+		ob.queue.RecordBroadcast(signed, ob.clock.Now())
+	}
+	return signed.Cid()
+}
+
 // sendSignedMsg add signed message in pool and return cid
 func sendSignedMsg(ctx context.Context, ob *Outbox, signed *types.SignedMessage, bcast bool) (cid.Cid, chan error, error) {
 	head := ob.chains.GetHead()
@@ -164,7 +347,7 @@ func sendSignedMsg(ctx context.Context, ob *Outbox, signed *types.SignedMessage,
 	pubErrCh := make(chan error)
 
 	go func() {
-		err = ob.publisher.Publish(ctx, signed, height, bcast)
+		err = ob.publish(ctx, signed, height, bcast)
 		if err != nil {
 			log.Errorf("error: %s publishing message %s", err, c.String())
 		}
@@ -175,6 +358,58 @@ func sendSignedMsg(ctx context.Context, ob *Outbox, signed *types.SignedMessage,
 	return c, pubErrCh, nil
 }
 
+// publish publishes a queued message, recording the attempt in the queue if it is broadcast.
+func (ob *Outbox) publish(ctx context.Context, msg *types.SignedMessage, height uint64, bcast bool) error {
+	err := ob.publisher.Publish(ctx, msg, height, bcast)
+	if bcast {
+		ob.queue.RecordBroadcast(msg, ob.clock.Now())
+	}
+	return err
+}
+
+// Load restores the queue persisted before a restart. Messages the head state shows were
+// already executed are dropped, and the others are published again.
+func (ob *Outbox) Load(ctx context.Context) error {
+	if err := ob.queue.Load(ctx); err != nil {
+		return errors.Wrap(err, "failed to load outbound queue")
+	}
+
+	head := ob.chains.GetHead()
+	height, err := tipsetHeight(ob.chains, head)
+	if err != nil {
+		return errors.Wrap(err, "failed to get block height")
+	}
+
+	var republish []*types.SignedMessage
+	for _, sender := range ob.queue.Queues() {
+		fromActor, err := ob.actors.GetActorAt(ctx, head, sender)
+		if err != nil {
+			// The queue policy expires the messages if they are never mined.
+			log.Warnf("not publishing restored messages from %s: no actor: %s", sender, err)
+			continue
+		}
+		for _, qm := range ob.queue.List(sender) {
+			if qm.Msg.Message.CallSeqNum >= fromActor.CallSeqNum {
+				republish = append(republish, qm.Msg)
+				continue
+			}
+			if _, _, err := ob.queue.RemoveNext(ctx, sender, uint64(qm.Msg.Message.CallSeqNum)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Publishing blocks until there are peers to publish to.
+	go func() {
+		for _, msg := range republish {
+			if err := ob.publish(ctx, msg, height, true); err != nil {
+				log.Errorf("error: %s publishing restored message from %s with nonce %d", err, msg.Message.From, msg.Message.CallSeqNum)
+			}
+		}
+	}()
+	return nil
+}
+
 // HandleNewHead maintains the message queue in response to a new head tipset.
 func (ob *Outbox) HandleNewHead(ctx context.Context, oldTips, newTips []block.TipSet) error {
 	return ob.policy.HandleNewHead(ctx, ob.queue, oldTips, newTips)
@@ -195,6 +430,12 @@ func nextNonce(act *actor.Actor, queue *Queue, address address.Address) (uint64,
 	return actorNonce, nil
 }
 
+// maxMessageCost returns the most that `msg` may spend from its sender's balance: its value
+// and its gas limit at its gas price.
+func maxMessageCost(msg *types.UnsignedMessage) types.AttoFIL {
+	return msg.Value.Add(msg.GasPrice.MulBigInt(new(big.Int).SetUint64(uint64(msg.GasLimit))))
+}
+
 func tipsetHeight(provider chainProvider, key block.TipSetKey) (uint64, error) {
 	head, err := provider.GetTipSet(key)
 	if err != nil {
@@ -7,10 +7,13 @@ import (
 	"time"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
 	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
@@ -37,7 +40,7 @@ func TestOutbox(t *testing.T) {
 		bcast := true
 
 		ob := message.NewOutbox(w, message.FakeValidator{RejectMessages: true}, queue, publisher,
-			message.NullPolicy{}, provider, provider, newOutboxTestJournal(t))
+			message.NullPolicy{}, provider, provider, newOutboxTestJournal(t), clock.NewSystemClock())
 
 		cid, _, err := ob.Send(context.Background(), sender, sender, types.NewAttoFILFromFIL(2), types.NewGasPrice(0), types.NewGasUnits(0), bcast, types.InvalidMethodID)
 		assert.Errorf(t, err, "for testing")
@@ -59,7 +62,7 @@ func TestOutbox(t *testing.T) {
 		actr.CallSeqNum = 42
 		provider.SetHeadAndActor(t, head.Key(), sender, actr)
 
-		ob := message.NewOutbox(w, message.FakeValidator{}, queue, publisher, message.NullPolicy{}, provider, provider, newOutboxTestJournal(t))
+		ob := message.NewOutbox(w, message.FakeValidator{}, queue, publisher, message.NullPolicy{}, provider, provider, newOutboxTestJournal(t), clock.NewSystemClock())
 		require.Empty(t, queue.List(sender))
 		require.Nil(t, publisher.Message)
 
@@ -103,7 +106,7 @@ func TestOutbox(t *testing.T) {
 		actr.CallSeqNum = 42
 		provider.SetHeadAndActor(t, head.Key(), sender, actr)
 
-		s := message.NewOutbox(w, message.FakeValidator{}, queue, publisher, message.NullPolicy{}, provider, provider, newOutboxTestJournal(t))
+		s := message.NewOutbox(w, message.FakeValidator{}, queue, publisher, message.NullPolicy{}, provider, provider, newOutboxTestJournal(t), clock.NewSystemClock())
 
 		var wg sync.WaitGroup
 		addTwentyMessages := func(batch int) {
@@ -140,6 +143,188 @@ func TestOutbox(t *testing.T) {
 		}
 	})
 
+	t.Run("replace re-signs and republishes a queued message", func(t *testing.T) {
+		ctx := context.Background()
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		toAddr := vmaddr.NewForTestGetter()()
+		queue := message.NewQueue()
+		publisher := &message.MockPublisher{}
+		provider := message.NewFakeProvider(t)
+
+		head := provider.BuildOneOn(block.UndefTipSet, func(b *chain.BlockBuilder) {
+			b.IncHeight(1000)
+		})
+		actr, _ := account.NewActor(types.ZeroAttoFIL)
+		provider.SetHeadAndActor(t, head.Key(), sender, actr)
+
+		ob := message.NewOutbox(w, message.FakeValidator{}, queue, publisher, message.NullPolicy{}, provider, provider, newOutboxTestJournal(t), clock.NewSystemClock())
+		original, pubDone, err := ob.Send(ctx, sender, toAddr, types.ZeroAttoFIL, types.NewGasPrice(1), types.NewGasUnits(0), true, types.InvalidMethodID)
+		require.NoError(t, err)
+		require.NoError(t, <-pubDone)
+
+		_, err = ob.Replace(ctx, original, types.NewGasPrice(1), true)
+		assert.Error(t, err)
+
+		// A replacement rejected by the publisher leaves the original queued.
+		publisher.ReturnError = errors.New("rejected")
+		_, err = ob.Replace(ctx, original, types.NewGasPrice(2), true)
+		assert.Error(t, err)
+		_, found := queue.Get(original)
+		assert.True(t, found)
+		publisher.ReturnError = nil
+
+		replacement, err := ob.Replace(ctx, original, types.NewGasPrice(2), true)
+		require.NoError(t, err)
+		queued := queue.List(sender)
+		require.Len(t, queued, 1)
+		assert.Equal(t, types.NewGasPrice(2), queued[0].Msg.Message.GasPrice)
+		assert.Equal(t, actr.CallSeqNum, queued[0].Msg.Message.CallSeqNum)
+		queuedCid, err := queued[0].Msg.Cid()
+		require.NoError(t, err)
+		assert.Equal(t, replacement, queuedCid)
+		assert.Equal(t, queued[0].Msg, publisher.Message)
+
+		_, err = ob.Replace(ctx, original, types.NewGasPrice(3), true)
+		assert.Error(t, err)
+	})
+
+	t.Run("send batch queues contiguous nonces", func(t *testing.T) {
+		ctx := context.Background()
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		toAddr := vmaddr.NewForTestGetter()()
+		queue := message.NewQueue()
+		publisher := &message.MockPublisher{}
+		provider := message.NewFakeProvider(t)
+
+		head := provider.BuildOneOn(block.UndefTipSet, func(b *chain.BlockBuilder) {
+			b.IncHeight(1000)
+		})
+		actr, _ := account.NewActor(types.NewAttoFILFromFIL(7))
+		actr.CallSeqNum = 42
+		provider.SetHeadAndActor(t, head.Key(), sender, actr)
+
+		ob := message.NewOutbox(w, message.FakeValidator{}, queue, publisher, message.NullPolicy{}, provider, provider, newOutboxTestJournal(t), clock.NewSystemClock())
+		_, pubDone, err := ob.Send(ctx, sender, toAddr, types.ZeroAttoFIL, types.NewGasPrice(0), types.NewGasUnits(0), true, types.InvalidMethodID)
+		require.NoError(t, err)
+		require.NoError(t, <-pubDone)
+
+		batch := []*message.BatchMessage{
+			{To: toAddr, Value: types.NewAttoFILFromFIL(1)},
+			{To: toAddr, Value: types.NewAttoFILFromFIL(2)},
+			{To: toAddr, Value: types.NewAttoFILFromFIL(3), Method: types.SendMethodID, Params: []byte{1}},
+		}
+		cids, pubDone, err := ob.SendBatch(ctx, sender, batch, types.NewGasPrice(1), types.NewGasUnits(100), true)
+		require.NoError(t, err)
+		require.NoError(t, <-pubDone)
+
+		queued := queue.List(sender)
+		require.Len(t, queued, 4)
+		require.Len(t, cids, 3)
+		for i, qm := range queued[1:] {
+			c, err := qm.Msg.Cid()
+			require.NoError(t, err)
+			assert.Equal(t, cids[i], c)
+			assert.Equal(t, types.Uint64(43+i), qm.Msg.Message.CallSeqNum)
+			assert.Equal(t, batch[i].Value, qm.Msg.Message.Value)
+			assert.Equal(t, uint64(1), qm.Attempts)
+		}
+		assert.Equal(t, []byte{1}, queued[3].Msg.Message.Params)
+		assert.Equal(t, types.Uint64(45), publisher.Message.Message.CallSeqNum)
+
+		// Nothing is queued if a message is invalid.
+		rejecting := message.NewOutbox(w, message.FakeValidator{RejectMessages: true}, queue, publisher, message.NullPolicy{}, provider, provider, newOutboxTestJournal(t), clock.NewSystemClock())
+		_, _, err = rejecting.SendBatch(ctx, sender, batch, types.NewGasPrice(1), types.NewGasUnits(100), true)
+		assert.Error(t, err)
+		assert.Len(t, queue.List(sender), 4)
+
+		_, _, err = ob.SendBatch(ctx, sender, nil, types.NewGasPrice(1), types.NewGasUnits(100), true)
+		assert.Error(t, err)
+	})
+
+	t.Run("send batch rejects a batch overspending the balance", func(t *testing.T) {
+		ctx := context.Background()
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		toAddr := vmaddr.NewForTestGetter()()
+		queue := message.NewQueue()
+		provider := message.NewFakeProvider(t)
+
+		head := provider.BuildOneOn(block.UndefTipSet, func(b *chain.BlockBuilder) {
+			b.IncHeight(1000)
+		})
+		actr, _ := account.NewActor(types.NewAttoFILFromFIL(5))
+		provider.SetHeadAndActor(t, head.Key(), sender, actr)
+
+		ob := message.NewOutbox(w, message.FakeValidator{}, queue, &message.MockPublisher{}, message.NullPolicy{}, provider, provider, newOutboxTestJournal(t), clock.NewSystemClock())
+		batch := []*message.BatchMessage{
+			{To: toAddr, Value: types.NewAttoFILFromFIL(2)},
+			{To: toAddr, Value: types.NewAttoFILFromFIL(3)},
+		}
+
+		// Each message is within the balance, but not together with the gas of both.
+		_, _, err := ob.SendBatch(ctx, sender, batch, types.NewGasPrice(1), types.NewGasUnits(100), true)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "insufficient balance for message 1")
+		assert.Empty(t, queue.List(sender))
+
+		// Messages already queued spend the balance first.
+		_, pubDone, err := ob.Send(ctx, sender, toAddr, types.NewAttoFILFromFIL(4), types.NewGasPrice(0), types.NewGasUnits(0), true, types.InvalidMethodID)
+		require.NoError(t, err)
+		require.NoError(t, <-pubDone)
+		_, _, err = ob.SendBatch(ctx, sender, batch[:1], types.NewGasPrice(0), types.NewGasUnits(0), true)
+		require.Error(t, err)
+		assert.Len(t, queue.List(sender), 1)
+
+		cids, pubDone, err := ob.SendBatch(ctx, sender, []*message.BatchMessage{{To: toAddr, Value: types.NewAttoFILFromFIL(1)}}, types.NewGasPrice(0), types.NewGasUnits(0), true)
+		require.NoError(t, err)
+		require.NoError(t, <-pubDone)
+		assert.Len(t, cids, 1)
+	})
+
+	t.Run("load drops executed messages and publishes the others", func(t *testing.T) {
+		ctx := context.Background()
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		toAddr := vmaddr.NewForTestGetter()()
+		ds := datastore.NewMapDatastore()
+		provider := message.NewFakeProvider(t)
+
+		head := provider.BuildOneOn(block.UndefTipSet, func(b *chain.BlockBuilder) {
+			b.IncHeight(1000)
+		})
+		actr, _ := account.NewActor(types.ZeroAttoFIL)
+		actr.CallSeqNum = 42
+		provider.SetHeadAndActor(t, head.Key(), sender, actr)
+
+		ob := message.NewOutbox(w, message.FakeValidator{}, message.NewPersistentQueue(ds), &message.MockPublisher{}, message.NullPolicy{}, provider, provider, newOutboxTestJournal(t), clock.NewSystemClock())
+		for i := 0; i < 3; i++ {
+			_, pubDone, err := ob.Send(ctx, sender, toAddr, types.ZeroAttoFIL, types.NewGasPrice(0), types.NewGasUnits(0), true, types.InvalidMethodID)
+			require.NoError(t, err)
+			require.NoError(t, <-pubDone)
+		}
+
+		// The first message was executed while the node was down.
+		next := provider.BuildOneOn(head, func(b *chain.BlockBuilder) {})
+		mined, _ := account.NewActor(types.ZeroAttoFIL)
+		mined.CallSeqNum = 43
+		provider.SetHeadAndActor(t, next.Key(), sender, mined)
+
+		queue := message.NewPersistentQueue(ds)
+		publisher := &chanPublisher{published: make(chan *types.SignedMessage, 2)}
+		restored := message.NewOutbox(w, message.FakeValidator{}, queue, publisher, message.NullPolicy{}, provider, provider, newOutboxTestJournal(t), clock.NewSystemClock())
+		require.NoError(t, restored.Load(ctx))
+
+		queued := queue.List(sender)
+		require.Len(t, queued, 2)
+		for i, qm := range queued {
+			assert.Equal(t, types.Uint64(43+i), qm.Msg.Message.CallSeqNum)
+			assert.Equal(t, uint64(1000), qm.Stamp)
+			assert.Equal(t, qm.Msg, <-publisher.published)
+		}
+	})
+
 	t.Run("fails with non-account actor", func(t *testing.T) {
 		w, _ := types.NewMockSignersAndKeyInfo(1)
 		sender := w.Addresses[0]
@@ -152,10 +337,20 @@ func TestOutbox(t *testing.T) {
 		actr := storagemarket.NewActor() // Not an account actor
 		provider.SetHeadAndActor(t, head.Key(), sender, actr)
 
-		ob := message.NewOutbox(w, message.FakeValidator{}, queue, publisher, message.NullPolicy{}, provider, provider, newOutboxTestJournal(t))
+		ob := message.NewOutbox(w, message.FakeValidator{}, queue, publisher, message.NullPolicy{}, provider, provider, newOutboxTestJournal(t), clock.NewSystemClock())
 
 		_, _, err := ob.Send(context.Background(), sender, toAddr, types.ZeroAttoFIL, types.NewGasPrice(0), types.NewGasUnits(0), true, types.InvalidMethodID)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "account or empty")
 	})
 }
+
+// chanPublisher sends the messages it publishes on a channel.
+type chanPublisher struct {
+	published chan *types.SignedMessage
+}
+
+func (p *chanPublisher) Publish(ctx context.Context, message *types.SignedMessage, height uint64, bcast bool) error {
+	p.published <- message
+	return nil
+}
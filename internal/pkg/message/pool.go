@@ -2,18 +2,35 @@ package message
 
 import (
 	"context"
+	"math/big"
+	"sort"
 	"sync"
 
+	"github.com/cskr/pubsub"
 	"github.com/filecoin-project/go-address"
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 )
 
-var mpSize = metrics.NewInt64Gauge("message_pool_size", "The size of the message pool")
+func init() {
+	encoding.RegisterIpldCborType(persistedMessage{})
+}
+
+// poolDatastorePrefix is the datastore namespace under which a persistent pool
+// keeps its pending messages by CID.
+var poolDatastorePrefix = datastore.NewKey("/mpool")
+
+var (
+	mpSize    = metrics.NewInt64Gauge("message_pool_size", "The size of the message pool")
+	mpEvictCt = metrics.NewInt64Counter("message_pool_evict", "The number of messages evicted from the full message pool for higher priority messages")
+)
 
 // PoolValidator defines a validator that ensures a message can go through the pool.
 type PoolValidator interface {
@@ -25,6 +42,14 @@ type PoolValidator interface {
 // exists is a nop. We use a Pool to store all messages received by this node
 // via network or directly created via user command that have yet to be included
 // in a block. Messages are removed as they are processed.
+// A message with the same sender and nonce as a pending message replaces it if
+// its gas price is higher by at least the configured minimum bump.
+// When the pool is full a message evicts pending messages of lower priority,
+// ranked by effective gas price. Messages from local addresses are never evicted.
+// Senders other than local addresses are limited to a quota of pending messages.
+// A persistent pool also keeps its pending messages in a datastore to be loaded
+// again after a restart.
+// The pool publishes an event for every message added or removed, see Subscribe.
 //
 // Pool is safe for concurrent access.
 type Pool struct {
@@ -32,8 +57,18 @@ type Pool struct {
 
 	cfg           *config.MessagePoolConfig
 	validator     PoolValidator
+	local         localAddresses
 	pending       map[cid.Cid]*timedmessage // all pending messages
-	addressNonces map[addressNonce]bool     // set of address nonce pairs used to efficiently validate duplicate nonces
+	addressNonces map[addressNonce]cid.Cid  // CIDs of pending messages by address nonce pair, used to efficiently find duplicate nonces
+	senderCounts  map[address.Address]uint  // number of pending messages by sender
+	ds            datastore.Datastore       // persists pending messages, nil if the pool is not persistent
+	events        *pubsub.PubSub            // publishes PoolEvents
+	closed        bool                      // set by Close, after which events are no longer published
+}
+
+// localAddresses identifies the addresses of the node's own wallet.
+type localAddresses interface {
+	HasAddress(addr address.Address) bool
 }
 
 type timedmessage struct {
@@ -41,6 +76,12 @@ type timedmessage struct {
 	addedAt uint64
 }
 
+// persistedMessage is a pending message as persisted in the datastore.
+type persistedMessage struct {
+	Message *types.SignedMessage
+	AddedAt uint64
+}
+
 type addressNonce struct {
 	addr  address.Address
 	nonce uint64
@@ -50,18 +91,87 @@ func newAddressNonce(msg *types.SignedMessage) addressNonce {
 	return addressNonce{addr: msg.Message.From, nonce: uint64(msg.Message.CallSeqNum)}
 }
 
-// NewPool constructs a new Pool.
-func NewPool(cfg *config.MessagePoolConfig, validator PoolValidator) *Pool {
+// NewPool constructs a new Pool. Messages from addresses `local` has are never evicted; `local`
+// may be nil.
+func NewPool(cfg *config.MessagePoolConfig, validator PoolValidator, local localAddresses) *Pool {
 	return &Pool{
 		cfg:           cfg,
 		validator:     validator,
+		local:         local,
 		pending:       make(map[cid.Cid]*timedmessage),
-		addressNonces: make(map[addressNonce]bool),
+		addressNonces: make(map[addressNonce]cid.Cid),
+		senderCounts:  make(map[address.Address]uint),
+		events:        pubsub.New(128),
+	}
+}
+
+// NewPersistentPool constructs a new Pool keeping its pending messages in `ds`. Messages
+// persisted by a previous pool are restored by Load.
+func NewPersistentPool(cfg *config.MessagePoolConfig, validator PoolValidator, local localAddresses, ds datastore.Datastore) *Pool {
+	pool := NewPool(cfg, validator, local)
+	pool.ds = ds
+	return pool
+}
+
+func poolDatastoreKey(c cid.Cid) datastore.Key {
+	return poolDatastorePrefix.ChildString(c.String())
+}
+
+// Load adds the messages persisted by a previous pool on the same datastore, validating
+// them again against the current state. Messages that are no longer valid are dropped.
+// Returns the number of messages added.
+func (pool *Pool) Load(ctx context.Context) (int, error) {
+	if pool.ds == nil {
+		return 0, nil
+	}
+	res, err := pool.ds.Query(query.Query{Prefix: poolDatastorePrefix.String() + "/"})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to query persisted messages")
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read persisted messages")
+	}
+
+	persisted := make([]*persistedMessage, len(entries))
+	for i, entry := range entries {
+		persisted[i] = &persistedMessage{}
+		if err := encoding.Decode(entry.Value, persisted[i]); err != nil {
+			return 0, errors.Wrapf(err, "failed to decode persisted message %s", entry.Key)
+		}
 	}
+	// Add messages in nonce order so that no message is added before those it depends on.
+	sort.Slice(persisted, func(i, j int) bool {
+		mi, mj := persisted[i].Message.Message, persisted[j].Message.Message
+		if mi.From != mj.From {
+			return mi.From.String() < mj.From.String()
+		}
+		return mi.CallSeqNum < mj.CallSeqNum
+	})
+
+	added := 0
+	for _, pm := range persisted {
+		_, err := pool.Add(ctx, pm.Message, pm.AddedAt)
+		if err == nil {
+			added++
+			continue
+		}
+		log.Debugf("dropping persisted message from %s with nonce %d: %s", pm.Message.Message.From, pm.Message.Message.CallSeqNum, err)
+		c, err := pm.Message.Cid()
+		if err != nil {
+			return added, err
+		}
+		if err := pool.ds.Delete(poolDatastoreKey(c)); err != nil {
+			return added, errors.Wrapf(err, "failed to delete persisted message %s", c)
+		}
+	}
+	return added, nil
 }
 
 // Add adds a message to the pool, tagged with the block height at which it was received.
-// Does nothing if the message is already in the pool.
+// Does nothing if the message is already in the pool. A pending message with the same
+// sender and nonce is removed if the new message replaces it, and lower priority messages
+// are evicted if the pool is full.
 func (pool *Pool) Add(ctx context.Context, msg *types.SignedMessage, height uint64) (cid.Cid, error) {
 	pool.lk.Lock()
 	defer pool.lk.Unlock()
@@ -77,12 +187,27 @@ func (pool *Pool) Add(ctx context.Context, msg *types.SignedMessage, height uint
 		return c, nil
 	}
 
-	if err = pool.validateMessage(ctx, msg); err != nil {
+	removals, err := pool.validateMessage(ctx, msg)
+	if err != nil {
 		return cid.Undef, errors.Wrap(err, "validation error adding message to pool")
 	}
+	for _, removed := range removals {
+		pool.remove(removed, PoolEventEvict)
+	}
 
 	pool.pending[c] = &timedmessage{message: msg, addedAt: height}
-	pool.addressNonces[newAddressNonce(msg)] = true
+	pool.addressNonces[newAddressNonce(msg)] = c
+	pool.senderCounts[msg.Message.From]++
+	if pool.ds != nil {
+		bs, err := encoding.Encode(&persistedMessage{Message: msg, AddedAt: height})
+		if err == nil {
+			err = pool.ds.Put(poolDatastoreKey(c), bs)
+		}
+		if err != nil {
+			log.Warnf("failed to persist message %s: %s", c, err)
+		}
+	}
+	pool.publish(PoolEventAdd, c, msg)
 	mpSize.Set(ctx, int64(len(pool.pending)))
 	return c, nil
 }
@@ -117,13 +242,64 @@ func (pool *Pool) Get(c cid.Cid) (*types.SignedMessage, bool) {
 func (pool *Pool) Remove(c cid.Cid) {
 	pool.lk.Lock()
 	defer pool.lk.Unlock()
+	pool.remove(c, PoolEventRemove)
+
+	mpSize.Set(context.TODO(), int64(len(pool.pending)))
+}
+
+// RemoveIncluded removes the message by CID from the pending pool because it was included in
+// a block.
+func (pool *Pool) RemoveIncluded(c cid.Cid) {
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+	pool.remove(c, PoolEventIncluded)
+
+	mpSize.Set(context.TODO(), int64(len(pool.pending)))
+}
+
+// remove removes a message by CID, publishing an event of type `reason` if it was pending.
+// The caller must hold the lock.
+func (pool *Pool) remove(c cid.Cid, reason PoolEventType) {
 	msg, ok := pool.pending[c]
 	if ok {
-		delete(pool.addressNonces, newAddressNonce(msg.message))
+		an := newAddressNonce(msg.message)
+		if pool.addressNonces[an].Equals(c) {
+			delete(pool.addressNonces, an)
+		}
 		delete(pool.pending, c)
+		pool.senderCounts[an.addr]--
+		if pool.senderCounts[an.addr] == 0 {
+			delete(pool.senderCounts, an.addr)
+		}
+		if pool.ds != nil {
+			if err := pool.ds.Delete(poolDatastoreKey(c)); err != nil && err != datastore.ErrNotFound {
+				log.Warnf("failed to delete persisted message %s: %s", c, err)
+			}
+		}
+		pool.publish(reason, c, msg.message)
 	}
+}
 
-	mpSize.Set(context.TODO(), int64(len(pool.pending)))
+// SenderQuotaReached returns true if `msg` would exceed the quota of pending messages of its
+// sender. A message with the nonce of a pending one may replace it and never exceeds the quota.
+func (pool *Pool) SenderQuotaReached(msg *types.SignedMessage) bool {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+	if _, found := pool.addressNonces[newAddressNonce(msg)]; found {
+		return false
+	}
+	return pool.senderQuotaReached(msg.Message.From)
+}
+
+func (pool *Pool) senderQuotaReached(sender address.Address) bool {
+	if pool.cfg.MaxPendingPerSender == 0 || pool.isLocal(sender) {
+		return false
+	}
+	return pool.senderCounts[sender] >= pool.cfg.MaxPendingPerSender
+}
+
+func (pool *Pool) isLocal(addr address.Address) bool {
+	return pool.local != nil && pool.local.HasAddress(addr)
 }
 
 // LargestNonce returns the largest nonce used by a message from address in the pool.
@@ -154,19 +330,117 @@ func (pool *Pool) PendingBefore(minimumHeight uint64) []cid.Cid {
 	return cids
 }
 
-// validateMessage validates that too many messages aren't added to the pool and the ones that are
-// have a high probability of making it through processing.
-func (pool *Pool) validateMessage(ctx context.Context, message *types.SignedMessage) error {
-	if uint(len(pool.pending)) >= pool.cfg.MaxPoolSize {
-		return errors.Errorf("message pool is full (%d messages)", pool.cfg.MaxPoolSize)
+// MinReplacementGasPrice returns the lowest gas price a message must have to
+// replace a pending message with gas price `price`.
+func (pool *Pool) MinReplacementGasPrice(price types.AttoFIL) types.AttoFIL {
+	// The bump is rounded up and at least one attoFIL so that a replacement always pays more.
+	bump := new(big.Int).Mul(price.AsBigInt(), big.NewInt(int64(pool.cfg.ReplaceByFeePercent)))
+	bump.Add(bump, big.NewInt(99)).Div(bump, big.NewInt(100))
+	if bump.Sign() == 0 {
+		bump.SetInt64(1)
 	}
+	return price.Add(types.NewAttoFIL(bump))
+}
 
-	// check that message with this nonce does not already exist
-	_, found := pool.addressNonces[newAddressNonce(message)]
+// validateMessage validates that too many messages aren't added to the pool and the ones that are
+// have a high probability of making it through processing. It returns the CIDs of the pending
+// messages to remove to make room for the message: the message it replaces or those it evicts.
+func (pool *Pool) validateMessage(ctx context.Context, message *types.SignedMessage) ([]cid.Cid, error) {
+	var removals []cid.Cid
+	// check that a message with this nonce does not already exist, unless the new one replaces it
+	replaced, found := pool.addressNonces[newAddressNonce(message)]
 	if found {
-		return errors.Errorf("message pool contains message with same actor and nonce but different cid")
+		existing := pool.pending[replaced].message
+		minPrice := pool.MinReplacementGasPrice(existing.Message.GasPrice)
+		if message.Message.GasPrice.LessThan(minPrice) {
+			return nil, errors.Errorf("message pool contains message with same actor and nonce but different cid and gas price %s, a replacement needs a gas price of at least %s",
+				existing.Message.GasPrice, minPrice)
+		}
+		removals = append(removals, replaced)
+	} else if pool.senderQuotaReached(message.Message.From) {
+		return nil, errors.Errorf("sender %s has reached its quota of %d pending messages", message.Message.From, pool.cfg.MaxPendingPerSender)
+	} else if uint(len(pool.pending)) >= pool.cfg.MaxPoolSize {
+		removals = pool.evictionsFor(message, uint(len(pool.pending))-pool.cfg.MaxPoolSize+1)
+		if removals == nil {
+			return nil, errors.Errorf("message pool is full (%d messages)", pool.cfg.MaxPoolSize)
+		}
 	}
 
 	// check that the message is likely to succeed in processing
-	return pool.validator.Validate(ctx, message)
+	if err := pool.validator.Validate(ctx, message); err != nil {
+		return nil, err
+	}
+	if !found {
+		mpEvictCt.Inc(ctx, int64(len(removals)))
+	}
+	return removals, nil
+}
+
+// evictionsFor returns the CIDs of `count` pending messages of lower priority than `message`,
+// or nil if there are not enough of them.
+//
+// The priority of a message is its effective gas price: the lowest gas price of the message and
+// of the pending messages from the same sender with lower nonces, since it cannot be mined before
+// them. Evictions are taken from the highest nonces of a sender down so that the remaining nonces
+// of every sender stay a prefix of those pending, which is also the order of decreasing priority.
+// Messages from local addresses or the sender of `message` are never evicted.
+func (pool *Pool) evictionsFor(message *types.SignedMessage, count uint) []cid.Cid {
+	type candidate struct {
+		cid       cid.Cid
+		nonce     uint64
+		effective types.AttoFIL
+	}
+
+	// Messages by sender in nonce order.
+	bySender := make(map[address.Address][]*candidate)
+	for c, tm := range pool.pending {
+		from := tm.message.Message.From
+		bySender[from] = append(bySender[from], &candidate{cid: c, nonce: uint64(tm.message.Message.CallSeqNum), effective: tm.message.Message.GasPrice})
+	}
+	for _, msgs := range bySender {
+		sort.Slice(msgs, func(i, j int) bool { return msgs[i].nonce < msgs[j].nonce })
+		for i := 1; i < len(msgs); i++ {
+			if msgs[i-1].effective.LessThan(msgs[i].effective) {
+				msgs[i].effective = msgs[i-1].effective
+			}
+		}
+	}
+
+	from := message.Message.From
+	isLocal := pool.isLocal(from)
+	priority := message.Message.GasPrice
+	for _, m := range bySender[from] {
+		if m.nonce < uint64(message.Message.CallSeqNum) && m.effective.LessThan(priority) {
+			priority = m.effective
+		}
+	}
+	delete(bySender, from)
+	for sender := range bySender {
+		if pool.isLocal(sender) {
+			delete(bySender, sender)
+		}
+	}
+
+	var evictions []cid.Cid
+	for uint(len(evictions)) < count {
+		// Find the lowest priority message among the highest nonces of each sender.
+		var lowest address.Address
+		var lowestMsg *candidate
+		for sender, msgs := range bySender {
+			tail := msgs[len(msgs)-1]
+			if lowestMsg == nil || tail.effective.LessThan(lowestMsg.effective) {
+				lowest, lowestMsg = sender, tail
+			}
+		}
+		if lowestMsg == nil || (!isLocal && !lowestMsg.effective.LessThan(priority)) {
+			return nil
+		}
+		evictions = append(evictions, lowestMsg.cid)
+		if msgs := bySender[lowest]; len(msgs) > 1 {
+			bySender[lowest] = msgs[:len(msgs)-1]
+		} else {
+			delete(bySender, lowest)
+		}
+	}
+	return evictions
 }
@@ -0,0 +1,141 @@
+package message
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// PoolEventTopic is the pubsub topic on which a pool publishes its events.
+const PoolEventTopic = "mpool"
+
+// PoolSubscriptionBufferSize is the number of events buffered for each subscriber. A subscriber
+// that falls further behind is dropped.
+const PoolSubscriptionBufferSize = 256
+
+// ErrPoolSubscriberTooSlow is the error of a subscription dropped because it did not keep up
+// with the pool events.
+var ErrPoolSubscriberTooSlow = errors.New("message pool subscriber too slow")
+
+// PoolEventType is the kind of change a PoolEvent describes.
+type PoolEventType string
+
+const (
+	// PoolEventAdd is a message added to the pool.
+	PoolEventAdd = PoolEventType("add")
+	// PoolEventRemove is a message removed from the pool because it was deleted or timed out.
+	PoolEventRemove = PoolEventType("remove")
+	// PoolEventEvict is a message pushed out by a message added to the pool: replaced by a
+	// message with the same nonce and a higher gas price, or evicted from the full pool for a
+	// message of higher priority.
+	PoolEventEvict = PoolEventType("evict")
+	// PoolEventIncluded is a message removed from the pool because it was included in a block
+	// of the head chain.
+	PoolEventIncluded = PoolEventType("included")
+)
+
+// PoolEvent is a change of the messages pending in a pool.
+type PoolEvent struct {
+	Type    PoolEventType
+	Cid     cid.Cid
+	Message *types.SignedMessage
+}
+
+// PoolSubscription delivers the events of a pool in the order they happen. The channel of
+// events is closed when the subscription ends, after which Err reports why.
+type PoolSubscription struct {
+	ch chan *PoolEvent
+
+	mu  sync.Mutex
+	err error
+}
+
+// Events returns the channel of pool events.
+func (s *PoolSubscription) Events() <-chan *PoolEvent {
+	return s.ch
+}
+
+// Err returns the reason the subscription ended. It returns nil while the subscription is
+// active or if the subscriber cancelled it.
+func (s *PoolSubscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Subscribe delivers the events of the pool from now until the context is done. Events are
+// buffered so that a subscriber never holds up the pool; a subscriber that falls behind by
+// more than PoolSubscriptionBufferSize events is dropped with ErrPoolSubscriberTooSlow.
+func (pool *Pool) Subscribe(ctx context.Context) *PoolSubscription {
+	sub := &PoolSubscription{ch: make(chan *PoolEvent, PoolSubscriptionBufferSize)}
+	pool.lk.RLock()
+	if pool.closed {
+		pool.lk.RUnlock()
+		close(sub.ch)
+		return sub
+	}
+	events := pool.events.Sub(PoolEventTopic)
+	pool.lk.RUnlock()
+
+	go func() {
+		defer close(sub.ch)
+		defer func() {
+			// The pubsub closes the channel once unsubscribed, or once the pool is closed, and
+			// may be blocked sending to it until then.
+			go func() {
+				pool.lk.RLock()
+				defer pool.lk.RUnlock()
+				if !pool.closed {
+					pool.events.Unsub(events, PoolEventTopic)
+				}
+			}()
+			for range events {
+			}
+		}()
+
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				select {
+				case sub.ch <- e.(*PoolEvent):
+				default:
+					sub.mu.Lock()
+					sub.err = ErrPoolSubscriberTooSlow
+					sub.mu.Unlock()
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return sub
+}
+
+// Close stops the publishing of pool events and ends all subscriptions. The pool remains
+// usable without events.
+func (pool *Pool) Close() {
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+	if pool.closed {
+		return
+	}
+	pool.closed = true
+	pool.events.Shutdown()
+}
+
+// publish publishes an event about the message `msg` with CID `c`. The caller must hold the
+// lock.
+func (pool *Pool) publish(typ PoolEventType, c cid.Cid, msg *types.SignedMessage) {
+	if pool.closed {
+		return
+	}
+	pool.events.Pub(&PoolEvent{Type: typ, Cid: c, Message: msg}, PoolEventTopic)
+}
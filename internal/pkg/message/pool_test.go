@@ -5,6 +5,9 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -24,7 +27,7 @@ func TestMessagePoolAddRemove(t *testing.T) {
 
 	ctx := context.Background()
 
-	pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+	pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 	msg1 := newSignedMessage()
 	msg2 := mustSetNonce(mockSigner, newSignedMessage(), 1)
 
@@ -66,8 +69,10 @@ func TestMessagePoolValidate(t *testing.T) {
 		// pull the default size from the default config value
 		mpoolCfg := config.NewDefaultConfig().Mpool
 		maxMessagePoolSize := mpoolCfg.MaxPoolSize
+		// all the messages are from the same sender
+		mpoolCfg.MaxPendingPerSender = 0
 		ctx := context.Background()
-		pool := message.NewPool(mpoolCfg, th.NewMockMessagePoolValidator())
+		pool := message.NewPool(mpoolCfg, th.NewMockMessagePoolValidator(), nil)
 
 		smsgs := types.NewSignedMsgs(maxMessagePoolSize+1, mockSigner)
 		for _, smsg := range smsgs[:maxMessagePoolSize] {
@@ -87,7 +92,7 @@ func TestMessagePoolValidate(t *testing.T) {
 
 	t.Run("validates no two messages are added with same nonce", func(t *testing.T) {
 		ctx := context.Background()
-		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 
 		smsg1 := newSignedMessage()
 		_, err := pool.Add(ctx, smsg1, 0)
@@ -103,7 +108,7 @@ func TestMessagePoolValidate(t *testing.T) {
 		ctx := context.Background()
 		validator := th.NewMockMessagePoolValidator()
 		validator.Valid = false
-		pool := message.NewPool(config.NewDefaultConfig().Mpool, validator)
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, validator, nil)
 
 		smsg1 := mustSetNonce(mockSigner, newSignedMessage(), 0)
 		_, err := pool.Add(ctx, smsg1, 0)
@@ -112,12 +117,302 @@ func TestMessagePoolValidate(t *testing.T) {
 	})
 }
 
+func TestMessagePoolReplaceByFee(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	withPrice := func(msg *types.SignedMessage, price uint64) *types.SignedMessage {
+		return mustResignMessage(mockSigner, msg, func(m *types.UnsignedMessage) {
+			m.GasPrice = types.NewGasPrice(price)
+		})
+	}
+
+	cfg := config.NewDefaultConfig().Mpool
+	cfg.ReplaceByFeePercent = 10
+	pool := message.NewPool(cfg, th.NewMockMessagePoolValidator(), nil)
+	assert.Equal(t, types.NewGasPrice(110), pool.MinReplacementGasPrice(types.NewGasPrice(100)))
+	assert.Equal(t, types.NewGasPrice(102), pool.MinReplacementGasPrice(types.NewGasPrice(101)))
+	assert.Equal(t, types.NewGasPrice(1), pool.MinReplacementGasPrice(types.NewGasPrice(0)))
+
+	original := withPrice(newSignedMessage(), 100)
+	originalCid, err := pool.Add(ctx, original, 0)
+	require.NoError(t, err)
+
+	// A replacement below the minimum bump is rejected.
+	_, err = pool.Add(ctx, withPrice(original, 109), 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "message with same actor and nonce")
+	_, found := pool.Get(originalCid)
+	assert.True(t, found)
+
+	replacement := withPrice(original, 110)
+	replacementCid, err := pool.Add(ctx, replacement, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []*types.SignedMessage{replacement}, pool.Pending())
+	_, found = pool.Get(originalCid)
+	assert.False(t, found)
+
+	// Removing the replacement frees the nonce.
+	pool.Remove(replacementCid)
+	_, err = pool.Add(ctx, original, 0)
+	assert.NoError(t, err)
+
+	t.Run("replacement in a full pool", func(t *testing.T) {
+		cfg := config.NewDefaultConfig().Mpool
+		cfg.MaxPoolSize = 1
+		pool := message.NewPool(cfg, th.NewMockMessagePoolValidator(), nil)
+
+		original := withPrice(newSignedMessage(), 100)
+		reqAdd(t, pool, 0, original)
+		reqAdd(t, pool, 0, withPrice(original, 200))
+		assert.Len(t, pool.Pending(), 1)
+	})
+}
+
+type fakeLocalAddresses map[address.Address]bool
+
+func (l fakeLocalAddresses) HasAddress(addr address.Address) bool {
+	return l[addr]
+}
+
+func TestMessagePoolEviction(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	alice, bob, carol, dave, erin := mockSigner.Addresses[0], mockSigner.Addresses[1], mockSigner.Addresses[2], mockSigner.Addresses[3], mockSigner.Addresses[4]
+	newMessage := func(from address.Address, nonce, price uint64) *types.SignedMessage {
+		return mustResignMessage(mockSigner, newSignedMessage(), func(m *types.UnsignedMessage) {
+			m.From = from
+			m.CallSeqNum = types.Uint64(nonce)
+			m.GasPrice = types.NewGasPrice(price)
+		})
+	}
+	requireAdd := func(p *message.Pool, msg *types.SignedMessage) cid.Cid {
+		c, err := p.Add(ctx, msg, 0)
+		require.NoError(t, err)
+		return c
+	}
+	assertPending := func(p *message.Pool, expected ...cid.Cid) {
+		var actual []cid.Cid
+		for _, msg := range p.Pending() {
+			c, err := msg.Cid()
+			require.NoError(t, err)
+			actual = append(actual, c)
+		}
+		assert.ElementsMatch(t, expected, actual)
+	}
+
+	cfg := config.NewDefaultConfig().Mpool
+	cfg.MaxPoolSize = 4
+	pool := message.NewPool(cfg, th.NewMockMessagePoolValidator(), fakeLocalAddresses{carol: true})
+
+	alice0 := requireAdd(pool, newMessage(alice, 0, 5))
+	// Cannot be mined before alice0, but its own price is lower.
+	requireAdd(pool, newMessage(alice, 1, 1))
+	bob0 := requireAdd(pool, newMessage(bob, 0, 3))
+	carol0 := requireAdd(pool, newMessage(carol, 0, 0))
+
+	// The lowest priority message is evicted.
+	dave0 := requireAdd(pool, newMessage(dave, 0, 2))
+	assertPending(pool, alice0, bob0, carol0, dave0)
+
+	// A message of lower priority than all others is rejected.
+	_, err := pool.Add(ctx, newMessage(erin, 0, 2), 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "message pool is full")
+	assertPending(pool, alice0, bob0, carol0, dave0)
+
+	// The priority of a message is limited by the lower nonces of its sender.
+	alice1 := requireAdd(pool, newMessage(alice, 1, 10))
+	assertPending(pool, alice0, alice1, bob0, carol0)
+
+	// Local messages are admitted and never evicted.
+	carol1 := requireAdd(pool, newMessage(carol, 1, 0))
+	assertPending(pool, alice0, alice1, carol0, carol1)
+
+	// Eviction starts from the highest nonce of a sender.
+	erin0 := requireAdd(pool, newMessage(erin, 0, 100))
+	assertPending(pool, alice0, carol0, carol1, erin0)
+	erin1 := requireAdd(pool, newMessage(erin, 1, 100))
+	assertPending(pool, carol0, carol1, erin0, erin1)
+
+	// Only local messages and the sender's own remain.
+	_, err = pool.Add(ctx, newMessage(erin, 2, 100), 0)
+	assert.Error(t, err)
+}
+
+func TestMessagePoolSenderQuota(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	alice, bob := mockSigner.Addresses[0], mockSigner.Addresses[1]
+	newMessage := func(from address.Address, nonce uint64) *types.SignedMessage {
+		return mustResignMessage(mockSigner, newSignedMessage(), func(m *types.UnsignedMessage) {
+			m.From = from
+			m.CallSeqNum = types.Uint64(nonce)
+		})
+	}
+
+	cfg := config.NewDefaultConfig().Mpool
+	cfg.MaxPendingPerSender = 2
+	pool := message.NewPool(cfg, th.NewMockMessagePoolValidator(), fakeLocalAddresses{bob: true})
+
+	reqAdd(t, pool, 0, newMessage(alice, 0))
+	assert.False(t, pool.SenderQuotaReached(newMessage(alice, 1)))
+	second := newMessage(alice, 1)
+	reqAdd(t, pool, 0, second)
+	assert.True(t, pool.SenderQuotaReached(newMessage(alice, 2)))
+
+	_, err := pool.Add(ctx, newMessage(alice, 2), 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "quota")
+
+	// A replacement does not count against the quota.
+	replacement := mustResignMessage(mockSigner, second, func(m *types.UnsignedMessage) {
+		m.GasPrice = types.NewGasPrice(100)
+	})
+	assert.False(t, pool.SenderQuotaReached(replacement))
+	reqAdd(t, pool, 0, replacement)
+
+	// Removing the replaced message has no effect, removing pending ones frees the quota.
+	c, err := second.Cid()
+	require.NoError(t, err)
+	pool.Remove(c)
+	assert.True(t, pool.SenderQuotaReached(newMessage(alice, 2)))
+	for _, msg := range pool.Pending() {
+		c, err := msg.Cid()
+		require.NoError(t, err)
+		pool.Remove(c)
+	}
+	assert.False(t, pool.SenderQuotaReached(newMessage(alice, 2)))
+
+	// Local addresses have no quota.
+	reqAdd(t, pool, 0, newMessage(bob, 0), newMessage(bob, 1), newMessage(bob, 2))
+	assert.False(t, pool.SenderQuotaReached(newMessage(bob, 3)))
+}
+
+func TestMessagePoolPersistence(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	ds := datastore.NewMapDatastore()
+	cfg := config.NewDefaultConfig().Mpool
+
+	pool := message.NewPersistentPool(cfg, th.NewMockMessagePoolValidator(), nil, ds)
+	msgs := types.NewSignedMsgs(4, mockSigner)
+	reqAdd(t, pool, 1, msgs[0], msgs[1])
+	reqAdd(t, pool, 2, msgs[2], msgs[3])
+	c3, err := msgs[3].Cid()
+	require.NoError(t, err)
+	pool.Remove(c3)
+
+	// The messages are restored with the height they were added at.
+	reloaded := message.NewPersistentPool(cfg, th.NewMockMessagePoolValidator(), nil, ds)
+	loaded, err := reloaded.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, loaded)
+	assert.ElementsMatch(t, msgs[:3], reloaded.Pending())
+	assert.Len(t, reloaded.PendingBefore(2), 2)
+
+	// Messages no longer valid are dropped.
+	validator := th.NewMockMessagePoolValidator()
+	validator.Valid = false
+	invalidated := message.NewPersistentPool(cfg, validator, nil, ds)
+	loaded, err = invalidated.Load(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, loaded)
+	loaded, err = message.NewPersistentPool(cfg, th.NewMockMessagePoolValidator(), nil, ds).Load(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, loaded)
+}
+
+func TestMessagePoolEvents(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("publishes changes", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
+		sub := pool.Subscribe(ctx)
+
+		assertEvent := func(typ message.PoolEventType, msg *types.SignedMessage) {
+			event := <-sub.Events()
+			c, err := msg.Cid()
+			require.NoError(t, err)
+			assert.Equal(t, typ, event.Type)
+			assert.Equal(t, c, event.Cid)
+			assert.Equal(t, msg, event.Message)
+		}
+
+		msg1 := newSignedMessage()
+		msg2 := mustSetNonce(mockSigner, newSignedMessage(), 1)
+		msg3 := mustSetNonce(mockSigner, newSignedMessage(), 2)
+		reqAdd(t, pool, 0, msg1, msg2, msg3)
+		assertEvent(message.PoolEventAdd, msg1)
+		assertEvent(message.PoolEventAdd, msg2)
+		assertEvent(message.PoolEventAdd, msg3)
+
+		replacement := mustResignMessage(mockSigner, msg2, func(m *types.UnsignedMessage) {
+			m.GasPrice = types.NewGasPrice(100)
+		})
+		reqAdd(t, pool, 0, replacement)
+		assertEvent(message.PoolEventEvict, msg2)
+		assertEvent(message.PoolEventAdd, replacement)
+
+		c1, err := msg1.Cid()
+		require.NoError(t, err)
+		pool.RemoveIncluded(c1)
+		assertEvent(message.PoolEventIncluded, msg1)
+		c3, err := msg3.Cid()
+		require.NoError(t, err)
+		pool.Remove(c3)
+		assertEvent(message.PoolEventRemove, msg3)
+
+		// Removing a message not in the pool is not an event.
+		pool.Remove(c3)
+		cancel()
+		_, ok := <-sub.Events()
+		assert.False(t, ok)
+		assert.NoError(t, sub.Err())
+	})
+
+	t.Run("drops a slow subscriber", func(t *testing.T) {
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
+		sub := pool.Subscribe(context.Background())
+
+		// More events than can be buffered anywhere along the way.
+		reqAdd(t, pool, 0, types.NewSignedMsgs(message.PoolSubscriptionBufferSize+200, mockSigner)...)
+		received := 0
+		for range sub.Events() {
+			received++
+		}
+		assert.Equal(t, message.PoolSubscriptionBufferSize, received)
+		assert.Equal(t, message.ErrPoolSubscriberTooSlow, sub.Err())
+	})
+
+	t.Run("ends subscriptions when closed", func(t *testing.T) {
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
+		sub := pool.Subscribe(context.Background())
+
+		pool.Close()
+		_, ok := <-sub.Events()
+		assert.False(t, ok)
+		assert.NoError(t, sub.Err())
+
+		// The pool is still usable but no longer publishes events.
+		reqAdd(t, pool, 0, newSignedMessage())
+		_, ok = <-pool.Subscribe(context.Background()).Events()
+		assert.False(t, ok)
+		pool.Close()
+	})
+}
+
 func TestMessagePoolDedup(t *testing.T) {
 	tf.UnitTest(t)
 
 	ctx := context.Background()
 
-	pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+	pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 	msg1 := newSignedMessage()
 
 	assert.Len(t, pool.Pending(), 0)
@@ -140,7 +435,7 @@ func TestMessagePoolAsync(t *testing.T) {
 	mpoolCfg.MaxPoolSize = count
 	msgs := types.NewSignedMsgs(count, mockSigner)
 
-	pool := message.NewPool(mpoolCfg, th.NewMockMessagePoolValidator())
+	pool := message.NewPool(mpoolCfg, th.NewMockMessagePoolValidator(), nil)
 	var wg sync.WaitGroup
 
 	for i := uint(0); i < 4; i++ {
@@ -162,7 +457,7 @@ func TestLargestNonce(t *testing.T) {
 	tf.UnitTest(t)
 
 	t.Run("No matches", func(t *testing.T) {
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 
 		m := types.NewSignedMsgs(2, mockSigner)
 		reqAdd(t, p, 0, m[0], m[1])
@@ -172,7 +467,7 @@ func TestLargestNonce(t *testing.T) {
 	})
 
 	t.Run("Match, largest is zero", func(t *testing.T) {
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 
 		m := types.NewMsgsWithAddrs(1, mockSigner.Addresses)
 		m[0].CallSeqNum = 0
@@ -188,7 +483,7 @@ func TestLargestNonce(t *testing.T) {
 	})
 
 	t.Run("Match", func(t *testing.T) {
-		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		p := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 
 		m := types.NewMsgsWithAddrs(3, mockSigner.Addresses)
 		m[1].CallSeqNum = 1
@@ -14,7 +14,7 @@ import (
 )
 
 func TestDefaultMessagePublisher_Publish(t *testing.T) {
-	pool := message.NewPool(config.NewDefaultConfig().Mpool, testhelpers.NewMockMessagePoolValidator())
+	pool := message.NewPool(config.NewDefaultConfig().Mpool, testhelpers.NewMockMessagePoolValidator(), nil)
 
 	ms, _ := types.NewMockSignersAndKeyInfo(2)
 	msg := types.NewUnsignedMessage(ms.Addresses[0], ms.Addresses[1], 0, types.ZeroAttoFIL, types.InvalidMethodID, []byte{})
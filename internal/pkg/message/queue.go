@@ -2,16 +2,31 @@ package message
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-address"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 )
 
+func init() {
+	encoding.RegisterIpldCborType(Queued{})
+}
+
+// queueDatastorePrefix is the datastore namespace under which a persistent queue keeps its
+// messages by sender and nonce.
+var queueDatastorePrefix = datastore.NewKey("/outbox")
+
 var (
 	mqSizeGa   = metrics.NewInt64Gauge("message_queue_size", "The size of the message queue")
 	mqOldestGa = metrics.NewInt64Gauge("message_queue_oldest", "The age of the oldest message in the queue or zero when empty")
@@ -24,17 +39,24 @@ var (
 // not enforced.
 // A message queue is intended to record outbound messages that have been transmitted but not yet appeared in a block,
 // where the stamp could be block height.
+// A persistent queue also keeps its messages in a datastore to be loaded again after a restart.
 // Queue is safe for concurrent access.
 type Queue struct {
 	lk sync.RWMutex
 	// Message queues keyed by sending actor address, in nonce order
 	queues map[address.Address][]*Queued
+	// Persists the queued messages, nil if the queue is not persistent
+	ds datastore.Datastore
 }
 
 // Queued is a message an the stamp it was enqueued with.
 type Queued struct {
 	Msg   *types.SignedMessage
 	Stamp uint64
+	// Unix time in seconds of the message's last broadcast, zero if it was never broadcast
+	LastBroadcast uint64
+	// Number of times the message has been broadcast
+	Attempts uint64
 }
 
 // NewQueue constructs a new, empty queue.
@@ -44,6 +66,84 @@ func NewQueue() *Queue {
 	}
 }
 
+// NewPersistentQueue constructs a new, empty queue keeping its messages in `ds`. Messages
+// persisted by a previous queue are restored by Load.
+func NewPersistentQueue(ds datastore.Datastore) *Queue {
+	mq := NewQueue()
+	mq.ds = ds
+	return mq
+}
+
+func queueDatastoreKey(sender address.Address, nonce types.Uint64) datastore.Key {
+	return queueDatastorePrefix.ChildString(sender.String()).ChildString(fmt.Sprintf("%020d", nonce))
+}
+
+// Load replaces the contents of the queue with the messages persisted by a previous queue on
+// the same datastore.
+func (mq *Queue) Load(ctx context.Context) error {
+	defer func() {
+		mqSizeGa.Set(ctx, mq.Size())
+		mqOldestGa.Set(ctx, int64(mq.Oldest()))
+	}()
+	if mq.ds == nil {
+		return nil
+	}
+
+	res, err := mq.ds.Query(query.Query{Prefix: queueDatastorePrefix.String() + "/"})
+	if err != nil {
+		return errors.Wrap(err, "failed to query persisted messages")
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return errors.Wrap(err, "failed to read persisted messages")
+	}
+
+	queues := make(map[address.Address][]*Queued)
+	for _, entry := range entries {
+		qm := &Queued{}
+		if err := encoding.Decode(entry.Value, qm); err != nil {
+			return errors.Wrapf(err, "failed to decode persisted message %s", entry.Key)
+		}
+		from := qm.Msg.Message.From
+		queues[from] = append(queues[from], qm)
+	}
+	for _, q := range queues {
+		sort.Slice(q, func(i, j int) bool { return q[i].Msg.Message.CallSeqNum < q[j].Msg.Message.CallSeqNum })
+	}
+
+	mq.lk.Lock()
+	defer mq.lk.Unlock()
+	mq.queues = queues
+	return nil
+}
+
+// persist writes a queued message to the datastore. The caller must hold the lock.
+func (mq *Queue) persist(qm *Queued) {
+	if mq.ds == nil {
+		return
+	}
+	bs, err := encoding.Encode(qm)
+	if err == nil {
+		err = mq.ds.Put(queueDatastoreKey(qm.Msg.Message.From, qm.Msg.Message.CallSeqNum), bs)
+	}
+	if err != nil {
+		log.Warnf("failed to persist queued message from %s with nonce %d: %s", qm.Msg.Message.From, qm.Msg.Message.CallSeqNum, err)
+	}
+}
+
+// unpersist deletes queued messages from the datastore. The caller must hold the lock.
+func (mq *Queue) unpersist(qms ...*Queued) {
+	if mq.ds == nil {
+		return
+	}
+	for _, qm := range qms {
+		err := mq.ds.Delete(queueDatastoreKey(qm.Msg.Message.From, qm.Msg.Message.CallSeqNum))
+		if err != nil && err != datastore.ErrNotFound {
+			log.Warnf("failed to delete queued message from %s with nonce %d: %s", qm.Msg.Message.From, qm.Msg.Message.CallSeqNum, err)
+		}
+	}
+}
+
 // Enqueue appends a new message for an address. If the queue already contains any messages for
 // from same address, the new message's nonce must be exactly one greater than the largest nonce
 // present.
@@ -63,7 +163,48 @@ func (mq *Queue) Enqueue(ctx context.Context, msg *types.SignedMessage, stamp ui
 			return errors.Errorf("Invalid nonce in %d in enqueue, expected %d", msg.Message.CallSeqNum, nextNonce)
 		}
 	}
-	mq.queues[msg.Message.From] = append(q, &Queued{msg, stamp})
+	qm := &Queued{Msg: msg, Stamp: stamp}
+	mq.queues[msg.Message.From] = append(q, qm)
+	mq.persist(qm)
+	return nil
+}
+
+// EnqueueAll appends new messages for an address, in order. The messages must be from the same
+// address with contiguous nonces, the first following the largest nonce present as for Enqueue.
+// Either all the messages are enqueued or none is.
+func (mq *Queue) EnqueueAll(ctx context.Context, msgs []*types.SignedMessage, stamp uint64) error {
+	defer func() {
+		mqSizeGa.Set(ctx, mq.Size())
+		mqOldestGa.Set(ctx, int64(mq.Oldest()))
+	}()
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	mq.lk.Lock()
+	defer mq.lk.Unlock()
+
+	from := msgs[0].Message.From
+	q := mq.queues[from]
+	nextNonce := msgs[0].Message.CallSeqNum
+	if len(q) > 0 {
+		nextNonce = q[len(q)-1].Msg.Message.CallSeqNum + 1
+	}
+	for _, msg := range msgs {
+		if msg.Message.From != from {
+			return errors.Errorf("Invalid sender %s in enqueue, expected %s", msg.Message.From, from)
+		}
+		if msg.Message.CallSeqNum != nextNonce {
+			return errors.Errorf("Invalid nonce in %d in enqueue, expected %d", msg.Message.CallSeqNum, nextNonce)
+		}
+		nextNonce++
+	}
+	for _, msg := range msgs {
+		qm := &Queued{Msg: msg, Stamp: stamp}
+		q = append(q, qm)
+		mq.persist(qm)
+	}
+	mq.queues[from] = q
 	return nil
 }
 
@@ -85,10 +226,53 @@ func (mq *Queue) Requeue(ctx context.Context, msg *types.SignedMessage, stamp ui
 			return errors.Errorf("Invalid nonce %d in requeue, expected %d", msg.Message.CallSeqNum, prevNonce)
 		}
 	}
-	mq.queues[msg.Message.From] = append([]*Queued{{msg, stamp}}, q...)
+	qm := &Queued{Msg: msg, Stamp: stamp}
+	mq.queues[msg.Message.From] = append([]*Queued{qm}, q...)
+	mq.persist(qm)
 	return nil
 }
 
+// Replace replaces the queued message with the same sender and nonce as `msg`, which takes
+// the new stamp. Returns the replaced message, or an error if there is none.
+func (mq *Queue) Replace(ctx context.Context, msg *types.SignedMessage, stamp uint64) (*types.SignedMessage, error) {
+	defer func() {
+		mqOldestGa.Set(ctx, int64(mq.Oldest()))
+	}()
+
+	mq.lk.Lock()
+	defer mq.lk.Unlock()
+
+	for _, qm := range mq.queues[msg.Message.From] {
+		if qm.Msg.Message.CallSeqNum == msg.Message.CallSeqNum {
+			replaced := qm.Msg
+			qm.Msg = msg
+			qm.Stamp = stamp
+			qm.LastBroadcast = 0
+			qm.Attempts = 0
+			mq.persist(qm)
+			return replaced, nil
+		}
+	}
+	return nil, errors.Errorf("no queued message from %s with nonce %d", msg.Message.From, msg.Message.CallSeqNum)
+}
+
+// RecordBroadcast records that `msg` was broadcast at `at`. Returns the number of times it has
+// been broadcast, or false if it is no longer queued.
+func (mq *Queue) RecordBroadcast(msg *types.SignedMessage, at time.Time) (uint64, bool) {
+	mq.lk.Lock()
+	defer mq.lk.Unlock()
+
+	for _, qm := range mq.queues[msg.Message.From] {
+		if qm.Msg.Message.CallSeqNum == msg.Message.CallSeqNum && qm.Msg.Equals(msg) {
+			qm.LastBroadcast = uint64(at.Unix())
+			qm.Attempts++
+			mq.persist(qm)
+			return qm.Attempts, true
+		}
+	}
+	return 0, false
+}
+
 // RemoveNext removes and returns a single message from the queue, if it bears the expected nonce value, with found = true.
 // Returns found = false if the queue is empty or the expected nonce is less than any in the queue for that address
 // (indicating the message had already been removed).
@@ -108,6 +292,7 @@ func (mq *Queue) RemoveNext(ctx context.Context, sender address.Address, expecte
 		head := q[0]
 		if expectedNonce == uint64(head.Msg.Message.CallSeqNum) {
 			mq.queues[sender] = q[1:] // pop the head
+			mq.unpersist(head)
 			msg = head.Msg
 			found = true
 		} else if expectedNonce > uint64(head.Msg.Message.CallSeqNum) {
@@ -131,6 +316,7 @@ func (mq *Queue) Clear(ctx context.Context, sender address.Address) bool {
 
 	q := mq.queues[sender]
 	delete(mq.queues, sender)
+	mq.unpersist(q...)
 	return len(q) > 0
 }
 
@@ -157,6 +343,7 @@ func (mq *Queue) ExpireBefore(ctx context.Context, stamp uint64) map[address.Add
 			}
 
 			mq.queues[sender] = []*Queued{}
+			mq.unpersist(q...)
 		}
 	}
 	return expired
@@ -224,6 +411,22 @@ func (mq *Queue) Oldest() (oldest uint64) {
 	return oldest
 }
 
+// Get returns a copy of the queued message with CID `c`, if there is one.
+func (mq *Queue) Get(c cid.Cid) (*Queued, bool) {
+	mq.lk.RLock()
+	defer mq.lk.RUnlock()
+	for _, q := range mq.queues {
+		for _, qm := range q {
+			mc, err := qm.Msg.Cid()
+			if err == nil && mc.Equals(c) {
+				out := *qm
+				return &out, true
+			}
+		}
+	}
+	return nil, false
+}
+
 // List returns a copy of the list of messages queued for an address.
 func (mq *Queue) List(sender address.Address) []*Queued {
 	mq.lk.RLock()
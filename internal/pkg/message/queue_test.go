@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-datastore"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -183,6 +184,93 @@ func TestMessageQueue(t *testing.T) {
 		assert.Error(t, err)
 	})
 
+	t.Run("enqueue all or none", func(t *testing.T) {
+		q := message.NewQueue()
+		requireEnqueue(q, mm.NewSignedMessage(alice, 0), 100)
+
+		// A gap in the batch.
+		err := q.EnqueueAll(ctx, []*types.SignedMessage{mm.NewSignedMessage(alice, 1), mm.NewSignedMessage(alice, 3)}, 101)
+		assert.Error(t, err)
+		// A different sender.
+		err = q.EnqueueAll(ctx, []*types.SignedMessage{mm.NewSignedMessage(alice, 1), mm.NewSignedMessage(bob, 2)}, 101)
+		assert.Error(t, err)
+		// Not following the queue.
+		err = q.EnqueueAll(ctx, []*types.SignedMessage{mm.NewSignedMessage(alice, 2), mm.NewSignedMessage(alice, 3)}, 101)
+		assert.Error(t, err)
+		assert.Len(t, q.List(alice), 1)
+
+		batch := []*types.SignedMessage{mm.NewSignedMessage(alice, 1), mm.NewSignedMessage(alice, 2)}
+		require.NoError(t, q.EnqueueAll(ctx, batch, 101))
+		queued := q.List(alice)
+		require.Len(t, queued, 3)
+		assert.Equal(t, &message.Queued{Msg: batch[0], Stamp: 101}, queued[1])
+		assert.Equal(t, &message.Queued{Msg: batch[1], Stamp: 101}, queued[2])
+		assertLargestNonce(q, alice, 2)
+	})
+
+	t.Run("replace and get", func(t *testing.T) {
+		q := message.NewQueue()
+		msgs := []*types.SignedMessage{
+			mm.NewSignedMessage(alice, 0),
+			mm.NewSignedMessage(alice, 1),
+		}
+		requireEnqueue(q, msgs[0], 100)
+		requireEnqueue(q, msgs[1], 101)
+
+		c, err := msgs[1].Cid()
+		require.NoError(t, err)
+		queued, found := q.Get(c)
+		require.True(t, found)
+		assert.Equal(t, &message.Queued{Msg: msgs[1], Stamp: 101}, queued)
+
+		replacement := mm.NewSignedMessage(alice, 1)
+		replaced, err := q.Replace(ctx, replacement, 102)
+		require.NoError(t, err)
+		assert.Equal(t, msgs[1], replaced)
+		assert.Equal(t, []*message.Queued{{Msg: msgs[0], Stamp: 100}, {Msg: replacement, Stamp: 102}}, q.List(alice))
+		_, found = q.Get(c)
+		assert.False(t, found)
+
+		_, err = q.Replace(ctx, mm.NewSignedMessage(alice, 2), 102)
+		assert.Error(t, err)
+		_, err = q.Replace(ctx, mm.NewSignedMessage(bob, 0), 102)
+		assert.Error(t, err)
+	})
+
+	t.Run("persistence", func(t *testing.T) {
+		ds := datastore.NewMapDatastore()
+		q := message.NewPersistentQueue(ds)
+		fromAlice := []*types.SignedMessage{
+			mm.NewSignedMessage(alice, 1),
+			mm.NewSignedMessage(alice, 2),
+			mm.NewSignedMessage(alice, 3),
+		}
+		fromBob := []*types.SignedMessage{
+			mm.NewSignedMessage(bob, 10),
+			mm.NewSignedMessage(bob, 11),
+		}
+		requireEnqueue(q, fromAlice[1], 100)
+		requireEnqueue(q, fromAlice[2], 101)
+		requireRequeue(q, fromAlice[0], 99)
+		requireEnqueue(q, fromBob[0], 200)
+		requireEnqueue(q, fromBob[1], 201)
+		requireRemoveNext(q, alice, 1)
+		replacement := mm.NewSignedMessage(alice, 3)
+		_, err := q.Replace(ctx, replacement, 102)
+		require.NoError(t, err)
+		q.Clear(ctx, bob)
+
+		reloaded := message.NewPersistentQueue(ds)
+		require.NoError(t, reloaded.Load(ctx))
+		assert.Equal(t, q.List(alice), reloaded.List(alice))
+		assert.Equal(t, []*message.Queued{{Msg: fromAlice[1], Stamp: 100}, {Msg: replacement, Stamp: 102}}, reloaded.List(alice))
+		assert.Empty(t, reloaded.List(bob))
+
+		reloaded.ExpireBefore(ctx, 101)
+		require.NoError(t, q.Load(ctx))
+		assert.Empty(t, q.List(alice))
+	})
+
 	t.Run("largest nonce", func(t *testing.T) {
 		msgs := []*types.SignedMessage{
 			mm.NewSignedMessage(alice, 0),
@@ -0,0 +1,118 @@
+package message
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+var mqRebroadcastCt = metrics.NewInt64Counter("message_queue_rebroadcast", "The number of messages rebroadcast from the queue")
+
+// Rebroadcaster broadcasts the messages in an outbound queue again while they are not mined,
+// in case their last broadcast was lost to a network partition or dropped by peers.
+// A message is rebroadcast once `interval` has passed since its last broadcast. The interval
+// doubles after each rebroadcast, until the message has been rebroadcast `maxRebroadcasts`
+// times. Messages that were never broadcast are left alone.
+type Rebroadcaster struct {
+	queue     *Queue
+	publisher publisher
+	chains    chainProvider
+
+	interval        time.Duration
+	maxRebroadcasts uint64
+
+	journal journal.Writer
+	clock   clock.Clock
+}
+
+// NewRebroadcaster creates a rebroadcaster for the messages in `queue`. A zero `interval` or
+// `maxRebroadcasts` disables it.
+func NewRebroadcaster(queue *Queue, publisher publisher, chains chainProvider, interval time.Duration, maxRebroadcasts uint, jw journal.Writer, clk clock.Clock) *Rebroadcaster {
+	return &Rebroadcaster{
+		queue:           queue,
+		publisher:       publisher,
+		chains:          chains,
+		interval:        interval,
+		maxRebroadcasts: uint64(maxRebroadcasts),
+		journal:         jw,
+		clock:           clk,
+	}
+}
+
+// Run rebroadcasts the messages that are due every interval until the context is done.
+func (r *Rebroadcaster) Run(ctx context.Context) {
+	if r.interval == 0 || r.maxRebroadcasts == 0 {
+		return
+	}
+
+	ticker := r.clock.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.Chan():
+			r.RebroadcastDue(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RebroadcastDue rebroadcasts the queued messages whose wait since their last broadcast is
+// over. Returns the number of messages rebroadcast.
+// Publishing blocks while there are no peers, so this may not return until the context is done.
+func (r *Rebroadcaster) RebroadcastDue(ctx context.Context) int {
+	if r.interval == 0 || r.maxRebroadcasts == 0 {
+		return 0
+	}
+
+	height, err := tipsetHeight(r.chains, r.chains.GetHead())
+	if err != nil {
+		log.Errorf("not rebroadcasting messages: failed to get block height: %s", err)
+		return 0
+	}
+
+	now := r.clock.Now()
+	var due []*types.SignedMessage
+	for _, sender := range r.queue.Queues() {
+		for _, qm := range r.queue.List(sender) {
+			if r.isDue(qm, now) {
+				due = append(due, qm.Msg)
+			}
+		}
+	}
+
+	for _, msg := range due {
+		err := r.publisher.Publish(ctx, msg, height, true)
+		// Failed attempts count too, so that the wait keeps growing during a partition.
+		attempts, _ := r.queue.RecordBroadcast(msg, now)
+		mqRebroadcastCt.Inc(ctx, 1)
+
+		c, cidErr := msg.Cid()
+		if cidErr != nil {
+			log.Errorf("failed to compute cid of rebroadcast message: %s", cidErr)
+		}
+		r.journal.Write("Rebroadcast",
+			"cid", c.String(), "from", msg.Message.From.String(), "nonce", uint64(msg.Message.CallSeqNum),
+			"attempts", attempts, "error", err)
+		if err != nil {
+			log.Warnf("error: %s rebroadcasting message %s", err, c)
+		}
+		if attempts > r.maxRebroadcasts {
+			log.Warnf("message %s rebroadcast %d times without being mined, giving up", c, r.maxRebroadcasts)
+		}
+	}
+	return len(due)
+}
+
+// isDue returns true if `qm` was broadcast and the wait since then is over.
+func (r *Rebroadcaster) isDue(qm *Queued, now time.Time) bool {
+	if qm.Attempts == 0 || qm.Attempts > r.maxRebroadcasts {
+		return false
+	}
+	wait := r.interval << (qm.Attempts - 1)
+	return !now.Before(time.Unix(int64(qm.LastBroadcast), 0).Add(wait))
+}
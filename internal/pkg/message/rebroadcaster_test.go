@@ -0,0 +1,90 @@
+package message_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin/account"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+func TestRebroadcaster(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	w, _ := types.NewMockSignersAndKeyInfo(1)
+	sender := w.Addresses[0]
+	toAddr := vmaddr.NewForTestGetter()()
+	clk := th.NewFakeClock(time.Unix(1234567890, 0))
+
+	provider := message.NewFakeProvider(t)
+	head := provider.BuildOneOn(block.UndefTipSet, func(b *chain.BlockBuilder) {
+		b.IncHeight(1000)
+	})
+	actr, _ := account.NewActor(types.ZeroAttoFIL)
+	provider.SetHeadAndActor(t, head.Key(), sender, actr)
+
+	queue := message.NewQueue()
+	ob := message.NewOutbox(w, message.FakeValidator{}, queue, &message.MockPublisher{}, message.NullPolicy{}, provider, provider, newOutboxTestJournal(t), clk)
+	// Two broadcast messages and one kept local.
+	for _, bcast := range []bool{true, true, false} {
+		_, pubDone, err := ob.Send(ctx, sender, toAddr, types.ZeroAttoFIL, types.NewGasPrice(0), types.NewGasUnits(0), bcast, types.InvalidMethodID)
+		require.NoError(t, err)
+		require.NoError(t, <-pubDone)
+	}
+	queued := queue.List(sender)
+	require.Len(t, queued, 3)
+	assert.Equal(t, uint64(1), queued[0].Attempts)
+	assert.Equal(t, uint64(clk.Now().Unix()), queued[0].LastBroadcast)
+	assert.Equal(t, uint64(0), queued[2].Attempts)
+
+	publisher := &chanPublisher{published: make(chan *types.SignedMessage, 10)}
+	rb := message.NewRebroadcaster(queue, publisher, provider, time.Minute, 2, newOutboxTestJournal(t), clk)
+
+	assertRebroadcast := func(expectedAttempts uint64) {
+		assert.Equal(t, 2, rb.RebroadcastDue(ctx))
+		queued := queue.List(sender)
+		for _, qm := range queued[:2] {
+			assert.Equal(t, qm.Msg, <-publisher.published)
+			assert.Equal(t, expectedAttempts, qm.Attempts)
+			assert.Equal(t, uint64(clk.Now().Unix()), qm.LastBroadcast)
+		}
+		assert.Equal(t, uint64(0), queued[2].Attempts)
+	}
+
+	assert.Equal(t, 0, rb.RebroadcastDue(ctx))
+	clk.Advance(time.Minute)
+	assertRebroadcast(2)
+
+	// The wait doubles after each rebroadcast.
+	clk.Advance(time.Minute)
+	assert.Equal(t, 0, rb.RebroadcastDue(ctx))
+	clk.Advance(time.Minute)
+	assertRebroadcast(3)
+
+	// No more than the maximum number of rebroadcasts.
+	clk.Advance(time.Hour)
+	assert.Equal(t, 0, rb.RebroadcastDue(ctx))
+	assert.Empty(t, publisher.published)
+
+	// A replacement starts over.
+	c, err := queued[1].Msg.Cid()
+	require.NoError(t, err)
+	_, err = ob.Replace(ctx, c, types.NewGasPrice(1), true)
+	require.NoError(t, err)
+	replacement := queue.List(sender)[1]
+	assert.Equal(t, uint64(1), replacement.Attempts)
+	clk.Advance(time.Minute)
+	assert.Equal(t, 1, rb.RebroadcastDue(ctx))
+	assert.Equal(t, replacement.Msg, <-publisher.published)
+}
@@ -9,10 +9,13 @@ import (
 	"time"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
 	"github.com/pkg/errors"
 
 	bls "github.com/filecoin-project/filecoin-ffi"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 )
@@ -56,38 +59,18 @@ func (w *DefaultWorker) Generate(
 
 	blockHeight := baseHeight + nullBlockCount + 1
 
-	// Construct list of message candidates for inclusion.
-	// These messages will be processed, and those that fail excluded from the block.
-	pending := w.messageSource.Pending()
-	mq := NewMessageQueue(pending)
-	candidateMsgs := orderMessageCandidates(mq.Drain())
-
-	// Dragons: ask something to select and order messages to include
-
-	var blsAccepted []*types.SignedMessage
-	var secpAccepted []*types.SignedMessage
-
-	// Align the results with the candidate signed messages to accumulate the messages lists
-	// to include in the block, and handle failed messages.
-	for _, msg := range candidateMsgs {
-		if msg.Message.From.Protocol() == address.BLS {
-			blsAccepted = append(blsAccepted, msg)
-		} else {
-			secpAccepted = append(secpAccepted, msg)
-		}
-
+	// Select the pending messages to include that can be applied on the state of the base tipset.
+	baseState, err := w.getStateTree(ctx, baseTipSet.Key())
+	if err != nil {
+		return nil, errors.Wrap(err, "get base tip set state")
 	}
-
-	// Create an aggregage signature for messages
-	unwrappedBLSMessages, blsAggregateSig, err := aggregateBLS(blsAccepted)
+	selected, err := SelectMessages(ctx, w.messageSource.Pending(), baseState, consensus.NewDefaultMessageValidator(), types.BlockGasLimit)
 	if err != nil {
-		return nil, errors.Wrap(err, "could not aggregate bls messages")
+		return nil, errors.Wrap(err, "select messages")
 	}
-
-	// Persist messages to ipld storage
-	txMetaCid, err := w.messageStore.StoreMessages(ctx, secpAccepted, unwrappedBLSMessages)
+	txMetaCid, blsAggregateSig, err := storeBlockMessages(ctx, selected, w.messageStore)
 	if err != nil {
-		return nil, errors.Wrap(err, "error persisting messages")
+		return nil, err
 	}
 
 	// get tipset state root and receipt root
@@ -128,6 +111,39 @@ func (w *DefaultWorker) Generate(
 	return next, nil
 }
 
+// storeBlockMessages persists the messages selected for a block, returning the CID of the
+// block's message collection and the aggregate signature of its BLS messages.
+func storeBlockMessages(ctx context.Context, selected []*types.SignedMessage, store chain.MessageWriter) (cid.Cid, types.Signature, error) {
+	candidateMsgs := orderMessageCandidates(selected)
+
+	var blsAccepted []*types.SignedMessage
+	var secpAccepted []*types.SignedMessage
+
+	// Align the results with the candidate signed messages to accumulate the messages lists
+	// to include in the block, and handle failed messages.
+	for _, msg := range candidateMsgs {
+		if msg.Message.From.Protocol() == address.BLS {
+			blsAccepted = append(blsAccepted, msg)
+		} else {
+			secpAccepted = append(secpAccepted, msg)
+		}
+
+	}
+
+	// Create an aggregage signature for messages
+	unwrappedBLSMessages, blsAggregateSig, err := aggregateBLS(blsAccepted)
+	if err != nil {
+		return cid.Undef, nil, errors.Wrap(err, "could not aggregate bls messages")
+	}
+
+	// Persist messages to ipld storage
+	txMetaCid, err := store.StoreMessages(ctx, secpAccepted, unwrappedBLSMessages)
+	if err != nil {
+		return cid.Undef, nil, errors.Wrap(err, "error persisting messages")
+	}
+	return txMetaCid, blsAggregateSig, nil
+}
+
 func aggregateBLS(blsMessages []*types.SignedMessage) ([]*types.UnsignedMessage, types.Signature, error) {
 	sigs := []bls.Signature{}
 	unwrappedMsgs := []*types.UnsignedMessage{}
@@ -0,0 +1,222 @@
+package mining
+
+// The InstantSealer produces the blocks of an instant-seal network. It seals a block on the
+// head as soon as messages arrive, and optionally at a fixed interval, without elections or
+// proofs of spacetime.
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// SealMessageSource provides the messages to seal into blocks and notifies of new ones.
+type SealMessageSource interface {
+	Pending() []*types.SignedMessage
+	Subscribe(ctx context.Context) *message.PoolSubscription
+}
+
+// InstantSealer seals blocks as the authorized signer of an instant-seal network.
+type InstantSealer struct {
+	signer   address.Address
+	wallet   types.Signer
+	interval time.Duration
+
+	getHead       func() (block.TipSet, error)
+	getStateTree  GetStateTree
+	getWeight     GetWeight
+	tsMetadata    tipSetMetadata
+	ticketGen     ticketGenerator
+	messageSource SealMessageSource
+	messageStore  chain.MessageWriter
+	clock         clock.ChainEpochClock
+	publish       func(context.Context, *block.Block) error
+}
+
+// InstantSealerParameters use for NewInstantSealer parameters
+type InstantSealerParameters struct {
+	Signer   address.Address
+	Wallet   types.Signer
+	Interval time.Duration
+
+	// consensus things
+	GetHead        func() (block.TipSet, error)
+	GetStateTree   GetStateTree
+	GetWeight      GetWeight
+	TipSetMetadata tipSetMetadata
+	TicketGen      ticketGenerator
+
+	// core filecoin things
+	MessageSource SealMessageSource
+	MessageStore  chain.MessageWriter
+	Clock         clock.ChainEpochClock
+	// Publish adds a sealed block to the chain, returning once it has been processed.
+	Publish func(context.Context, *block.Block) error
+}
+
+// NewInstantSealer instantiates a new InstantSealer.
+func NewInstantSealer(parameters InstantSealerParameters) *InstantSealer {
+	return &InstantSealer{
+		signer:        parameters.Signer,
+		wallet:        parameters.Wallet,
+		interval:      parameters.Interval,
+		getHead:       parameters.GetHead,
+		getStateTree:  parameters.GetStateTree,
+		getWeight:     parameters.GetWeight,
+		tsMetadata:    parameters.TipSetMetadata,
+		ticketGen:     parameters.TicketGen,
+		messageSource: parameters.MessageSource,
+		messageStore:  parameters.MessageStore,
+		clock:         parameters.Clock,
+		publish:       parameters.Publish,
+	}
+}
+
+// Run seals blocks until the context is done: a block including the pending messages as soon
+// as messages arrive, and a block every interval if the interval is not zero.
+func (s *InstantSealer) Run(ctx context.Context) {
+	wake := make(chan struct{}, 1)
+	go s.watchMessages(ctx, wake)
+
+	var tick <-chan time.Time
+	if s.interval > 0 {
+		ticker := s.clock.NewTicker(s.interval)
+		defer ticker.Stop()
+		tick = ticker.Chan()
+	}
+
+	for {
+		requireMessages := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+			requireMessages = true
+		case <-tick:
+		}
+
+		blk, err := s.Seal(ctx, requireMessages)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warnf("failed to seal block: %s", err)
+			}
+			continue
+		}
+		if blk == nil {
+			continue
+		}
+		if err := s.publish(ctx, blk); err != nil {
+			log.Warnf("error adding sealed block %s: %s", blk.Cid(), err)
+		}
+	}
+}
+
+// watchMessages signals `wake` when messages are added to the pool. Signals coalesce while
+// a block is being sealed, since the block includes all the messages pending by then.
+func (s *InstantSealer) watchMessages(ctx context.Context, wake chan<- struct{}) {
+	for ctx.Err() == nil {
+		sub := s.messageSource.Subscribe(ctx)
+		for event := range sub.Events() {
+			if event.Type != message.PoolEventAdd {
+				continue
+			}
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+		if err := sub.Err(); err != nil {
+			log.Warnf("resubscribing to message pool: %s", err)
+		}
+	}
+}
+
+// Seal creates a block on the head including the pending messages, signed by the signer. The
+// block is in the current epoch, waiting for the next one if the head is already in it. If
+// `requireMessages` is set and no pending message can be included, no block is sealed and Seal
+// returns nil.
+func (s *InstantSealer) Seal(ctx context.Context, requireMessages bool) (*block.Block, error) {
+	base, err := s.getHead()
+	if err != nil {
+		return nil, errors.Wrap(err, "get head")
+	}
+	baseHeight, err := base.Height()
+	if err != nil {
+		return nil, errors.Wrap(err, "get base tip set height")
+	}
+	now := s.clock.Now()
+	epoch := s.clock.EpochAtTime(now)
+	for !epoch.GreaterThan(types.NewBlockHeight(baseHeight)) {
+		s.clock.WaitNextEpoch(ctx)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		now = s.clock.Now()
+		epoch = s.clock.EpochAtTime(now)
+	}
+
+	baseState, err := s.getStateTree(ctx, base.Key())
+	if err != nil {
+		return nil, errors.Wrap(err, "get base tip set state")
+	}
+	selected, err := SelectMessages(ctx, s.messageSource.Pending(), baseState, consensus.NewDefaultMessageValidator(), types.BlockGasLimit)
+	if err != nil {
+		return nil, errors.Wrap(err, "select messages")
+	}
+	if requireMessages && len(selected) == 0 {
+		return nil, nil
+	}
+	txMetaCid, blsAggregateSig, err := storeBlockMessages(ctx, selected, s.messageStore)
+	if err != nil {
+		return nil, err
+	}
+
+	weight, err := s.getWeight(ctx, base)
+	if err != nil {
+		return nil, errors.Wrap(err, "get weight")
+	}
+	baseStateRoot, err := s.tsMetadata.GetTipSetStateRoot(base.Key())
+	if err != nil {
+		return nil, errors.Wrapf(err, "error retrieving state root for tipset %s", base.Key().String())
+	}
+	baseReceiptRoot, err := s.tsMetadata.GetTipSetReceiptsRoot(base.Key())
+	if err != nil {
+		return nil, errors.Wrapf(err, "error retrieving receipt root for tipset %s", base.Key().String())
+	}
+	prevTicket, err := base.MinTicket()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read parent ticket")
+	}
+	ticket, err := s.ticketGen.NextTicket(prevTicket, s.signer, s.wallet)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate ticket")
+	}
+
+	next := &block.Block{
+		Miner:           s.signer,
+		Height:          epoch.AsBigInt().Uint64(),
+		Messages:        e.NewCid(txMetaCid),
+		MessageReceipts: e.NewCid(baseReceiptRoot),
+		Parents:         base.Key(),
+		ParentWeight:    weight,
+		StateRoot:       e.NewCid(baseStateRoot),
+		Ticket:          ticket,
+		Timestamp:       uint64(now.Unix()),
+		BLSAggregateSig: blsAggregateSig,
+	}
+	next.BlockSig, err = s.wallet.SignBytes(next.SignatureData(), s.signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign block")
+	}
+	return next, nil
+}
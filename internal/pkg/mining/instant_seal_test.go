@@ -0,0 +1,150 @@
+package mining_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/mining"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+func TestInstantSealer_Seal(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer, _ := types.NewMockSignersAndKeyInfo(3)
+	sealer, alice, bob := signer.Addresses[0], signer.Addresses[1], signer.Addresses[2]
+
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	cst := cborutil.NewIpldStore(bs)
+	st := state.NewTree(cst)
+	require.NoError(t, st.SetActor(ctx, alice, actor.NewActor(types.AccountActorCodeCid, types.NewAttoFILFromFIL(100))))
+	getStateTree := func(context.Context, block.TipSetKey) (state.Tree, error) {
+		return st, nil
+	}
+
+	genesisTime := time.Unix(1000, 0)
+	fc := th.NewFakeClock(genesisTime.Add(10 * time.Second))
+	chainClock := clock.NewChainClockFromClock(uint64(genesisTime.Unix()), consensus.InstantSealBlockTime, fc)
+
+	var head block.TipSet
+	newParams := func(pool *message.Pool) mining.InstantSealerParameters {
+		return mining.InstantSealerParameters{
+			Signer: sealer,
+			Wallet: signer,
+
+			GetHead:        func() (block.TipSet, error) { return head, nil },
+			GetStateTree:   getStateTree,
+			GetWeight:      getWeightTest,
+			TipSetMetadata: fakeTSMetadata{},
+			TicketGen:      consensus.TicketMachine{},
+
+			MessageSource: pool,
+			MessageStore:  chain.NewMessageStore(bs),
+			Clock:         chainClock,
+		}
+	}
+	newSealer := func(pool *message.Pool) *mining.InstantSealer {
+		return mining.NewInstantSealer(newParams(pool))
+	}
+	newBase := func(height uint64) block.TipSet {
+		return th.RequireNewTipSet(t, &block.Block{Height: height, ParentWeight: fbig.Zero(), StateRoot: e.NewCid(types.CidFromString(t, "base")), Ticket: block.Ticket{VRFProof: []byte{0}}})
+	}
+
+	t.Run("seals pending messages", func(t *testing.T) {
+		head = newBase(0)
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
+
+		blk, err := newSealer(pool).Seal(ctx, true)
+		require.NoError(t, err)
+		assert.Nil(t, blk)
+
+		msg := types.NewMeteredMessage(alice, bob, 0, types.NewAttoFILFromFIL(1), types.SendMethodID, nil, types.NewGasPrice(1), types.NewGasUnits(100))
+		smsg, err := types.NewSignedMessage(*msg, signer)
+		require.NoError(t, err)
+		_, err = pool.Add(ctx, smsg, 0)
+		require.NoError(t, err)
+
+		blk, err = newSealer(pool).Seal(ctx, true)
+		require.NoError(t, err)
+		require.NotNil(t, blk)
+		assert.Equal(t, sealer, blk.Miner)
+		assert.Equal(t, uint64(10), blk.Height)
+		assert.Equal(t, head.Key(), blk.Parents)
+		assert.True(t, types.IsValidSignature(blk.SignatureData(), sealer, blk.BlockSig))
+		assert.True(t, consensus.TicketMachine{}.IsValidTicket(block.Ticket{VRFProof: []byte{0}}, blk.Ticket, sealer))
+
+		secpMsgs, blsMsgs, err := chain.NewMessageStore(bs).LoadMessages(ctx, blk.Messages.Cid)
+		require.NoError(t, err)
+		require.Len(t, secpMsgs, 1)
+		assert.Equal(t, requireCid(t, smsg), requireCid(t, secpMsgs[0]))
+		assert.Empty(t, blsMsgs)
+	})
+
+	t.Run("waits for the next epoch", func(t *testing.T) {
+		head = newBase(10)
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
+
+		sealed := make(chan *block.Block)
+		go func() {
+			blk, err := newSealer(pool).Seal(ctx, false)
+			assert.NoError(t, err)
+			sealed <- blk
+		}()
+		fc.BlockUntil(1)
+		fc.Advance(consensus.InstantSealBlockTime)
+
+		blk := <-sealed
+		require.NotNil(t, blk)
+		assert.Equal(t, uint64(11), blk.Height)
+		assert.Equal(t, uint64(fc.Now().Unix()), blk.Timestamp)
+	})
+
+	t.Run("seals a block every interval", func(t *testing.T) {
+		height := chainClock.EpochAtTime(fc.Now()).AsBigInt().Uint64()
+		head = newBase(height)
+		params := newParams(message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil))
+		params.Interval = 5 * consensus.InstantSealBlockTime
+		published := make(chan *block.Block, 1)
+		params.Publish = func(_ context.Context, blk *block.Block) error {
+			published <- blk
+			return nil
+		}
+
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go mining.NewInstantSealer(params).Run(runCtx)
+
+		fc.BlockUntil(1)
+		fc.Advance(params.Interval)
+		blk := <-published
+		assert.Equal(t, height+5, blk.Height)
+		assert.Equal(t, head.Key(), blk.Parents)
+	})
+}
+
+func requireCid(t *testing.T, msg *types.SignedMessage) cid.Cid {
+	c, err := msg.Cid()
+	require.NoError(t, err)
+	return c
+}
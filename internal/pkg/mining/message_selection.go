@@ -0,0 +1,186 @@
+package mining
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+// actorState provides the state of the actors sending messages.
+type actorState interface {
+	GetActor(ctx context.Context, addr address.Address) (*actor.Actor, error)
+}
+
+// messageValidator validates a message against the state of its sender.
+type messageValidator interface {
+	Validate(ctx context.Context, msg *types.UnsignedMessage, fromActor *actor.Actor) error
+}
+
+// SelectMessages chooses the messages from `candidates` to include in a block using at most
+// `gasLimit` gas, so as to maximize the fees paid to the miner. The messages are returned in
+// an order they can be applied in: messages from a sender in increasing nonce order.
+//
+// The messages of each sender form a chain starting at the sender's nonce in `st`. A chain is
+// cut at the first message that fails validation against the sender's state after the messages
+// before it, accounting for the value and maximum gas charge they spend, since no message after
+// it can be applied.
+//
+// The fee of a message is its gas price times its gas limit. Each chain is divided into chunks
+// of consecutive messages that are worth including together: a message paying a low gas price
+// joins the messages after it when they pay more on average, since they cannot be included
+// without it. Chunks are then packed greedily in order of decreasing fee per unit of gas. When a
+// chunk does not fit, the rest of its chain is skipped and the remaining gas is filled with as
+// many of its messages as fit, in the same order.
+func SelectMessages(ctx context.Context, candidates []*types.SignedMessage, st actorState, validator messageValidator, gasLimit types.GasUnits) ([]*types.SignedMessage, error) {
+	bySender := make(map[address.Address][]*types.SignedMessage)
+	for _, msg := range candidates {
+		bySender[msg.Message.From] = append(bySender[msg.Message.From], msg)
+	}
+
+	var chunks []*messageChunk
+	for sender, msgs := range bySender {
+		chain, err := validChain(ctx, sender, msgs, st, validator)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunkChain(chain)...)
+	}
+	sort.Slice(chunks, func(i, j int) bool {
+		if cmp := chunks[i].compareDensity(chunks[j]); cmp != 0 {
+			return cmp > 0
+		}
+		if chunks[i].sender != chunks[j].sender {
+			return bytes.Compare(chunks[i].sender.Bytes(), chunks[j].sender.Bytes()) < 0
+		}
+		return chunks[i].msgs[0].Message.CallSeqNum < chunks[j].msgs[0].Message.CallSeqNum
+	})
+
+	var selected []*types.SignedMessage
+	remaining := gasLimit
+	// The chunks left out for lack of gas, at most one per sender, in order of decreasing density.
+	var misfits []*messageChunk
+	skipped := make(map[address.Address]bool)
+	for _, chunk := range chunks {
+		if skipped[chunk.sender] {
+			continue
+		}
+		if chunk.gas > remaining {
+			skipped[chunk.sender] = true
+			misfits = append(misfits, chunk)
+			continue
+		}
+		selected = append(selected, chunk.msgs...)
+		remaining -= chunk.gas
+	}
+
+	for _, chunk := range misfits {
+		for _, msg := range chunk.msgs {
+			if msg.Message.GasLimit > remaining {
+				break
+			}
+			selected = append(selected, msg)
+			remaining -= msg.Message.GasLimit
+		}
+	}
+	return selected, nil
+}
+
+// validChain returns the longest chain of messages from `msgs` that can be applied in nonce
+// order on the state of `sender`.
+func validChain(ctx context.Context, sender address.Address, msgs []*types.SignedMessage, st actorState, validator messageValidator) ([]*types.SignedMessage, error) {
+	fromActor, err := st.GetActor(ctx, sender)
+	if state.IsActorNotFoundError(err) {
+		fromActor = &actor.Actor{Balance: types.ZeroAttoFIL}
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to get actor %s", sender)
+	}
+	// The state of the actor after applying the messages selected so far.
+	simulated := *fromActor
+
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].Message.CallSeqNum < msgs[j].Message.CallSeqNum })
+	// Messages with nonces already used are stale, not blocking those after them.
+	for len(msgs) > 0 && msgs[0].Message.CallSeqNum < fromActor.CallSeqNum {
+		msgs = msgs[1:]
+	}
+	for i, msg := range msgs {
+		if err := validator.Validate(ctx, &msg.Message, &simulated); err != nil {
+			log.Debugf("not selecting message from %s with nonce %d and later: %s", sender, msg.Message.CallSeqNum, err)
+			return msgs[:i], nil
+		}
+		simulated.CallSeqNum++
+		simulated.Balance = simulated.Balance.Sub(msg.Message.Value).Sub(messageFee(msg))
+	}
+	return msgs, nil
+}
+
+// messageChunk is a run of consecutive messages from a sender that are selected together.
+type messageChunk struct {
+	sender address.Address
+	msgs   []*types.SignedMessage
+	gas    types.GasUnits
+	fee    types.AttoFIL
+}
+
+// chunkChain divides a chain of messages from a sender into chunks of non-increasing density.
+func chunkChain(chain []*types.SignedMessage) []*messageChunk {
+	var chunks []*messageChunk
+	for _, msg := range chain {
+		chunk := &messageChunk{
+			sender: msg.Message.From,
+			msgs:   []*types.SignedMessage{msg},
+			gas:    msg.Message.GasLimit,
+			fee:    messageFee(msg),
+		}
+		// A chunk paying less per unit of gas than the one after it is only worth including
+		// for the sake of the latter.
+		for len(chunks) > 0 && chunks[len(chunks)-1].compareDensity(chunk) < 0 {
+			last := chunks[len(chunks)-1]
+			chunks = chunks[:len(chunks)-1]
+			chunk = &messageChunk{
+				sender: last.sender,
+				msgs:   append(last.msgs, chunk.msgs...),
+				gas:    last.gas + chunk.gas,
+				fee:    last.fee.Add(chunk.fee),
+			}
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// compareDensity compares the fees per unit of gas of two chunks, returning -1, 0 or 1 if the
+// density of `c` is lower than, equal to or higher than that of `other`.
+func (c *messageChunk) compareDensity(other *messageChunk) int {
+	// A chunk using no gas takes no room in the block, which beats any fee.
+	switch {
+	case c.gas == 0 && other.gas == 0:
+		return 0
+	case c.gas == 0:
+		return 1
+	case other.gas == 0:
+		return -1
+	}
+	// c.fee / c.gas <=> other.fee / other.gas
+	left := c.fee.MulBigInt(new(big.Int).SetUint64(uint64(other.gas)))
+	right := other.fee.MulBigInt(new(big.Int).SetUint64(uint64(c.gas)))
+	if left.LessThan(right) {
+		return -1
+	}
+	if left.GreaterThan(right) {
+		return 1
+	}
+	return 0
+}
+
+// messageFee returns the most a message can pay the miner: its gas price times its gas limit.
+func messageFee(msg *types.SignedMessage) types.AttoFIL {
+	return msg.Message.GasPrice.MulBigInt(new(big.Int).SetUint64(uint64(msg.Message.GasLimit)))
+}
@@ -0,0 +1,106 @@
+package mining_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/mining"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+type fakeActorState map[address.Address]*actor.Actor
+
+func (s fakeActorState) GetActor(ctx context.Context, addr address.Address) (*actor.Actor, error) {
+	act, ok := s[addr]
+	if !ok {
+		return nil, actorNotFoundError{}
+	}
+	return act, nil
+}
+
+type actorNotFoundError struct{}
+
+func (actorNotFoundError) Error() string {
+	return "actor not found"
+}
+
+func (actorNotFoundError) ActorNotFound() bool {
+	return true
+}
+
+func TestSelectMessages(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer, _ := types.NewMockSignersAndKeyInfo(4)
+	alice, bob, carol, dave := signer.Addresses[0], signer.Addresses[1], signer.Addresses[2], signer.Addresses[3]
+	to := vmaddr.NewForTestGetter()()
+	newMsg := func(from address.Address, nonce uint64, price int64, limit uint64) *types.SignedMessage {
+		msg := types.NewMeteredMessage(from, to, nonce, types.ZeroAttoFIL, types.SendMethodID, nil, types.NewGasPrice(price), types.NewGasUnits(limit))
+		smsg, err := types.NewSignedMessage(*msg, signer)
+		require.NoError(t, err)
+		return smsg
+	}
+	newState := func() fakeActorState {
+		return fakeActorState{
+			alice: actor.NewActor(types.AccountActorCodeCid, types.NewAttoFILFromFIL(1)),
+			bob:   actor.NewActor(types.AccountActorCodeCid, types.NewAttoFILFromFIL(1)),
+			carol: actor.NewActor(types.AccountActorCodeCid, types.NewAttoFILFromFIL(1)),
+		}
+	}
+	validator := consensus.NewDefaultMessageValidator()
+
+	t.Run("prefers chains by their combined fees", func(t *testing.T) {
+		// Alice's cheap message unlocks an expensive one, worth more than Bob's together.
+		alice0, alice1 := newMsg(alice, 0, 1, 100), newMsg(alice, 1, 10, 100)
+		bob0 := newMsg(bob, 0, 4, 100)
+		candidates := []*types.SignedMessage{bob0, alice1, alice0}
+
+		selected, err := mining.SelectMessages(ctx, candidates, newState(), validator, types.NewGasUnits(200))
+		require.NoError(t, err)
+		assert.Equal(t, []*types.SignedMessage{alice0, alice1}, selected)
+
+		selected, err = mining.SelectMessages(ctx, candidates, newState(), validator, types.NewGasUnits(100))
+		require.NoError(t, err)
+		assert.Equal(t, []*types.SignedMessage{bob0}, selected)
+
+		selected, err = mining.SelectMessages(ctx, candidates, newState(), validator, types.NewGasUnits(300))
+		require.NoError(t, err)
+		assert.Equal(t, []*types.SignedMessage{alice0, alice1, bob0}, selected)
+	})
+
+	t.Run("fills the remaining gas with the start of a chain that does not fit", func(t *testing.T) {
+		alice0, alice1 := newMsg(alice, 0, 1, 50), newMsg(alice, 1, 10, 100)
+		bob0 := newMsg(bob, 0, 4, 60)
+
+		selected, err := mining.SelectMessages(ctx, []*types.SignedMessage{alice0, alice1, bob0}, newState(), validator, types.NewGasUnits(120))
+		require.NoError(t, err)
+		assert.Equal(t, []*types.SignedMessage{bob0, alice0}, selected)
+	})
+
+	t.Run("skips messages that cannot be applied", func(t *testing.T) {
+		st := newState()
+		st[bob].CallSeqNum = 1
+		// Alice cannot cover the gas of her third message after the first two.
+		alice0, alice1, alice2 := newMsg(alice, 0, 1, 100), newMsg(alice, 1, 1, 100), newMsg(alice, 2, 1e16, 100)
+		// Bob's first message is stale and his fourth leaves a gap.
+		bob0, bob1, bob3 := newMsg(bob, 0, 1, 100), newMsg(bob, 1, 1, 100), newMsg(bob, 3, 1, 100)
+		// Carol's message is invalid and blocks the one after it.
+		carol0, carol1 := newMsg(carol, 0, 0, 100), newMsg(carol, 1, 1, 100)
+		// Dave has no actor.
+		dave0 := newMsg(dave, 0, 1, 100)
+
+		candidates := []*types.SignedMessage{alice0, alice1, alice2, bob0, bob1, bob3, carol0, carol1, dave0}
+		selected, err := mining.SelectMessages(ctx, candidates, st, validator, types.BlockGasLimit)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []*types.SignedMessage{alice0, alice1, bob1}, selected)
+	})
+}
@@ -279,7 +279,7 @@ func sharedSetupInitial() (cbor.IpldStore, *message.Pool, cid.Cid) {
 	r := repo.NewInMemoryRepo()
 	bs := blockstore.NewBlockstore(r.Datastore())
 	cst := cborutil.NewIpldStore(bs)
-	pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+	pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator(), nil)
 	// Install the fake actor so we can execute it.
 	fakeActorCodeCid := types.AccountActorCodeCid
 	return cst, pool, fakeActorCodeCid
@@ -0,0 +1,157 @@
+package net
+
+import (
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+)
+
+// peerBlacklister drops a peer and ignores it from then on.
+type peerBlacklister interface {
+	BlacklistPeer(p peer.ID)
+}
+
+// PeerLimiter rate limits the messages relayed by each peer and drops peers
+// relaying too many invalid ones.
+//
+// The rate limit is a token bucket per peer, holding up to `burst` tokens and
+// refilled at `rate` tokens per second. A peer's invalid count is increased by
+// each invalid message and decreased by each valid one; the peer is dropped
+// when it reaches `maxInvalid`. A peer with a full bucket and no invalid
+// messages is not distinguishable from a new one, so its entry is evicted.
+//
+// PeerLimiter is safe for concurrent access.
+type PeerLimiter struct {
+	rate       float64
+	burst      float64
+	maxInvalid uint
+
+	blacklister peerBlacklister
+	clock       clock.Clock
+
+	lk    sync.Mutex
+	peers map[peer.ID]*peerLimit
+	// sweepAt is the number of peers at which idle ones are next evicted.
+	sweepAt int
+}
+
+type peerLimit struct {
+	tokens  float64
+	updated time.Time
+	invalid uint
+}
+
+// minPeerLimiterSweep is the least number of peers at which idle ones are
+// evicted.
+const minPeerLimiterSweep = 64
+
+// NewPeerLimiter creates a limiter dropping peers with `blacklister`. A zero
+// `rate` disables rate limiting and a zero `maxInvalid` never drops peers.
+func NewPeerLimiter(rate, burst, maxInvalid uint, blacklister peerBlacklister, clk clock.Clock) *PeerLimiter {
+	return &PeerLimiter{
+		rate:        float64(rate),
+		burst:       float64(burst),
+		maxInvalid:  maxInvalid,
+		blacklister: blacklister,
+		clock:       clk,
+		peers:       make(map[peer.ID]*peerLimit),
+		sweepAt:     minPeerLimiterSweep,
+	}
+}
+
+// Allow returns true if a message relayed by `p` is within its rate limit,
+// consuming a token if so.
+func (l *PeerLimiter) Allow(p peer.ID) bool {
+	if l.rate == 0 {
+		return true
+	}
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	now := l.clock.Now()
+	limit := l.peer(p, now)
+	limit.tokens += now.Sub(limit.updated).Seconds() * l.rate
+	if limit.tokens > l.burst {
+		limit.tokens = l.burst
+	}
+	limit.updated = now
+	if limit.tokens < 1 {
+		return false
+	}
+	limit.tokens--
+	return true
+}
+
+// ReportValid records that `p` relayed a valid message.
+func (l *PeerLimiter) ReportValid(p peer.ID) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	if limit, ok := l.peers[p]; ok && limit.invalid > 0 {
+		limit.invalid--
+		if l.idle(limit, l.clock.Now()) {
+			delete(l.peers, p)
+		}
+	}
+}
+
+// ReportInvalid records that `p` relayed an invalid message and drops it if
+// it has relayed too many. Returns true if the peer was dropped.
+func (l *PeerLimiter) ReportInvalid(p peer.ID) bool {
+	if l.maxInvalid == 0 {
+		return false
+	}
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	limit := l.peer(p, l.clock.Now())
+	limit.invalid++
+	if limit.invalid < l.maxInvalid {
+		return false
+	}
+	delete(l.peers, p)
+	l.blacklister.BlacklistPeer(p)
+	return true
+}
+
+// peer returns the limit of `p`, creating it with a full bucket at `now`.
+// Idle peers are evicted whenever the number of peers doubles, so that the
+// cost of eviction is amortized over the peers added.
+// The caller must hold the lock.
+func (l *PeerLimiter) peer(p peer.ID, now time.Time) *peerLimit {
+	limit, ok := l.peers[p]
+	if !ok {
+		if len(l.peers) >= l.sweepAt {
+			for other, otherLimit := range l.peers {
+				if l.idle(otherLimit, now) {
+					delete(l.peers, other)
+				}
+			}
+			l.sweepAt = 2 * len(l.peers)
+			if l.sweepAt < minPeerLimiterSweep {
+				l.sweepAt = minPeerLimiterSweep
+			}
+		}
+		limit = &peerLimit{tokens: l.burst, updated: now}
+		l.peers[p] = limit
+	}
+	return limit
+}
+
+// idle returns true if `limit` has no invalid messages and its bucket is
+// full at `now`, as for a new peer.
+func (l *PeerLimiter) idle(limit *peerLimit, now time.Time) bool {
+	if limit.invalid > 0 {
+		return false
+	}
+	return l.rate == 0 || limit.tokens+now.Sub(limit.updated).Seconds()*l.rate >= l.burst
+}
+
+// Len returns the number of peers tracked by the limiter.
+func (l *PeerLimiter) Len() int {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	return len(l.peers)
+}
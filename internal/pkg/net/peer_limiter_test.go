@@ -0,0 +1,94 @@
+package net_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/net"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+type fakeBlacklister struct {
+	blacklisted []peer.ID
+}
+
+func (b *fakeBlacklister) BlacklistPeer(p peer.ID) {
+	b.blacklisted = append(b.blacklisted, p)
+}
+
+func TestPeerLimiter(t *testing.T) {
+	tf.UnitTest(t)
+
+	pid1 := th.RequireIntPeerID(t, 1)
+	pid2 := th.RequireIntPeerID(t, 2)
+
+	t.Run("rate limits each peer", func(t *testing.T) {
+		clk := th.NewFakeClock(time.Unix(1234567890, 0))
+		limiter := net.NewPeerLimiter(2, 3, 0, &fakeBlacklister{}, clk)
+
+		for i := 0; i < 3; i++ {
+			assert.True(t, limiter.Allow(pid1))
+		}
+		assert.False(t, limiter.Allow(pid1))
+		assert.True(t, limiter.Allow(pid2))
+
+		clk.Advance(time.Second)
+		assert.True(t, limiter.Allow(pid1))
+		assert.True(t, limiter.Allow(pid1))
+		assert.False(t, limiter.Allow(pid1))
+
+		// The bucket refills up to its burst.
+		clk.Advance(time.Hour)
+		for i := 0; i < 3; i++ {
+			assert.True(t, limiter.Allow(pid1))
+		}
+		assert.False(t, limiter.Allow(pid1))
+	})
+
+	t.Run("zero rate does not limit", func(t *testing.T) {
+		limiter := net.NewPeerLimiter(0, 0, 0, &fakeBlacklister{}, th.NewFakeClock(time.Unix(1234567890, 0)))
+		for i := 0; i < 100; i++ {
+			assert.True(t, limiter.Allow(pid1))
+		}
+	})
+
+	t.Run("drops peers relaying invalid messages", func(t *testing.T) {
+		blacklister := &fakeBlacklister{}
+		limiter := net.NewPeerLimiter(0, 0, 3, blacklister, th.NewFakeClock(time.Unix(1234567890, 0)))
+
+		assert.False(t, limiter.ReportInvalid(pid1))
+		assert.False(t, limiter.ReportInvalid(pid1))
+		// Valid messages offset invalid ones.
+		limiter.ReportValid(pid1)
+		assert.False(t, limiter.ReportInvalid(pid1))
+		assert.False(t, limiter.ReportInvalid(pid2))
+		assert.Empty(t, blacklister.blacklisted)
+
+		assert.True(t, limiter.ReportInvalid(pid1))
+		assert.Equal(t, []peer.ID{pid1}, blacklister.blacklisted)
+	})
+
+	t.Run("evicts idle peers", func(t *testing.T) {
+		clk := th.NewFakeClock(time.Unix(1234567890, 0))
+		limiter := net.NewPeerLimiter(1, 2, 3, &fakeBlacklister{}, clk)
+
+		assert.True(t, limiter.Allow(pid1))
+		assert.False(t, limiter.ReportInvalid(pid2))
+		// Idle peers are first evicted when a peer is added to 64 others.
+		for i := 3; i <= 64; i++ {
+			assert.True(t, limiter.Allow(th.RequireIntPeerID(t, int64(i))))
+		}
+		clk.Advance(time.Hour)
+		assert.True(t, limiter.Allow(th.RequireIntPeerID(t, 65)))
+		// Only the peer with an invalid message and the new one are kept.
+		assert.Equal(t, 2, limiter.Len())
+
+		// A peer is evicted once its invalid messages are offset.
+		limiter.ReportValid(pid2)
+		assert.Equal(t, 1, limiter.Len())
+	})
+}
@@ -20,6 +20,9 @@ var mDecodeBlkFail = metrics.NewInt64Counter("net/pubsub_block_decode_failure",
 var mInvalidBlk = metrics.NewInt64Counter("net/pubsub_invalid_block", "Number of blocks that fail syntax validation seen on BlockTopic pubsub channel")
 var mDecodeMsgFail = metrics.NewInt64Counter("net/pubsub_message_decode_failure", "Number of messages that fail to decode seen on MessageTopic pubsub channel")
 var mInvalidMsg = metrics.NewInt64Counter("net/pubsub_invalid_message", "Number of messages that fail syntax validation seen on MessageTopic pubsub channel")
+var mRateLimitedMsg = metrics.NewInt64Counter("net/pubsub_rate_limited_message", "Number of messages dropped on MessageTopic pubsub channel because their peer exceeded its rate limit")
+var mQuotaMsg = metrics.NewInt64Counter("net/pubsub_sender_quota_message", "Number of messages dropped on MessageTopic pubsub channel because their sender reached its quota of pending messages")
+var mDroppedPeer = metrics.NewInt64Counter("net/pubsub_dropped_peer", "Number of peers dropped for relaying too many invalid messages on MessageTopic pubsub channel")
 
 // BlockTopicValidator may be registered on go-libp2p-pubsub to validate pubsub messages on the
 // BlockTopic.
@@ -72,24 +75,63 @@ type MessageTopicValidator struct {
 	opts      []pubsub.ValidatorOpt
 }
 
+// messageValidator validates messages seen on the MessageTopic, e.g. a
+// consensus.IngestionValidator.
+type messageValidator interface {
+	Validate(ctx context.Context, msg *types.SignedMessage) error
+}
+
+// senderQuota limits the pending messages of each sender, e.g. a message.Pool.
+type senderQuota interface {
+	SenderQuotaReached(msg *types.SignedMessage) bool
+}
+
 // NewMessageTopicValidator returns a MessageTopicValidator using `mv` for
-// message validation
-func NewMessageTopicValidator(mv *consensus.IngestionValidator, opts ...pubsub.ValidatorOpt) *MessageTopicValidator {
+// message validation. Messages relayed by a peer beyond its rate limit in
+// `limiter`, or from a sender that reached its `quota` of pending messages
+// without replacing one, are ignored. Peers relaying invalid messages are
+// reported to `limiter`. Messages published by the node itself, validated as
+// from peer `self`, are neither rate limited nor reported.
+func NewMessageTopicValidator(self peer.ID, mv messageValidator, quota senderQuota, limiter *PeerLimiter, opts ...pubsub.ValidatorOpt) *MessageTopicValidator {
 	return &MessageTopicValidator{
 		opts: opts,
 		validator: func(ctx context.Context, p peer.ID, msg *pubsub.Message) bool {
+			local := p == self
+			if !local && !limiter.Allow(p) {
+				messageTopicLogger.Debugf("message from peer: %s exceeds its rate limit", p.String())
+				mRateLimitedMsg.Inc(ctx, 1)
+				return false
+			}
+			invalid := func() {
+				if !local && limiter.ReportInvalid(p) {
+					messageTopicLogger.Infof("dropping peer: %s for relaying too many invalid messages", p.String())
+					mDroppedPeer.Inc(ctx, 1)
+				}
+			}
+
 			unmarshaled := &types.SignedMessage{}
 			if err := unmarshaled.Unmarshal(msg.GetData()); err != nil {
 				messageTopicLogger.Debugf("message from peer: %s failed to decode: %s", p.String(), err.Error())
 				mDecodeMsgFail.Inc(ctx, 1)
+				invalid()
+				return false
+			}
+			// A sender at its quota may be honest, so the peer relaying its message is not penalized.
+			if quota.SenderQuotaReached(unmarshaled) {
+				messageTopicLogger.Debugf("message from peer: %s has sender %s at its quota", p.String(), unmarshaled.Message.From)
+				mQuotaMsg.Inc(ctx, 1)
 				return false
 			}
 			if err := mv.Validate(ctx, unmarshaled); err != nil {
 				mCid, _ := unmarshaled.Cid()
 				messageTopicLogger.Debugf("message %s from peer: %s failed to validate: %s", mCid.String(), p.String(), err.Error())
 				mInvalidMsg.Inc(ctx, 1)
+				invalid()
 				return false
 			}
+			if !local {
+				limiter.ReportValid(p)
+			}
 			return true
 		},
 	}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/libp2p/go-libp2p-core/peer"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
 	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
@@ -50,6 +51,99 @@ func TestBlockTopicValidator(t *testing.T) {
 	assert.False(t, validator(ctx, pid1, nonBlkPubSubMsg()))
 }
 
+type fakeMessageValidator struct {
+	invalid map[address.Address]bool
+}
+
+func (v *fakeMessageValidator) Validate(_ context.Context, msg *types.SignedMessage) error {
+	if v.invalid[msg.Message.From] {
+		return fmt.Errorf("invalid message")
+	}
+	return nil
+}
+
+// fakeSenderQuota has reached the quota of the senders it maps to the nonces
+// of their pending messages, which may be replaced.
+type fakeSenderQuota map[address.Address]uint64
+
+func (q fakeSenderQuota) SenderQuotaReached(msg *types.SignedMessage) bool {
+	pending, ok := q[msg.Message.From]
+	return ok && uint64(msg.Message.CallSeqNum) > pending
+}
+
+func TestMessageTopicValidator(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	keys := types.MustGenerateKeyInfo(3, 42)
+	mm := types.NewMessageMaker(t, keys)
+	alice, bob, carol := mm.Addresses()[0], mm.Addresses()[1], mm.Addresses()[2]
+	pid1 := th.RequireIntPeerID(t, 1)
+	pid2 := th.RequireIntPeerID(t, 2)
+	self := th.RequireIntPeerID(t, 3)
+
+	blacklister := &fakeBlacklister{}
+	limiter := net.NewPeerLimiter(1, 3, 2, blacklister, th.NewFakeClock(time.Unix(1234567890, 0)))
+	mv := &fakeMessageValidator{invalid: map[address.Address]bool{bob: true}}
+	tv := net.NewMessageTopicValidator(self, mv, fakeSenderQuota{carol: 0}, limiter)
+	validator := tv.Validator()
+
+	network := "go-filecoin-test"
+	assert.Equal(t, net.MessageTopic(network), tv.Topic(network))
+	assert.True(t, validator(ctx, pid1, msgToPubSub(t, mm.NewSignedMessage(alice, 0))))
+	// A sender at its quota does not count against the peer.
+	assert.False(t, validator(ctx, pid1, msgToPubSub(t, mm.NewSignedMessage(carol, 1))))
+	assert.False(t, validator(ctx, pid1, msgToPubSub(t, mm.NewSignedMessage(bob, 0))))
+	assert.Empty(t, blacklister.blacklisted)
+
+	// The peer exceeds its rate limit.
+	assert.False(t, validator(ctx, pid1, msgToPubSub(t, mm.NewSignedMessage(alice, 1))))
+
+	assert.False(t, validator(ctx, pid2, nonBlkPubSubMsg()))
+	assert.False(t, validator(ctx, pid2, msgToPubSub(t, mm.NewSignedMessage(bob, 1))))
+	assert.Equal(t, []peer.ID{pid2}, blacklister.blacklisted)
+
+	// A sender at its quota may still replace a pending message.
+	limiter = net.NewPeerLimiter(0, 0, 2, blacklister, th.NewFakeClock(time.Unix(1234567890, 0)))
+	validator = net.NewMessageTopicValidator(self, mv, fakeSenderQuota{carol: 0}, limiter).Validator()
+	assert.True(t, validator(ctx, pid1, msgToPubSub(t, mm.NewSignedMessage(carol, 0))))
+}
+
+func TestMessageTopicValidatorLocalPublish(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	keys := types.MustGenerateKeyInfo(2, 42)
+	mm := types.NewMessageMaker(t, keys)
+	alice, bob := mm.Addresses()[0], mm.Addresses()[1]
+	self := th.RequireIntPeerID(t, 1)
+
+	// The limiter allows a single message and drops a peer at its first invalid message.
+	blacklister := &fakeBlacklister{}
+	limiter := net.NewPeerLimiter(1, 1, 1, blacklister, th.NewFakeClock(time.Unix(1234567890, 0)))
+	mv := &fakeMessageValidator{invalid: map[address.Address]bool{bob: true}}
+	validator := net.NewMessageTopicValidator(self, mv, fakeSenderQuota{}, limiter).Validator()
+
+	// Messages the node publishes itself are not rate limited,
+	for i := 0; i < 3; i++ {
+		assert.True(t, validator(ctx, self, msgToPubSub(t, mm.NewSignedMessage(alice, uint64(i)))))
+	}
+	// and invalid ones do not count against the node.
+	assert.False(t, validator(ctx, self, msgToPubSub(t, mm.NewSignedMessage(bob, 0))))
+	assert.False(t, validator(ctx, self, nonBlkPubSubMsg()))
+	assert.Empty(t, blacklister.blacklisted)
+}
+
+func msgToPubSub(t *testing.T, msg *types.SignedMessage) *pubsub.Message {
+	data, err := msg.Marshal()
+	require.NoError(t, err)
+	return &pubsub.Message{
+		Message: &pubsub_pb.Message{
+			Data: data,
+		},
+	}
+}
+
 func TestBlockPubSubValidation(t *testing.T) {
 	tf.IntegrationTest(t)
 	ctx := context.Background()
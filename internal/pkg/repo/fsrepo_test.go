@@ -57,7 +57,14 @@ const (
 	},
 	"mpool": {
 		"maxPoolSize": 10000,
-		"maxNonceGap": "100"
+		"maxNonceGap": "100",
+		"replaceByFeePercent": 25,
+		"maxPendingPerSender": 1000,
+		"peerMessageRate": 100,
+		"peerMessageBurst": 1000,
+		"maxPeerInvalidMessages": 100,
+		"rebroadcastInterval": "1m",
+		"maxRebroadcasts": 5
 	},
 	"observability": {
 		"metrics": {
@@ -74,6 +81,14 @@ const (
 	"sectorbase": {
 		"rootdir": ""
 	},
+	"slashing": {
+		"reporterAddress": "\u003cempty\u003e"
+	},
+	"stategc": {
+		"autoPrune": false,
+		"keepEpochs": 1000,
+		"period": "1h"
+	},
 	"swarm": {
 		"address": "/ip4/0.0.0.0/tcp/6000"
 	},
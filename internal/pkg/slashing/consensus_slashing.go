@@ -3,29 +3,71 @@ package slashing
 import (
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin/power"
 )
 
 // ConsensusFaultDetector detects consensus faults -- misbehavior conditions where a single
-// party produces multiple blocks at the same time.
+// party produces multiple blocks at the same time, produces blocks with the same parents at
+// different epochs, or abandons its own block for a sibling of it.
 type ConsensusFaultDetector struct {
-	// minerIndex tracks witnessed blocks by miner address and epoch
+	// minerIndex tracks witnessed blocks by miner address and the epoch they were mined at
 	minerIndex map[address.Address]map[uint64]*block.Block
+	// parentIndex tracks witnessed blocks by miner address and parent tipset key
+	parentIndex map[address.Address]map[string]*block.Block
+	// highest is the highest epoch witnessed, epochs more than a finality window behind it
+	// are pruned from the index
+	highest uint64
 	// sender sends messages on behalf of the slasher
 	faultCh chan ConsensusFault
 }
 
+// FaultType is the kind of misbehavior of a consensus fault.
+type FaultType uint64
+
+const (
+	// DoubleForkMining is the signing of two blocks at the same epoch.
+	DoubleForkMining FaultType = power.DoubleForkMiningFault
+	// ParentGrinding is the mining of a block on parents excluding the miner's own block
+	// at the parents' epoch, in favor of a sibling of that block.
+	ParentGrinding FaultType = power.ParentGrindingFault
+	// TimeOffsetMining is the signing of two blocks with the same parents at different
+	// epochs.
+	TimeOffsetMining FaultType = power.TimeOffsetMiningFault
+)
+
+func (t FaultType) String() string {
+	switch t {
+	case DoubleForkMining:
+		return "double-fork"
+	case ParentGrinding:
+		return "parent-grinding"
+	case TimeOffsetMining:
+		return "time-offset"
+	default:
+		return "unknown"
+	}
+}
+
 // ConsensusFault is the information needed to submit a consensus fault
 type ConsensusFault struct {
-	// Block1 and Block2 are two distinct blocks from an overlapping interval
-	// signed by the same miner
+	// Type is the kind of fault.
+	Type FaultType
+	// Block1 and Block2 are two distinct blocks signed by the same miner. For a
+	// parent-grinding fault Block1 is the block the miner abandoned and Block2 the block
+	// mined without it.
 	Block1, Block2 *block.Block
+	// Extra is the sibling of Block1 among the parents of Block2 for a parent-grinding
+	// fault, nil otherwise.
+	Extra *block.Block
 }
 
 // NewConsensusFaultDetector returns a fault detector given a fault channel
 func NewConsensusFaultDetector(faultCh chan ConsensusFault) *ConsensusFaultDetector {
 	return &ConsensusFaultDetector{
-		minerIndex: make(map[address.Address]map[uint64]*block.Block),
-		faultCh:    faultCh,
+		minerIndex:  make(map[address.Address]map[uint64]*block.Block),
+		parentIndex: make(map[address.Address]map[string]*block.Block),
+		faultCh:     faultCh,
 	}
 
 }
@@ -33,12 +75,10 @@ func NewConsensusFaultDetector(faultCh chan ConsensusFault) *ConsensusFaultDetec
 // CheckBlock records a new block and checks for faults
 // Preconditions: the signature is already checked and p is the parent
 func (detector *ConsensusFaultDetector) CheckBlock(b *block.Block, p block.TipSet) error {
-	latest := b.Height
 	parentHeight, err := p.Height()
 	if err != nil {
 		return err
 	}
-	earliest := parentHeight + 1
 
 	// Find per-miner index
 	blockByEpoch, tracked := detector.minerIndex[b.Miner]
@@ -47,19 +87,84 @@ func (detector *ConsensusFaultDetector) CheckBlock(b *block.Block, p block.TipSe
 		detector.minerIndex[b.Miner] = blockByEpoch
 	}
 
-	// Add this epoch to the miner's index, emitting any detected faults
-	for e := earliest; e <= latest; e++ {
-		collision, tracked := blockByEpoch[e]
-		if tracked {
-			// Exact duplicates are not faults
-			if collision.Cid().Equals(b.Cid()) {
-				continue
+	// Check whether the miner left out its own block at the parent epoch for a sibling
+	if own, tracked := blockByEpoch[parentHeight]; tracked && !p.Key().Has(own.Cid()) {
+		for i := 0; i < p.Len(); i++ {
+			if sibling := p.At(i); sibling.Parents.Equals(own.Parents) {
+				detector.faultCh <- ConsensusFault{Type: ParentGrinding, Block1: own, Block2: b, Extra: sibling}
+				break
 			}
-			// Emit all faults, any special handling of duplicates belongs downstream
-			detector.faultCh <- ConsensusFault{b, collision}
 		}
-		// In case of collision overwrite with most recent
-		blockByEpoch[e] = b
+	}
+
+	// Check whether the miner already mined on the same parents at another epoch. Blocks
+	// without parents are only found in tests, since genesis is not mined.
+	if !b.Parents.Empty() {
+		blockByParents, tracked := detector.parentIndex[b.Miner]
+		if !tracked {
+			blockByParents = make(map[string]*block.Block)
+			detector.parentIndex[b.Miner] = blockByParents
+		}
+		parentsKey := b.Parents.String()
+		if other, tracked := blockByParents[parentsKey]; tracked && other.Height != b.Height {
+			detector.faultCh <- ConsensusFault{Type: TimeOffsetMining, Block1: b, Block2: other}
+		}
+		blockByParents[parentsKey] = b
+	}
+
+	// Check whether the miner already mined another block at the same epoch. Blocks at
+	// different epochs are never a double-fork fault, even across null rounds.
+	if collision, tracked := blockByEpoch[b.Height]; tracked && !collision.Cid().Equals(b.Cid()) {
+		detector.faultCh <- ConsensusFault{Type: DoubleForkMining, Block1: b, Block2: collision}
+	}
+	// In case of collision overwrite with most recent
+	blockByEpoch[b.Height] = b
+
+	if b.Height > detector.highest {
+		detector.highest = b.Height
+		detector.prune()
 	}
 	return nil
 }
+
+// prune drops the epochs more than a finality window behind the highest epoch witnessed.
+// Blocks that old cannot be part of a chain the node accepts any more.
+func (detector *ConsensusFaultDetector) prune() {
+	if detector.highest <= consensus.FinalityEpochs {
+		return
+	}
+	cutoff := detector.highest - consensus.FinalityEpochs
+	for miner, blockByEpoch := range detector.minerIndex {
+		for e := range blockByEpoch {
+			if e < cutoff {
+				delete(blockByEpoch, e)
+			}
+		}
+		if len(blockByEpoch) == 0 {
+			delete(detector.minerIndex, miner)
+		}
+	}
+	for miner, blockByParents := range detector.parentIndex {
+		for key, b := range blockByParents {
+			if b.Height < cutoff {
+				delete(blockByParents, key)
+			}
+		}
+		if len(blockByParents) == 0 {
+			delete(detector.parentIndex, miner)
+		}
+	}
+}
+
+// IndexSize returns the number of epochs and parent sets tracked in the index across all
+// miners.
+func (detector *ConsensusFaultDetector) IndexSize() int {
+	size := 0
+	for _, blockByEpoch := range detector.minerIndex {
+		size += len(blockByEpoch)
+	}
+	for _, blockByParents := range detector.parentIndex {
+		size += len(blockByParents)
+	}
+	return size
+}
@@ -3,9 +3,11 @@ package slashing_test
 import (
 	"testing"
 
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
 	. "github.com/filecoin-project/go-filecoin/internal/pkg/slashing"
 	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
@@ -116,23 +118,157 @@ func TestFaultNullBlocks(t *testing.T) {
 	addrGetter := vmaddr.NewForTestGetter()
 	minerAddr1 := addrGetter()
 
-	t.Run("same base", func(t *testing.T) {
+	t.Run("same base at different epochs doesn't slash", func(t *testing.T) {
 		parentBlock := &block.Block{Height: 42}
 		parentTipSet := th.RequireNewTipSet(t, parentBlock)
 
+		// The null rounds before block2 span the epoch of block1
 		block1 := &block.Block{Miner: minerAddr1, Height: 45}
 		block2 := &block.Block{Miner: minerAddr1, Height: 49}
 
+		faultCh := make(chan ConsensusFault, 1)
+		cfd := NewConsensusFaultDetector(faultCh)
+		assert.NoError(t, cfd.CheckBlock(block1, parentTipSet))
+		assertEmptyCh(t, faultCh)
+		assert.NoError(t, cfd.CheckBlock(block2, parentTipSet))
+		assertEmptyCh(t, faultCh)
+	})
+
+	t.Run("same epoch after null rounds", func(t *testing.T) {
+		parent1TipSet := th.RequireNewTipSet(t, &block.Block{Height: 42})
+		parent2TipSet := th.RequireNewTipSet(t, &block.Block{Height: 44})
+
+		block1 := &block.Block{Miner: minerAddr1, Height: 46}
+		block2 := &block.Block{Miner: minerAddr1, Height: 46, StateRoot: e.NewCid(types.CidFromString(t, "some-other-state"))}
+
+		faultCh := make(chan ConsensusFault, 1)
+		cfd := NewConsensusFaultDetector(faultCh)
+		assert.NoError(t, cfd.CheckBlock(block1, parent1TipSet))
+		assertEmptyCh(t, faultCh)
+		assert.NoError(t, cfd.CheckBlock(block2, parent2TipSet))
+		fault := <-faultCh
+		assert.Equal(t, DoubleForkMining, fault.Type)
+		assert.Equal(t, block2, fault.Block1)
+		assert.Equal(t, block1, fault.Block2)
+	})
+}
+
+func TestPruneIndex(t *testing.T) {
+	addrGetter := vmaddr.NewForTestGetter()
+	minerAddr1 := addrGetter()
+	minerAddr2 := addrGetter()
+
+	faultCh := make(chan ConsensusFault, 1)
+	cfd := NewConsensusFaultDetector(faultCh)
+
+	parent1TipSet := th.RequireNewTipSet(t, &block.Block{Height: 42})
+	block1 := &block.Block{Miner: minerAddr1, Height: 43}
+	assert.NoError(t, cfd.CheckBlock(block1, parent1TipSet))
+	assert.Equal(t, 1, cfd.IndexSize())
+
+	// Blocks a finality window above drop the old epochs from the index
+	parent2TipSet := th.RequireNewTipSet(t, &block.Block{Height: 43 + consensus.FinalityEpochs})
+	block2 := &block.Block{Miner: minerAddr2, Height: 44 + consensus.FinalityEpochs}
+	assert.NoError(t, cfd.CheckBlock(block2, parent2TipSet))
+	assert.Equal(t, 1, cfd.IndexSize())
+
+	// so a conflicting block that old is not a fault any more
+	block3 := &block.Block{Miner: minerAddr1, Height: 43, StateRoot: e.NewCid(types.CidFromString(t, "some-other-state"))}
+	assert.NoError(t, cfd.CheckBlock(block3, parent1TipSet))
+	assertEmptyCh(t, faultCh)
+}
+
+func TestTimeOffsetFault(t *testing.T) {
+	addrGetter := vmaddr.NewForTestGetter()
+	minerAddr1 := addrGetter()
+	minerAddr2 := addrGetter()
+
+	grandparentTipSet := th.RequireNewTipSet(t, &block.Block{Height: 40})
+	parentTipSet := th.RequireNewTipSet(t, &block.Block{Height: 42, Parents: grandparentTipSet.Key()})
+
+	t.Run("same parents at different epochs", func(t *testing.T) {
+		block1 := &block.Block{Miner: minerAddr1, Height: 43, Parents: parentTipSet.Key()}
+		block2 := &block.Block{Miner: minerAddr1, Height: 45, Parents: parentTipSet.Key()}
+
 		faultCh := make(chan ConsensusFault, 3)
 		cfd := NewConsensusFaultDetector(faultCh)
 		assert.NoError(t, cfd.CheckBlock(block1, parentTipSet))
 		assertEmptyCh(t, faultCh)
 		assert.NoError(t, cfd.CheckBlock(block2, parentTipSet))
-		for i := 0; i < 3; i++ {
-			fault := <-faultCh
-			assert.Equal(t, fault.Block1, block2)
-			assert.Equal(t, fault.Block2, block1)
-		}
+
+		// Blocks at different epochs are not also reported as a double-fork fault
+		fault := <-faultCh
+		assert.Equal(t, TimeOffsetMining, fault.Type)
+		assert.Equal(t, block2, fault.Block1)
+		assert.Equal(t, block1, fault.Block2)
+		assert.Nil(t, fault.Extra)
+		assertEmptyCh(t, faultCh)
+	})
+
+	t.Run("same parents at the same epoch", func(t *testing.T) {
+		block1 := &block.Block{Miner: minerAddr1, Height: 43, Parents: parentTipSet.Key()}
+		block2 := &block.Block{Miner: minerAddr1, Height: 43, Parents: parentTipSet.Key(), StateRoot: e.NewCid(types.CidFromString(t, "some-other-state"))}
+
+		faultCh := make(chan ConsensusFault, 3)
+		cfd := NewConsensusFaultDetector(faultCh)
+		assert.NoError(t, cfd.CheckBlock(block1, parentTipSet))
+		assert.NoError(t, cfd.CheckBlock(block2, parentTipSet))
+
+		fault := <-faultCh
+		assert.Equal(t, DoubleForkMining, fault.Type)
+		assert.Equal(t, block2, fault.Block1)
+		assert.Equal(t, block1, fault.Block2)
+		assertEmptyCh(t, faultCh)
+	})
+
+	t.Run("same parents from different miners don't slash", func(t *testing.T) {
+		block1 := &block.Block{Miner: minerAddr1, Height: 43, Parents: parentTipSet.Key()}
+		block2 := &block.Block{Miner: minerAddr2, Height: 45, Parents: parentTipSet.Key()}
+
+		faultCh := make(chan ConsensusFault, 1)
+		cfd := NewConsensusFaultDetector(faultCh)
+		assert.NoError(t, cfd.CheckBlock(block1, parentTipSet))
+		assert.NoError(t, cfd.CheckBlock(block2, parentTipSet))
+		assertEmptyCh(t, faultCh)
 	})
+}
+
+func TestParentGrindingFault(t *testing.T) {
+	addrGetter := vmaddr.NewForTestGetter()
+	minerAddr1 := addrGetter()
+	minerAddr2 := addrGetter()
+
+	grandparentTipSet := th.RequireNewTipSet(t, &block.Block{Height: 42})
+	own := &block.Block{Miner: minerAddr1, Height: 43, Parents: grandparentTipSet.Key(), ParentWeight: fbig.Zero()}
+	sibling := &block.Block{Miner: minerAddr2, Height: 43, Parents: grandparentTipSet.Key(), ParentWeight: fbig.Zero()}
+
+	t.Run("mining on a sibling of an own block", func(t *testing.T) {
+		faultCh := make(chan ConsensusFault, 1)
+		cfd := NewConsensusFaultDetector(faultCh)
+		assert.NoError(t, cfd.CheckBlock(own, grandparentTipSet))
+		assert.NoError(t, cfd.CheckBlock(sibling, grandparentTipSet))
+		assertEmptyCh(t, faultCh)
+
+		siblingTipSet := th.RequireNewTipSet(t, sibling)
+		next := &block.Block{Miner: minerAddr1, Height: 44, Parents: siblingTipSet.Key()}
+		assert.NoError(t, cfd.CheckBlock(next, siblingTipSet))
 
+		fault := <-faultCh
+		assert.Equal(t, ParentGrinding, fault.Type)
+		assert.Equal(t, own, fault.Block1)
+		assert.Equal(t, next, fault.Block2)
+		assert.Equal(t, sibling, fault.Extra)
+	})
+
+	t.Run("mining on parents including an own block doesn't slash", func(t *testing.T) {
+		faultCh := make(chan ConsensusFault, 1)
+		cfd := NewConsensusFaultDetector(faultCh)
+		assert.NoError(t, cfd.CheckBlock(own, grandparentTipSet))
+		assert.NoError(t, cfd.CheckBlock(sibling, grandparentTipSet))
+
+		parentTipSet := th.RequireNewTipSet(t, own, sibling)
+		next := &block.Block{Miner: minerAddr1, Height: 44, Parents: parentTipSet.Key()}
+		assert.NoError(t, cfd.CheckBlock(next, parentTipSet))
+		assertEmptyCh(t, faultCh)
+	})
 }
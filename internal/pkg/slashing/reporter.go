@@ -0,0 +1,384 @@
+package slashing
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin/power"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+var log = logging.Logger("slashing")
+
+func init() {
+	encoding.RegisterIpldCborType(FaultReport{})
+}
+
+// reportDatastorePrefix is the datastore namespace under which a reporter keeps its reports by
+// miner, fault type and blocks.
+var reportDatastorePrefix = datastore.NewKey("/slashing/faults")
+
+// submitRetryInterval is how often a reporter checks the receipts of the messages reporting
+// faults and submits again the reports that failed.
+const submitRetryInterval = time.Minute
+
+// reportInclusionTimeout is how long a reporter waits for a message reporting a fault to be
+// included in the chain before sending another.
+const reportInclusionTimeout = 10 * time.Minute
+
+// maxReportAttempts is the number of messages a reporter sends for a fault before giving up.
+const maxReportAttempts = 3
+
+var (
+	faultsDetectedCt  = metrics.NewInt64Counter("slashing/faults_detected", "The number of distinct consensus faults detected")
+	faultsSubmittedCt = metrics.NewInt64Counter("slashing/faults_submitted", "The number of consensus fault reports sent to the power actor")
+)
+
+// FaultReport is a consensus fault detected by the node and the progress of its report.
+type FaultReport struct {
+	// Type is the kind of fault.
+	Type FaultType
+	// Miner is the miner that signed both blocks.
+	Miner address.Address
+	// Block1, Block2 and Extra are the evidence of the fault, as in ConsensusFault.
+	Block1, Block2, Extra *block.Block
+	// Detected is the time the fault was detected, in seconds since the Unix epoch.
+	Detected uint64
+	// Message is the CID of the last message reporting the fault, undefined until one is sent.
+	Message e.Cid
+	// Sent is the time Message was sent, in seconds since the Unix epoch.
+	Sent uint64
+	// Attempts is the number of messages sent reporting the fault.
+	Attempts uint64
+	// Included is true once Message is included in the chain.
+	Included bool
+	// ExitCode is the exit code of the receipt of Message once it is included.
+	ExitCode uint8
+	// Abandoned is true once the reporter gave up on the fault, because the power actor
+	// rejected it or the last message reporting it failed or was not included in time.
+	Abandoned bool
+}
+
+// Submitted returns true once a message reporting the fault has been included in the chain
+// and accepted by the power actor.
+func (fr *FaultReport) Submitted() bool {
+	return fr.Included && fr.ExitCode == 0
+}
+
+// Failed returns true if the reporter gave up on the fault without submitting it.
+func (fr *FaultReport) Failed() bool {
+	return fr.Abandoned
+}
+
+// faultSender sends the messages reporting faults.
+type faultSender interface {
+	SendBatch(ctx context.Context, from address.Address, msgs []*message.BatchMessage,
+		gasPrice types.AttoFIL, gasLimit types.GasUnits, bcast bool) ([]cid.Cid, chan error, error)
+}
+
+// receiptFinder finds where messages were included in the chain and their receipts.
+type receiptFinder interface {
+	Lookup(msgCid cid.Cid) (*chain.MessageInclusion, bool, error)
+}
+
+// gasPricer estimates the gas price for a message to be included in the chain soon.
+type gasPricer interface {
+	EstimateGasPrice(ctx context.Context) (types.AttoFIL, error)
+}
+
+// Reporter reports the consensus faults detected by a ConsensusFaultDetector to the power
+// actor, in messages sent from a wallet address. Each fault is reported once however many
+// times it is detected, but a report is sent again if its message is not included in the
+// chain in time or fails without the power actor rejecting it. Reports are kept in a
+// datastore so that faults detected before a restart are still reported. Without a sending
+// address faults are only recorded.
+// Reporter is safe for concurrent access.
+type Reporter struct {
+	lk sync.Mutex
+	// reports keyed by their datastore key
+	reports map[string]*FaultReport
+
+	ds       datastore.Datastore
+	sender   faultSender
+	receipts receiptFinder
+	pricer   gasPricer
+	from     address.Address
+	clock    clock.Clock
+}
+
+// NewReporter creates a reporter sending reports from `from` and keeping them in `ds`. The
+// reports are priced by `pricer` and their receipts found by `receipts`.
+func NewReporter(ds datastore.Datastore, sender faultSender, receipts receiptFinder, pricer gasPricer, from address.Address, clk clock.Clock) *Reporter {
+	return &Reporter{
+		reports:  make(map[string]*FaultReport),
+		ds:       ds,
+		sender:   sender,
+		receipts: receipts,
+		pricer:   pricer,
+		from:     from,
+		clock:    clk,
+	}
+}
+
+// reportKey returns the datastore key of the report of a fault, the same whichever order
+// the fault's blocks are in.
+func reportKey(fault ConsensusFault) datastore.Key {
+	c1, c2 := fault.Block1.Cid().String(), fault.Block2.Cid().String()
+	if c2 < c1 {
+		c1, c2 = c2, c1
+	}
+	return reportDatastorePrefix.ChildString(fault.Block1.Miner.String()).ChildString(fault.Type.String()).ChildString(c1 + "-" + c2)
+}
+
+// Load restores the reports persisted by a previous reporter on the same datastore.
+func (r *Reporter) Load(ctx context.Context) error {
+	res, err := r.ds.Query(query.Query{Prefix: reportDatastorePrefix.String() + "/"})
+	if err != nil {
+		return errors.Wrap(err, "failed to query persisted fault reports")
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return errors.Wrap(err, "failed to read persisted fault reports")
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	for _, entry := range entries {
+		report := &FaultReport{}
+		if err := encoding.Decode(entry.Value, report); err != nil {
+			return errors.Wrapf(err, "failed to decode persisted fault report %s", entry.Key)
+		}
+		r.reports[entry.Key] = report
+	}
+	return nil
+}
+
+// Run reports the faults received from `faults` until the context is done, and periodically
+// checks the receipts of the reports and submits again those that failed.
+func (r *Reporter) Run(ctx context.Context, faults <-chan ConsensusFault) {
+	r.SubmitPending(ctx)
+
+	ticker := r.clock.NewTicker(submitRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case fault := <-faults:
+			if _, err := r.Report(ctx, fault); err != nil {
+				log.Warnf("failed to report consensus fault by miner %s: %s", fault.Block1.Miner, err)
+			}
+		case <-ticker.Chan():
+			r.SubmitPending(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Report records a fault and sends a report of it. It returns false without sending anything
+// if the fault was already recorded.
+func (r *Reporter) Report(ctx context.Context, fault ConsensusFault) (bool, error) {
+	key := reportKey(fault).String()
+
+	r.lk.Lock()
+	if _, found := r.reports[key]; found {
+		r.lk.Unlock()
+		return false, nil
+	}
+	report := &FaultReport{
+		Type:     fault.Type,
+		Miner:    fault.Block1.Miner,
+		Block1:   fault.Block1,
+		Block2:   fault.Block2,
+		Extra:    fault.Extra,
+		Detected: uint64(r.clock.Now().Unix()),
+	}
+	r.reports[key] = report
+	err := r.persist(key, report)
+	r.lk.Unlock()
+
+	faultsDetectedCt.Inc(ctx, 1)
+	log.Warnf("detected %s consensus fault by miner %s: blocks %s and %s", report.Type, report.Miner, report.Block1.Cid(), report.Block2.Cid())
+	if err != nil {
+		return true, err
+	}
+	return true, r.submit(ctx, key, report)
+}
+
+// SubmitPending records the receipts of the messages reporting faults and sends the reports
+// that have not been sent yet, whose message failed, or whose message was not included in the
+// chain in time. Returns the number of reports sent.
+func (r *Reporter) SubmitPending(ctx context.Context) int {
+	if r.from.Empty() {
+		return 0
+	}
+
+	r.lk.Lock()
+	pending := make(map[string]*FaultReport)
+	for key, report := range r.reports {
+		if !report.Submitted() && !report.Failed() {
+			pending[key] = report
+		}
+	}
+	r.lk.Unlock()
+
+	submitted := 0
+	for key, report := range pending {
+		resend, err := r.checkReceipt(key, report)
+		if err != nil {
+			log.Warnf("failed to check report of consensus fault by miner %s: %s", report.Miner, err)
+			continue
+		}
+		if !resend {
+			continue
+		}
+		if err := r.submit(ctx, key, report); err != nil {
+			log.Warnf("failed to report consensus fault by miner %s: %s", report.Miner, err)
+			continue
+		}
+		submitted++
+	}
+	return submitted
+}
+
+// checkReceipt records the receipt of the last message reporting a fault if it is included in
+// the chain. It returns true if another message should be sent.
+func (r *Reporter) checkReceipt(key string, report *FaultReport) (bool, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if !report.Message.Defined() {
+		return true, nil
+	}
+	if !report.Included {
+		inclusion, found, err := r.receipts.Lookup(report.Message.Cid)
+		if err != nil {
+			return false, err
+		}
+		if !found {
+			// The message may still be pending, or have been dropped.
+			if r.clock.Since(time.Unix(int64(report.Sent), 0)) < reportInclusionTimeout {
+				return false, nil
+			}
+			if report.Attempts < maxReportAttempts {
+				return true, nil
+			}
+			report.Abandoned = true
+			log.Warnf("report of %s consensus fault by miner %s failed, message %s was not included in time",
+				report.Type, report.Miner, report.Message)
+			return false, r.persist(key, report)
+		}
+		report.Included = true
+		report.ExitCode = inclusion.Receipt.ExitCode
+		if !report.Submitted() {
+			// The power actor rejects a report the same way however many times it is sent.
+			_, rejected := power.Errors[report.ExitCode]
+			report.Abandoned = rejected || report.Attempts >= maxReportAttempts
+			log.Warnf("report of %s consensus fault by miner %s failed with exit code %d in message %s",
+				report.Type, report.Miner, report.ExitCode, report.Message)
+		}
+		if err := r.persist(key, report); err != nil {
+			return false, err
+		}
+	}
+	return !report.Submitted() && !report.Failed(), nil
+}
+
+// Reports returns the recorded reports, oldest first.
+func (r *Reporter) Reports() []*FaultReport {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	reports := make([]*FaultReport, 0, len(r.reports))
+	for _, report := range r.reports {
+		copied := *report
+		reports = append(reports, &copied)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Detected != reports[j].Detected {
+			return reports[i].Detected < reports[j].Detected
+		}
+		return reports[i].Block1.Height < reports[j].Block1.Height
+	})
+	return reports
+}
+
+// submit sends a message reporting a fault to the power actor and records it.
+func (r *Reporter) submit(ctx context.Context, key string, report *FaultReport) error {
+	if r.from.Empty() {
+		return nil
+	}
+
+	params := power.ReportConsensusFaultParams{FaultType: uint64(report.Type)}
+	var err error
+	if params.Header1, err = encoding.Encode(report.Block1); err != nil {
+		return errors.Wrap(err, "failed to encode block header")
+	}
+	if params.Header2, err = encoding.Encode(report.Block2); err != nil {
+		return errors.Wrap(err, "failed to encode block header")
+	}
+	if report.Extra != nil {
+		if params.HeaderExtra, err = encoding.Encode(report.Extra); err != nil {
+			return errors.Wrap(err, "failed to encode block header")
+		}
+	}
+	encodedParams, err := encoding.Encode(params)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode fault report params")
+	}
+
+	gasPrice, err := r.pricer.EstimateGasPrice(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to estimate gas price of fault report")
+	}
+	// The headers make up most of the message.
+	gasLimit := power.ReportConsensusFaultGas(uint32(len(encodedParams)))
+
+	msgCids, _, err := r.sender.SendBatch(ctx, r.from, []*message.BatchMessage{{
+		To:     vmaddr.StoragePowerAddress,
+		Value:  types.ZeroAttoFIL,
+		Method: power.ReportConsensusFault,
+		Params: encodedParams,
+	}}, gasPrice, gasLimit, true)
+	if err != nil {
+		return errors.Wrapf(err, "failed to send fault report from %s", r.from)
+	}
+	faultsSubmittedCt.Inc(ctx, 1)
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	report.Message = e.NewCid(msgCids[0])
+	report.Sent = uint64(r.clock.Now().Unix())
+	report.Attempts++
+	report.Included = false
+	report.ExitCode = 0
+	return r.persist(key, report)
+}
+
+// persist writes a report to the datastore. The caller must hold the lock.
+func (r *Reporter) persist(key string, report *FaultReport) error {
+	bs, err := encoding.Encode(report)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode fault report")
+	}
+	if err := r.ds.Put(datastore.NewKey(key), bs); err != nil {
+		return errors.Wrap(err, "failed to persist fault report")
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package slashing_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
+	. "github.com/filecoin-project/go-filecoin/internal/pkg/slashing"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin/power"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+// outOfGas is the exit code of a message that ran out of gas, a failure that does not
+// depend on the report.
+const outOfGas = 6
+
+type sentReport struct {
+	from     address.Address
+	msg      *message.BatchMessage
+	gasPrice types.AttoFIL
+	gasLimit types.GasUnits
+}
+
+// fakeFaultSender records the messages sent, failing while err is set.
+type fakeFaultSender struct {
+	cidGetter func() cid.Cid
+	sent      []sentReport
+	cids      []cid.Cid
+	err       error
+}
+
+func (s *fakeFaultSender) SendBatch(ctx context.Context, from address.Address, msgs []*message.BatchMessage,
+	gasPrice types.AttoFIL, gasLimit types.GasUnits, bcast bool) ([]cid.Cid, chan error, error) {
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+	var out []cid.Cid
+	for _, msg := range msgs {
+		s.sent = append(s.sent, sentReport{from, msg, gasPrice, gasLimit})
+		out = append(out, s.cidGetter())
+	}
+	s.cids = append(s.cids, out...)
+	return out, nil, nil
+}
+
+// fakeReceipts finds the receipts of the messages given an exit code.
+type fakeReceipts map[cid.Cid]uint8
+
+func (r fakeReceipts) Lookup(msgCid cid.Cid) (*chain.MessageInclusion, bool, error) {
+	exitCode, found := r[msgCid]
+	if !found {
+		return nil, false, nil
+	}
+	return &chain.MessageInclusion{Receipt: types.MessageReceipt{ExitCode: exitCode}}, true, nil
+}
+
+type fakeGasPricer struct {
+	price types.AttoFIL
+}
+
+func (p *fakeGasPricer) EstimateGasPrice(ctx context.Context) (types.AttoFIL, error) {
+	return p.price, nil
+}
+
+func TestReporter(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	addrGetter := vmaddr.NewForTestGetter()
+	minerAddr := addrGetter()
+	reporterAddr := addrGetter()
+	fc := th.NewFakeClock(time.Unix(1234567890, 0))
+	pricer := &fakeGasPricer{price: types.NewGasPrice(7)}
+
+	block1 := &block.Block{Miner: minerAddr, Height: 43, StateRoot: e.NewCid(types.CidFromString(t, "some-state"))}
+	block2 := &block.Block{Miner: minerAddr, Height: 43, StateRoot: e.NewCid(types.CidFromString(t, "some-other-state"))}
+
+	t.Run("reports a fault once", func(t *testing.T) {
+		sender := &fakeFaultSender{cidGetter: types.NewCidForTestGetter()}
+		receipts := fakeReceipts{}
+		reporter := NewReporter(datastore.NewMapDatastore(), sender, receipts, pricer, reporterAddr, fc)
+
+		reported, err := reporter.Report(ctx, ConsensusFault{Type: DoubleForkMining, Block1: block2, Block2: block1})
+		require.NoError(t, err)
+		assert.True(t, reported)
+		reported, err = reporter.Report(ctx, ConsensusFault{Type: DoubleForkMining, Block1: block1, Block2: block2})
+		require.NoError(t, err)
+		assert.False(t, reported)
+
+		require.Len(t, sender.sent, 1)
+		sent := sender.sent[0]
+		assert.Equal(t, reporterAddr, sent.from)
+		assert.Equal(t, vmaddr.StoragePowerAddress, sent.msg.To)
+		assert.Equal(t, power.ReportConsensusFault, sent.msg.Method)
+		assert.Equal(t, pricer.price, sent.gasPrice)
+		assert.Equal(t, power.ReportConsensusFaultGas(uint32(len(sent.msg.Params))), sent.gasLimit)
+		var params power.ReportConsensusFaultParams
+		require.NoError(t, encoding.Decode(sent.msg.Params, &params))
+		assert.Equal(t, uint64(DoubleForkMining), params.FaultType)
+		header1, err := block.DecodeBlock(params.Header1)
+		require.NoError(t, err)
+		assert.Equal(t, block2.Cid(), header1.Cid())
+		assert.Empty(t, params.HeaderExtra)
+
+		reports := reporter.Reports()
+		require.Len(t, reports, 1)
+		assert.Equal(t, minerAddr, reports[0].Miner)
+		assert.Equal(t, DoubleForkMining, reports[0].Type)
+		assert.Equal(t, uint64(fc.Now().Unix()), reports[0].Detected)
+		assert.Equal(t, sender.cids[0], reports[0].Message.Cid)
+		assert.False(t, reports[0].Submitted())
+
+		// The report is submitted once its message succeeds.
+		assert.Equal(t, 0, reporter.SubmitPending(ctx))
+		receipts[sender.cids[0]] = 0
+		assert.Equal(t, 0, reporter.SubmitPending(ctx))
+		assert.True(t, reporter.Reports()[0].Submitted())
+		assert.Len(t, sender.sent, 1)
+	})
+
+	t.Run("only records faults without a reporter address", func(t *testing.T) {
+		sender := &fakeFaultSender{cidGetter: types.NewCidForTestGetter()}
+		reporter := NewReporter(datastore.NewMapDatastore(), sender, fakeReceipts{}, pricer, address.Undef, fc)
+
+		reported, err := reporter.Report(ctx, ConsensusFault{Type: DoubleForkMining, Block1: block1, Block2: block2})
+		require.NoError(t, err)
+		assert.True(t, reported)
+		assert.Equal(t, 0, reporter.SubmitPending(ctx))
+
+		assert.Empty(t, sender.sent)
+		reports := reporter.Reports()
+		require.Len(t, reports, 1)
+		assert.False(t, reports[0].Submitted())
+	})
+
+	t.Run("submits persisted reports after a restart", func(t *testing.T) {
+		ds := datastore.NewMapDatastore()
+		sender := &fakeFaultSender{cidGetter: types.NewCidForTestGetter(), err: errors.New("no actor")}
+		receipts := fakeReceipts{}
+		reporter := NewReporter(ds, sender, receipts, pricer, reporterAddr, fc)
+
+		reported, err := reporter.Report(ctx, ConsensusFault{Type: DoubleForkMining, Block1: block1, Block2: block2})
+		assert.Error(t, err)
+		assert.True(t, reported)
+
+		sender.err = nil
+		restarted := NewReporter(ds, sender, receipts, pricer, reporterAddr, fc)
+		require.NoError(t, restarted.Load(ctx))
+		reports := restarted.Reports()
+		require.Len(t, reports, 1)
+		assert.False(t, reports[0].Submitted())
+		assert.Equal(t, block1.Cid(), reports[0].Block1.Cid())
+
+		assert.Equal(t, 1, restarted.SubmitPending(ctx))
+		assert.Equal(t, 0, restarted.SubmitPending(ctx))
+		receipts[sender.cids[0]] = 0
+		assert.Equal(t, 0, restarted.SubmitPending(ctx))
+		assert.True(t, restarted.Reports()[0].Submitted())
+
+		reported, err = restarted.Report(ctx, ConsensusFault{Type: DoubleForkMining, Block1: block2, Block2: block1})
+		require.NoError(t, err)
+		assert.False(t, reported)
+		assert.Len(t, sender.sent, 1)
+	})
+
+	t.Run("retries reports that fail or are not included", func(t *testing.T) {
+		fc := th.NewFakeClock(time.Unix(1234567890, 0))
+		sender := &fakeFaultSender{cidGetter: types.NewCidForTestGetter()}
+		receipts := fakeReceipts{}
+		reporter := NewReporter(datastore.NewMapDatastore(), sender, receipts, pricer, reporterAddr, fc)
+
+		_, err := reporter.Report(ctx, ConsensusFault{Type: DoubleForkMining, Block1: block1, Block2: block2})
+		require.NoError(t, err)
+
+		// A failed message is sent again.
+		receipts[sender.cids[0]] = outOfGas
+		assert.Equal(t, 1, reporter.SubmitPending(ctx))
+		report := reporter.Reports()[0]
+		assert.Equal(t, sender.cids[1], report.Message.Cid)
+		assert.Equal(t, uint64(2), report.Attempts)
+		assert.False(t, report.Submitted())
+
+		// A message not included in time is sent again.
+		fc.Advance(time.Minute)
+		assert.Equal(t, 0, reporter.SubmitPending(ctx))
+		fc.Advance(10 * time.Minute)
+		assert.Equal(t, 1, reporter.SubmitPending(ctx))
+
+		// The reporter gives up after the last attempt fails.
+		receipts[sender.cids[2]] = outOfGas
+		assert.Equal(t, 0, reporter.SubmitPending(ctx))
+		report = reporter.Reports()[0]
+		assert.Equal(t, uint64(3), report.Attempts)
+		assert.Equal(t, uint8(outOfGas), report.ExitCode)
+		assert.True(t, report.Failed())
+		assert.False(t, report.Submitted())
+		assert.Len(t, sender.sent, 3)
+	})
+
+	t.Run("gives up on reports the power actor rejects", func(t *testing.T) {
+		sender := &fakeFaultSender{cidGetter: types.NewCidForTestGetter()}
+		receipts := fakeReceipts{}
+		reporter := NewReporter(datastore.NewMapDatastore(), sender, receipts, pricer, reporterAddr, fc)
+
+		_, err := reporter.Report(ctx, ConsensusFault{Type: DoubleForkMining, Block1: block1, Block2: block2})
+		require.NoError(t, err)
+
+		receipts[sender.cids[0]] = power.ErrInvalidConsensusFault
+		assert.Equal(t, 0, reporter.SubmitPending(ctx))
+		report := reporter.Reports()[0]
+		assert.Equal(t, uint64(1), report.Attempts)
+		assert.Equal(t, uint8(power.ErrInvalidConsensusFault), report.ExitCode)
+		assert.True(t, report.Failed())
+		assert.Len(t, sender.sent, 1)
+	})
+
+	t.Run("gives up when the last attempt is not included", func(t *testing.T) {
+		fc := th.NewFakeClock(time.Unix(1234567890, 0))
+		sender := &fakeFaultSender{cidGetter: types.NewCidForTestGetter()}
+		reporter := NewReporter(datastore.NewMapDatastore(), sender, fakeReceipts{}, pricer, reporterAddr, fc)
+
+		_, err := reporter.Report(ctx, ConsensusFault{Type: DoubleForkMining, Block1: block1, Block2: block2})
+		require.NoError(t, err)
+		for i := 1; i < 3; i++ {
+			fc.Advance(11 * time.Minute)
+			assert.Equal(t, 1, reporter.SubmitPending(ctx))
+		}
+
+		fc.Advance(11 * time.Minute)
+		assert.Equal(t, 0, reporter.SubmitPending(ctx))
+		report := reporter.Reports()[0]
+		assert.Equal(t, uint64(3), report.Attempts)
+		assert.False(t, report.Included)
+		assert.True(t, report.Failed())
+		assert.Len(t, sender.sent, 3)
+
+		// A report given up on is not sent again.
+		fc.Advance(11 * time.Minute)
+		assert.Equal(t, 0, reporter.SubmitPending(ctx))
+		assert.Len(t, sender.sent, 3)
+	})
+}
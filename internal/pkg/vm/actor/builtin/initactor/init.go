@@ -30,6 +30,14 @@ type State struct {
 	AddressMap cid.Cid `refmt:",omitempty"`
 	IDMap      cid.Cid `refmt:",omitempty"`
 	NextID     types.Uint64
+
+	// Consensus names the consensus protocol of the network, empty for expected consensus.
+	Consensus string `refmt:",omitempty"`
+	// SealSigner is the address authorized to sign blocks under instant-seal consensus.
+	SealSigner []byte `refmt:",omitempty"`
+	// SealInterval is the number of seconds between blocks sealed under instant-seal
+	// consensus when no messages arrive, zero to seal only when messages arrive.
+	SealInterval types.Uint64 `refmt:",omitempty"`
 }
 
 // View is a readonly view into the actor state
@@ -75,28 +83,35 @@ func NewView(stateHandle runtime.ReadonlyActorStateHandle, store runtime.Storage
 //
 // If the lookup fails, this method will return false.
 func (v *View) GetIDAddressByAddress(target address.Address) (address.Address, bool) {
-	if target.Protocol() == address.ID {
-		return target, true
-	}
-	lookup, err := actor.LoadLookup(context.Background(), v.store, v.state.AddressMap)
+	idAddr, found, err := v.IDAddressByAddress(context.Background(), target)
 	if err != nil {
 		panic("could not load internal state")
 	}
+	return idAddr, found
+}
 
-	var id types.Uint64
-	err = lookup.Find(context.Background(), target.String(), &id)
+// IDAddressByAddress returns the IDAddress for the target address, or false if no actor
+// has that address.
+//
+// Unlike GetIDAddressByAddress it returns an error if the state cannot be read, for
+// callers outside of the VM.
+func (v *View) IDAddressByAddress(ctx context.Context, target address.Address) (address.Address, bool, error) {
+	if target.Protocol() == address.ID {
+		return target, true, nil
+	}
+	id, err := findActorID(ctx, v.store, v.state.AddressMap, target)
+	if err == hamt.ErrNotFound {
+		return address.Undef, false, nil
+	}
 	if err != nil {
-		if err == hamt.ErrNotFound {
-			return address.Undef, false
-		}
-		panic("could not load internal state")
+		return address.Undef, false, err
 	}
 
 	idAddr, err := address.NewIDAddress((uint64)(id))
 	if err != nil {
-		panic("could not load internal state")
+		return address.Undef, false, err
 	}
-	return idAddr, true
+	return idAddr, true, nil
 }
 
 //
@@ -111,6 +126,7 @@ func (a *Actor) Exports() []interface{} {
 	return []interface{}{
 		ExecMethodID:                 (*Impl)(a).Exec,
 		GetActorIDForAddressMethodID: (*Impl)(a).GetActorIDForAddress,
+		GetAddressForActorIDMethodID: (*Impl)(a).GetAddressForActorID,
 	}
 }
 
@@ -202,35 +218,37 @@ func (a *Impl) GetActorIDForAddress(ctx invocationContext, addr address.Address)
 }
 
 // GetAddressForActorID looks up the address for an actor id.
-func (a *Impl) GetAddressForActorID(vmctx runtime.InvocationContext, actorID types.Uint64) (address.Address, uint8, error) {
+func (a *Impl) GetAddressForActorID(vmctx runtime.InvocationContext, actorID types.Uint64) address.Address {
+	vmctx.ValidateCaller(pattern.Any{})
+
 	if err := vmctx.Charge(actor.DefaultGasCost); err != nil {
-		return address.Undef, internal.ErrInsufficientGas, fmt.Errorf("Insufficient gas")
+		runtime.Abort(exitcode.OutOfGas)
 	}
 
 	var state State
-	vmctx.StateHandle().Readonly(state)
+	vmctx.StateHandle().Readonly(&state)
 
 	ctx := context.TODO()
 	lookup, err := actor.LoadLookup(ctx, vmctx.Runtime().Storage(), state.IDMap)
 	if err != nil {
-		return address.Undef, 1, fmt.Errorf("could not load lookup for cid: %s", state.IDMap)
+		runtime.Abortf(exitcode.MethodAbort, "could not load lookup for cid: %s", state.IDMap)
 	}
 
 	key, err := keyForActorID(actorID)
 	if err != nil {
-		return address.Undef, 1, fmt.Errorf("could not encode actor id: %d", actorID)
+		runtime.Abortf(exitcode.MethodAbort, "could not encode actor id: %d", actorID)
 	}
 
 	var addr address.Address
 	err = lookup.Find(ctx, key, &addr)
 	if err != nil {
 		if err == hamt.ErrNotFound {
-			return address.Undef, ErrNotFound, fmt.Errorf("actor address not found for id: %d", actorID)
+			runtime.Abort(exitcode.ExitCode(ErrNotFound))
 		}
-		return address.Undef, 1, fmt.Errorf("could not lookup actor address")
+		runtime.Abortf(exitcode.MethodAbort, "could not lookup actor address")
 	}
 
-	return addr, 0, nil
+	return addr
 }
 
 // ExecParams are the params for the Exec method.
@@ -284,10 +302,14 @@ func (a *Impl) Exec(vmctx invocationContext, params ExecParams) address.Address
 }
 
 func lookupIDAddress(vmctx runtime.InvocationContext, state State, addr address.Address) (types.Uint64, error) {
-	ctx := context.TODO()
-	lookup, err := actor.LoadLookup(ctx, vmctx.Runtime().Storage(), state.AddressMap)
+	return findActorID(context.TODO(), vmctx.Runtime().Storage(), state.AddressMap, addr)
+}
+
+// findActorID returns the ID of the actor with address `addr` in the address map `addressMap`.
+func findActorID(ctx context.Context, storage runtime.Storage, addressMap cid.Cid, addr address.Address) (types.Uint64, error) {
+	lookup, err := actor.LoadLookup(ctx, storage, addressMap)
 	if err != nil {
-		return 0, fmt.Errorf("could not load lookup for cid: %s", state.IDMap)
+		return 0, fmt.Errorf("could not load lookup for cid: %s", addressMap)
 	}
 
 	var id types.Uint64
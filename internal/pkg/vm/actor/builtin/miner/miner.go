@@ -16,6 +16,7 @@ import (
 	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/dispatch"
 	internal "github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/errors"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/exitcode"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/pattern"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/runtime"
 )
@@ -208,6 +209,7 @@ var _ dispatch.Actor = (*Actor)(nil)
 func (a *Actor) Exports() []interface{} {
 	return []interface{}{
 		Constructor: (*Impl)(a).Constructor,
+		GetWorker:   (*Impl)(a).GetWorker,
 	}
 }
 
@@ -689,25 +691,16 @@ func (*Impl) ChangeWorker(ctx invocationContext, worker address.Address) (uint8,
 }
 
 // GetWorker returns the worker address for this miner.
-func (*Impl) GetWorker(ctx invocationContext) (address.Address, uint8, error) {
+func (*Impl) GetWorker(ctx runtime.InvocationContext) address.Address {
+	ctx.ValidateCaller(pattern.Any{})
+
 	if err := ctx.Charge(actor.DefaultGasCost); err != nil {
-		return address.Address{}, internal.ErrInsufficientGas, fmt.Errorf("Insufficient gas")
+		runtime.Abort(exitcode.OutOfGas)
 	}
 
 	var state State
-	out, err := ctx.StateHandle().Transaction(&state, func() (interface{}, error) {
-		return state.Worker, nil
-	})
-	if err != nil {
-		return address.Address{}, 1, err
-	}
-
-	validOut, ok := out.(address.Address)
-	if !ok {
-		return address.Address{}, 1, fmt.Errorf("expected an address")
-	}
-
-	return validOut, 0, nil
+	ctx.StateHandle().Readonly(&state)
+	return state.Worker
 }
 
 // GetPeerID returns the libp2p peer ID that this miner can be reached at.
@@ -12,6 +12,7 @@ import (
 	"github.com/ipfs/go-hamt-ipld"
 	"github.com/libp2p/go-libp2p-core/peer"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
@@ -19,6 +20,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/dispatch"
 	internal "github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/errors"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/exitcode"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/gascost"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/pattern"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/runtime"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/storage"
@@ -59,12 +61,26 @@ const (
 	GetPowerReport
 	ProcessFaultReport
 	GetSectorSize
-	// ReportConsensusFault
+	// ReportConsensusFault takes the encoded headers of two blocks signed by the same miner,
+	// the encoded header of a third block witnessing the fault or nothing, and the fault type.
+	ReportConsensusFault
 	// Surprise
 	// AddBalance ? (review: is this a runtime builtin?)
 	// WithdrawBalance ? (review: is this a runtime builtin?)
 )
 
+// Consensus fault types, as reported to ReportConsensusFault.
+const (
+	// DoubleForkMiningFault is the signing of two blocks at the same epoch.
+	DoubleForkMiningFault = iota + 1
+	// ParentGrindingFault is the mining of a block on parents excluding the miner's own
+	// block at the parents' epoch, in favor of a sibling of that block.
+	ParentGrindingFault
+	// TimeOffsetMiningFault is the signing of two blocks with the same parents at
+	// different epochs.
+	TimeOffsetMiningFault
+)
+
 // NewActor returns a new power actor
 func NewActor() *actor.Actor {
 	return actor.NewActor(types.PowerActorCodeCid, types.ZeroAttoFIL)
@@ -79,8 +95,9 @@ var _ dispatch.Actor = (*Actor)(nil)
 // Exports implements `dispatch.Actor`
 func (a *Actor) Exports() []interface{} {
 	return []interface{}{
-		CreateStorageMiner: (*impl)(a).createStorageMiner,
-		ProcessPowerReport: (*impl)(a).processPowerReport,
+		CreateStorageMiner:   (*impl)(a).createStorageMiner,
+		ProcessPowerReport:   (*impl)(a).processPowerReport,
+		ReportConsensusFault: (*impl)(a).reportConsensusFault,
 	}
 }
 
@@ -96,6 +113,12 @@ func (*Actor) InitializeState(handle runtime.ActorStateHandle, _ interface{}) er
 
 type impl Actor
 
+// invocationContext is the context for the power actor.
+type invocationContext interface {
+	runtime.InvocationContext
+	VerifySignature(signer address.Address, signature types.Signature, msg []byte) bool
+}
+
 const (
 	// ErrDeleteMinerWithPower signals that RemoveStorageMiner was called on an actor with nonzero power
 	ErrDeleteMinerWithPower = 100
@@ -103,13 +126,16 @@ const (
 	ErrUnknownEntry = 101
 	// ErrDuplicateEntry is returned when there is an attempt to create a new power table entry at an existing addrErr
 	ErrDuplicateEntry = 102
+	// ErrInvalidConsensusFault is returned when the blocks of a consensus fault report are not evidence of the fault
+	ErrInvalidConsensusFault = 103
 )
 
 // Errors map error codes to revert errors this actor may return.
 var Errors = map[uint8]error{
-	ErrDeleteMinerWithPower: fmt.Errorf("cannot delete miner with power from power table"),
-	ErrUnknownEntry:         fmt.Errorf("cannot find address in power table"),
-	ErrDuplicateEntry:       fmt.Errorf("duplicate create power table entry attempt"),
+	ErrDeleteMinerWithPower:  fmt.Errorf("cannot delete miner with power from power table"),
+	ErrUnknownEntry:          fmt.Errorf("cannot find address in power table"),
+	ErrDuplicateEntry:        fmt.Errorf("duplicate create power table entry attempt"),
+	ErrInvalidConsensusFault: fmt.Errorf("blocks are not evidence of the consensus fault"),
 }
 
 // CreateStorageMinerParams is the params for the CreateStorageMiner method.
@@ -367,3 +393,116 @@ func (*impl) processPowerReport(vmctx runtime.InvocationContext, params ProcessP
 		panic(err)
 	}
 }
+
+// ReportConsensusFaultParams is the params for the ReportConsensusFault method.
+type ReportConsensusFaultParams struct {
+	// Header1 and Header2 are the encoded headers of two distinct blocks signed by the
+	// same miner.
+	Header1, Header2 []byte
+	// HeaderExtra is the encoded header of the sibling of the first block among the
+	// parents of the second for a parent-grinding fault, empty otherwise.
+	HeaderExtra []byte
+	// FaultType is the kind of fault.
+	FaultType uint64
+}
+
+// ReportConsensusFaultGas returns the gas used by a message of `msgSize` bytes calling
+// ReportConsensusFault: the message and its receipt on chain, the method and the two calls
+// it makes to find the miner's worker key.
+func ReportConsensusFaultGas(msgSize uint32) types.GasUnits {
+	methods := 3 * (gascost.OnMethodInvocation(nil) + actor.DefaultGasCost)
+	return gascost.OnChainMessage(msgSize) + gascost.OnChainReturnValue(nil) + methods
+}
+
+// ReportConsensusFault removes all the power of the miner that signed the blocks of a
+// consensus fault.
+func (*impl) reportConsensusFault(vmctx invocationContext, params ReportConsensusFaultParams) {
+	vmctx.ValidateCaller(pattern.Any{})
+
+	if err := vmctx.Charge(actor.DefaultGasCost); err != nil {
+		runtime.Abort(exitcode.OutOfGas)
+	}
+
+	block1 := decodeFaultHeader(params.Header1)
+	block2 := decodeFaultHeader(params.Header2)
+	var extra *block.Block
+	if len(params.HeaderExtra) > 0 {
+		extra = decodeFaultHeader(params.HeaderExtra)
+	}
+	if !isConsensusFault(params.FaultType, block1, block2, extra) {
+		runtime.Abort(exitcode.ExitCode(ErrInvalidConsensusFault))
+	}
+
+	// Both blocks must be signed with the key of the miner's worker.
+	worker := vmctx.Send(block1.Miner, miner.GetWorker, types.ZeroAttoFIL, nil).(address.Address)
+	if worker.Protocol() == address.ID {
+		id, err := address.IDFromAddress(worker)
+		if err != nil {
+			panic(err)
+		}
+		worker = vmctx.Send(vmaddr.InitAddress, initactor.GetAddressForActorIDMethodID, types.ZeroAttoFIL, types.Uint64(id)).(address.Address)
+	}
+	for _, blk := range []*block.Block{block1, block2} {
+		if !vmctx.VerifySignature(worker, blk.BlockSig, blk.SignatureData()) {
+			runtime.Abort(exitcode.ExitCode(ErrInvalidConsensusFault))
+		}
+	}
+
+	var state State
+	_, err := vmctx.StateHandle().Transaction(&state, func() (interface{}, error) {
+		ctx := context.Background()
+		newPowerTable, err := actor.WithLookup(ctx, vmctx.Runtime().Storage(), state.PowerTable, func(lookup storage.Lookup) error {
+			var entry TableEntry
+			err := lookup.Find(ctx, block1.Miner.String(), &entry)
+			if err != nil {
+				if err == hamt.ErrNotFound {
+					return Errors[ErrUnknownEntry]
+				}
+				return fmt.Errorf("Could not retrieve power table entry with ID: %s", block1.Miner.String())
+			}
+			entry.ActivePower = types.NewBytesAmount(0)
+			entry.InactivePower = types.NewBytesAmount(0)
+			return lookup.Set(ctx, block1.Miner.String(), entry)
+		})
+		if err != nil {
+			return nil, err
+		}
+		state.PowerTable = newPowerTable
+		return nil, nil
+	})
+	if err == Errors[ErrUnknownEntry] {
+		runtime.Abort(exitcode.ExitCode(ErrUnknownEntry))
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+// decodeFaultHeader decodes the header of a block reported in a consensus fault.
+func decodeFaultHeader(header []byte) *block.Block {
+	blk, err := block.DecodeBlock(header)
+	if err != nil {
+		runtime.Abort(exitcode.ExitCode(ErrInvalidConsensusFault))
+	}
+	return blk
+}
+
+// isConsensusFault returns true if two distinct blocks by the same miner, and the extra block
+// for a parent-grinding fault, are evidence of a fault of type `faultType`.
+func isConsensusFault(faultType uint64, block1, block2, extra *block.Block) bool {
+	if block1.Miner != block2.Miner || block1.Cid().Equals(block2.Cid()) {
+		return false
+	}
+	switch faultType {
+	case DoubleForkMiningFault:
+		return block1.Height == block2.Height
+	case TimeOffsetMiningFault:
+		return block1.Parents.Equals(block2.Parents) && block1.Height != block2.Height
+	case ParentGrindingFault:
+		// The second block is mined on the sibling of the first instead of the first.
+		return extra != nil && block2.Parents.Has(extra.Cid()) && !block2.Parents.Has(block1.Cid()) &&
+			extra.Height == block1.Height && extra.Parents.Equals(block1.Parents)
+	default:
+		return false
+	}
+}
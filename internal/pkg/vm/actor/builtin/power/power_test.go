@@ -0,0 +1,168 @@
+package power_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-hamt-ipld"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin/initactor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin/power"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+// powerFixture is a genesis VM with a miner whose worker key is the first address of the signer.
+type powerFixture struct {
+	vm     consensus.GenesisVM
+	st     state.Tree
+	store  *vm.Storage
+	bs     bstore.Blockstore
+	signer types.MockSigner
+	miner  address.Address
+}
+
+func newPowerFixture(ctx context.Context, t *testing.T) *powerFixture {
+	bs := bstore.NewBlockstore(repo.NewInMemoryRepo().Datastore())
+	st := state.NewTree(cborutil.NewIpldStore(bs))
+	store := vm.NewStorage(bs)
+	genVM := vm.NewVM(st, &store).(consensus.GenesisVM)
+	require.NoError(t, consensus.SetupDefaultActors(ctx, genVM, &store, st, types.TestProofsMode, "test"))
+
+	signer, _ := types.NewMockSignersAndKeyInfo(2)
+	for _, addr := range signer.Addresses {
+		constructorParams, err := encoding.Encode(addr)
+		require.NoError(t, err)
+		_, err = genVM.ApplyGenesisMessage(vmaddr.LegacyNetworkAddress, vmaddr.InitAddress, initactor.ExecMethodID, types.NewAttoFILFromFIL(1000), initactor.ExecParams{
+			ActorCodeCid:      types.AccountActorCodeCid,
+			ConstructorParams: constructorParams,
+		})
+		require.NoError(t, err)
+	}
+
+	worker := signer.Addresses[0]
+	ret, err := genVM.ApplyGenesisMessage(worker, vmaddr.StoragePowerAddress, power.CreateStorageMiner, types.ZeroAttoFIL, power.CreateStorageMinerParams{
+		OwnerAddr:  worker,
+		WorkerAddr: worker,
+		SectorSize: types.OneKiBSectorSize,
+	})
+	require.NoError(t, err)
+	minerAddr := ret.(address.Address)
+
+	_, err = genVM.ApplyGenesisMessage(worker, vmaddr.StoragePowerAddress, power.ProcessPowerReport, types.ZeroAttoFIL, power.ProcessPowerReportParams{
+		Report:     types.NewPowerReport(1024, 0),
+		UpdateAddr: minerAddr,
+	})
+	require.NoError(t, err)
+
+	return &powerFixture{vm: genVM, st: st, store: &store, bs: bs, signer: signer, miner: minerAddr}
+}
+
+// newBlock returns a block by the fixture's miner signed with the key of `signer`.
+func (f *powerFixture) newBlock(t *testing.T, height uint64, parents block.TipSetKey, root string, signer address.Address) *block.Block {
+	blk := &block.Block{
+		Miner:     f.miner,
+		Height:    height,
+		Parents:   parents,
+		StateRoot: e.NewCid(types.CidFromString(t, root)),
+	}
+	sig, err := f.signer.SignBytes(blk.SignatureData(), signer)
+	require.NoError(t, err)
+	blk.BlockSig = sig
+	return blk
+}
+
+func (f *powerFixture) report(t *testing.T, faultType uint64, block1, block2 *block.Block) error {
+	header1, err := encoding.Encode(block1)
+	require.NoError(t, err)
+	header2, err := encoding.Encode(block2)
+	require.NoError(t, err)
+	_, err = f.vm.ApplyGenesisMessage(f.signer.Addresses[1], vmaddr.StoragePowerAddress, power.ReportConsensusFault, types.ZeroAttoFIL, power.ReportConsensusFaultParams{
+		Header1:   header1,
+		Header2:   header2,
+		FaultType: faultType,
+	})
+	return err
+}
+
+func (f *powerFixture) activePower(ctx context.Context, t *testing.T) *types.BytesAmount {
+	powerActor, err := f.st.GetActor(ctx, vmaddr.StoragePowerAddress)
+	require.NoError(t, err)
+	var powerState power.State
+	require.NoError(t, f.store.Get(powerActor.Head.Cid, &powerState))
+	table, err := hamt.LoadNode(ctx, cborutil.NewIpldStore(f.bs), powerState.PowerTable, hamt.UseTreeBitWidth(actor.TreeBitWidth))
+	require.NoError(t, err)
+	var entry power.TableEntry
+	require.NoError(t, table.Find(ctx, f.miner.String(), &entry))
+	return entry.ActivePower
+}
+
+func TestReportConsensusFault(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	parents := block.NewTipSetKey(types.CidFromString(t, "parent"))
+	otherParents := block.NewTipSetKey(types.CidFromString(t, "other-parent"))
+	invalidFault := fmt.Sprintf("ExitCode(%d)", power.ErrInvalidConsensusFault)
+
+	t.Run("slashes the power of the miner", func(t *testing.T) {
+		f := newPowerFixture(ctx, t)
+		worker := f.signer.Addresses[0]
+		require.Equal(t, types.NewBytesAmount(1024), f.activePower(ctx, t))
+
+		block1 := f.newBlock(t, 10, parents, "state", worker)
+		block2 := f.newBlock(t, 10, otherParents, "other-state", worker)
+		require.NoError(t, f.report(t, power.DoubleForkMiningFault, block1, block2))
+		assert.Equal(t, types.NewBytesAmount(0), f.activePower(ctx, t))
+
+		// Blocks with the same parents at different epochs are a time-offset fault.
+		block3 := f.newBlock(t, 11, parents, "state", worker)
+		require.NoError(t, f.report(t, power.TimeOffsetMiningFault, block1, block3))
+	})
+
+	t.Run("rejects blocks not signed by the worker", func(t *testing.T) {
+		f := newPowerFixture(ctx, t)
+		worker, other := f.signer.Addresses[0], f.signer.Addresses[1]
+
+		block1 := f.newBlock(t, 10, parents, "state", worker)
+		block2 := f.newBlock(t, 10, otherParents, "other-state", other)
+		err := f.report(t, power.DoubleForkMiningFault, block1, block2)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), invalidFault)
+		assert.Equal(t, types.NewBytesAmount(1024), f.activePower(ctx, t))
+	})
+
+	t.Run("rejects blocks that are not evidence of the fault", func(t *testing.T) {
+		f := newPowerFixture(ctx, t)
+		worker := f.signer.Addresses[0]
+
+		block1 := f.newBlock(t, 10, parents, "state", worker)
+		block2 := f.newBlock(t, 11, otherParents, "other-state", worker)
+		for _, faultType := range []uint64{power.DoubleForkMiningFault, power.TimeOffsetMiningFault, power.ParentGrindingFault, 0} {
+			err := f.report(t, faultType, block1, block2)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), invalidFault)
+		}
+
+		// A block is not a fault with itself.
+		err := f.report(t, power.DoubleForkMiningFault, block1, block1)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), invalidFault)
+		assert.Equal(t, types.NewBytesAmount(1024), f.activePower(ctx, t))
+	})
+}
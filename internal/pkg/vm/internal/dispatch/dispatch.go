@@ -53,18 +53,21 @@ func (d *actorDispatcher) Dispatch(methodID types.MethodID, ctx interface{}, arg
 		reflect.ValueOf(ctx),
 	}
 
-	if raw, ok := arg1.([]byte); ok {
-		obj, err := m.ArgInterface(raw)
-		if err != nil {
-			return nil, err
+	// methods without params ignore arg1
+	if m.method.Type().NumIn() > 1 {
+		if raw, ok := arg1.([]byte); ok {
+			obj, err := m.ArgInterface(raw)
+			if err != nil {
+				return nil, err
+			}
+
+			// push decoded arg to args list
+			// Note: the `Elem()` call is to dereference the pointer created by `ArgInterface()`
+			args = append(args, reflect.ValueOf(obj).Elem())
+		} else {
+			// the argument was not in raw bytes, let it be coerced
+			args = append(args, reflect.ValueOf(arg1))
 		}
-
-		// push decoded arg to args list
-		// Note: the `Elem()` call is to dereference the pointer created by `ArgInterface()`
-		args = append(args, reflect.ValueOf(obj).Elem())
-	} else {
-		// the argument was not in raw bytes, let it be coerced
-		args = append(args, reflect.ValueOf(arg1))
 	}
 
 	// invoke the method
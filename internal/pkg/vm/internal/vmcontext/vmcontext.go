@@ -67,6 +67,11 @@ type actorStorage struct {
 	inner *storage.VMStorage
 }
 
+// NewActorStorage returns the storage the actors see over `store`.
+func NewActorStorage(store *storage.VMStorage) runtime.Storage {
+	return actorStorage{inner: store}
+}
+
 // NewVM creates a new runtime for executing messages.
 func NewVM(rnd RandomnessSource, actorImpls ActorImplLookup, store *storage.VMStorage, st state.Tree) VM {
 	return VM{
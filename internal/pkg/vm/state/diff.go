@@ -0,0 +1,58 @@
+package state
+
+import (
+	"context"
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+)
+
+// ActorDiff is an actor that differs between two state trees.  Before is nil
+// if the actor was added, After is nil if the actor was removed.
+type ActorDiff struct {
+	Address address.Address
+	Before  *actor.Actor
+	After   *actor.Actor
+}
+
+// Diff returns the actors that were added, removed or changed going from
+// state tree `before` to `after`, ordered by address.
+func Diff(ctx context.Context, before, after Tree) ([]*ActorDiff, error) {
+	beforeActors := make(map[address.Address]*actor.Actor)
+	if err := before.ForEachActor(ctx, func(addr address.Address, a *actor.Actor) error {
+		beforeActors[addr] = a
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	var diffs []*ActorDiff
+	if err := after.ForEachActor(ctx, func(addr address.Address, a *actor.Actor) error {
+		old, ok := beforeActors[addr]
+		delete(beforeActors, addr)
+		if ok && actorsEqual(old, a) {
+			return nil
+		}
+		diffs = append(diffs, &ActorDiff{Address: addr, Before: old, After: a})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	for addr, a := range beforeActors {
+		diffs = append(diffs, &ActorDiff{Address: addr, Before: a})
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Address.String() < diffs[j].Address.String()
+	})
+	return diffs, nil
+}
+
+func actorsEqual(a, b *actor.Actor) bool {
+	return a.Code.Equals(b.Code.Cid) &&
+		a.Head.Equals(b.Head.Cid) &&
+		a.CallSeqNum == b.CallSeqNum &&
+		a.Balance.Equal(b.Balance)
+}
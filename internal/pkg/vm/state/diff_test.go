@@ -0,0 +1,69 @@
+package state
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+func TestDiff(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	bs := bstore.NewBlockstore(repo.NewInMemoryRepo().Datastore())
+	cst := cborutil.NewIpldStore(bs)
+
+	addrGetter := vmaddr.NewForTestGetter()
+	kept, changed, removed, added := addrGetter(), addrGetter(), addrGetter(), addrGetter()
+
+	before := NewTree(cst)
+	keptActor := actor.NewActor(types.AccountActorCodeCid, types.NewAttoFILFromFIL(1))
+	changedBefore := actor.NewActor(types.AccountActorCodeCid, types.NewAttoFILFromFIL(2))
+	removedActor := actor.NewActor(types.AccountActorCodeCid, types.NewAttoFILFromFIL(3))
+	require.NoError(t, before.SetActor(ctx, kept, keptActor))
+	require.NoError(t, before.SetActor(ctx, changed, changedBefore))
+	require.NoError(t, before.SetActor(ctx, removed, removedActor))
+
+	after := NewTree(cst)
+	changedAfter := actor.NewActor(types.AccountActorCodeCid, types.NewAttoFILFromFIL(2))
+	changedAfter.IncrementSeqNum()
+	addedActor := actor.NewActor(types.AccountActorCodeCid, types.NewAttoFILFromFIL(4))
+	require.NoError(t, after.SetActor(ctx, kept, keptActor))
+	require.NoError(t, after.SetActor(ctx, changed, changedAfter))
+	require.NoError(t, after.SetActor(ctx, added, addedActor))
+
+	_, err := before.Flush(ctx)
+	require.NoError(t, err)
+	_, err = after.Flush(ctx)
+	require.NoError(t, err)
+
+	diffs, err := Diff(ctx, before, after)
+	require.NoError(t, err)
+	require.Len(t, diffs, 3)
+	for i := 1; i < len(diffs); i++ {
+		assert.True(t, diffs[i-1].Address.String() < diffs[i].Address.String())
+	}
+
+	byAddr := make(map[address.Address]*ActorDiff)
+	for _, diff := range diffs {
+		byAddr[diff.Address] = diff
+	}
+	assert.Equal(t, &ActorDiff{Address: changed, Before: changedBefore, After: changedAfter}, byAddr[changed])
+	assert.Equal(t, &ActorDiff{Address: removed, Before: removedActor}, byAddr[removed])
+	assert.Equal(t, &ActorDiff{Address: added, After: addedActor}, byAddr[added])
+
+	diffs, err = Diff(ctx, after, after)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+}
@@ -1,12 +1,14 @@
 package vm
 
 import (
+	"github.com/ipfs/go-cid"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/dispatch"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/interpreter"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/message"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/runtime"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/storage"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/vmcontext"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
@@ -37,6 +39,17 @@ func NewStorage(bs blockstore.Blockstore) Storage {
 	return storage.NewStorage(bs)
 }
 
+// NewActorStorage returns the storage the actors see over `store`, for reading actor state
+// with the actors' views outside of the VM.
+func NewActorStorage(store *Storage) runtime.Storage {
+	return vmcontext.NewActorStorage(store)
+}
+
+// NewReadonlyStateHandle returns a readonly handle on the actor state with head `head`.
+func NewReadonlyStateHandle(store runtime.Storage, head cid.Cid) runtime.ReadonlyActorStateHandle {
+	return vmcontext.NewReadonlyStateHandle(store, head)
+}
+
 // DefaultActors is a code loader with the built-in actors that come with the system.
 var DefaultActors = builtin.DefaultActors
 
@@ -9,6 +9,7 @@ import (
 	cli "gopkg.in/urfave/cli.v2"
 
 	export "github.com/filecoin-project/go-filecoin/tools/chain-util/pkg/export"
+	gc "github.com/filecoin-project/go-filecoin/tools/chain-util/pkg/gc"
 )
 
 var log = logging.Logger("chain-util")
@@ -18,8 +19,12 @@ func init() {
 }
 
 const (
-	repoFlag = "repo"
-	outFlag  = "out"
+	repoFlag       = "repo"
+	outFlag        = "out"
+	keepEpochsFlag = "keep-epochs"
+	recentFlag     = "recent"
+	fromHeightFlag = "from-height"
+	toHeightFlag   = "to-height"
 )
 
 var exportCmd = &cli.Command{
@@ -34,6 +39,18 @@ var exportCmd = &cli.Command{
 			Name:  outFlag,
 			Usage: "the file to export the chain to",
 		},
+		&cli.Uint64Flag{
+			Name:  recentFlag,
+			Usage: "export messages and state for only this many of the most recent tipsets",
+		},
+		&cli.Uint64Flag{
+			Name:  fromHeightFlag,
+			Usage: "the lowest height of the range of tipsets to export",
+		},
+		&cli.Uint64Flag{
+			Name:  toHeightFlag,
+			Usage: "the highest height of the range of tipsets to export",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		cfg, err := parseFlags(cctx)
@@ -44,17 +61,44 @@ var exportCmd = &cli.Command{
 		if err != nil {
 			return err
 		}
-		if err := chainOut.Export(context.Background()); err == nil {
+		if err := chainOut.Export(context.Background(), cfg.exportOpts); err == nil {
 			fmt.Printf("Exported chain with head: %s to: %s", chainOut.Head, cfg.outFile.Name())
 		}
 		return err
 	},
 }
 
+var gcCmd = &cli.Command{
+	Name:  "gc",
+	Usage: "Prune old chain state from a stopped node's repo",
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:  repoFlag,
+			Usage: "the repo where go-filecoin was initialized",
+		},
+		&cli.Uint64Flag{
+			Name:  keepEpochsFlag,
+			Usage: "the number of finalized epochs to keep state for",
+			Value: 1000,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		repoPath := cctx.Path(repoFlag)
+		if repoPath == "" {
+			return fmt.Errorf("filecoin repo path required")
+		}
+		res, err := gc.PruneRepo(context.Background(), repoPath, cctx.Uint64(keepEpochsFlag))
+		if err == nil {
+			fmt.Printf("Pruned state of %d tipsets (%d blocks), state kept from height %d", res.TipSetsPruned, res.BlocksDeleted, res.PrunedHeight)
+		}
+		return err
+	},
+}
+
 func main() {
 	app := &cli.App{
 		Name:     "chain-export",
-		Commands: []*cli.Command{exportCmd},
+		Commands: []*cli.Command{exportCmd, gcCmd},
 	}
 	app.Setup()
 
@@ -73,10 +73,11 @@ type ChainExporter struct {
 	out io.Writer
 }
 
-// Export will export a chain (all blocks and their messages) to the writer `out`.
-func (ce *ChainExporter) Export(ctx context.Context) error {
+// Export will export a chain (all blocks and their messages) to the writer `out`,
+// restricted to the part of the chain selected by `opts`.
+func (ce *ChainExporter) Export(ctx context.Context, opts chain.ExportOptions) error {
 	msgStore := chain.NewMessageStore(ce.bstore)
-	return chain.Export(ctx, ce.Head, ce, msgStore, ce, ce.out)
+	return chain.Export(ctx, ce.Head, ce, msgStore, ce, opts, ce.out)
 }
 
 // GetTipSet gets the TipSet for a given TipSetKey from the ChainExporter blockstore.
@@ -0,0 +1,65 @@
+package gc
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	logging "github.com/ipfs/go-log"
+	errors "github.com/pkg/errors"
+
+	block "github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	chain "github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	encoding "github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	repo "github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+)
+
+var log = logging.Logger("chain-util/gc")
+
+// PruneRepo prunes the chain state kept in the repo at `repoPath` as the
+// node's state pruner would, keeping state for `keepEpochs` finalized epochs.
+// The repo is locked while pruning so the node must not be running.
+func PruneRepo(ctx context.Context, repoPath string, keepEpochs uint64) (*chain.PruneResult, error) {
+	log.Infof("opening filecoin repo: %s", repoPath)
+	r, err := repo.OpenFSRepo(repoPath, repo.Version)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			log.Warnf("error closing repo: %s", err)
+		}
+	}()
+
+	bs := blockstore.NewBlockstore(r.Datastore())
+	tipsets := chain.TipSetProviderFromBlocks(ctx, blockSource{bs})
+
+	bb, err := r.ChainDatastore().Get(chain.HeadKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read HeadKey")
+	}
+	var headKey block.TipSetKey
+	if err := encoding.Decode(bb, &headKey); err != nil {
+		return nil, errors.Wrap(err, "failed to cast headCids")
+	}
+	head, err := tipsets.GetTipSet(headKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load head tipset")
+	}
+
+	return chain.NewStatePruner(r.ChainDatastore(), bs, tipsets).Prune(ctx, head, keepEpochs)
+}
+
+// blockSource reads block headers straight from a blockstore.
+type blockSource struct {
+	bs blockstore.Blockstore
+}
+
+// GetBlock gets a block header by cid.
+func (s blockSource) GetBlock(ctx context.Context, c cid.Cid) (*block.Block, error) {
+	bsBlk, err := s.bs.Get(c)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read key %s from blockstore", c)
+	}
+	return block.DecodeBlock(bsBlk.RawData())
+}
@@ -5,11 +5,15 @@ import (
 	"os"
 
 	cli "gopkg.in/urfave/cli.v2"
+
+	chain "github.com/filecoin-project/go-filecoin/internal/pkg/chain"
 )
 
 type config struct {
 	repoPath string
 	outFile  *os.File
+	// exportOpts selects the part of the chain to export
+	exportOpts chain.ExportOptions
 }
 
 func parseFlags(cctx *cli.Context) (*config, error) {
@@ -18,6 +22,13 @@ func parseFlags(cctx *cli.Context) (*config, error) {
 		return nil, fmt.Errorf("filecoin repo path required")
 	}
 
+	if cctx.IsSet(fromHeightFlag) && !cctx.IsSet(toHeightFlag) {
+		return nil, fmt.Errorf("%s requires %s", fromHeightFlag, toHeightFlag)
+	}
+	if cctx.IsSet(toHeightFlag) && cctx.Uint64(toHeightFlag) == 0 {
+		return nil, fmt.Errorf("%s must be above genesis", toHeightFlag)
+	}
+
 	out := cctx.Path(outFlag)
 	if out == "" {
 		return nil, fmt.Errorf("output file required")
@@ -31,6 +42,11 @@ func parseFlags(cctx *cli.Context) (*config, error) {
 	return &config{
 		repoPath: repoPath,
 		outFile:  f,
+		exportOpts: chain.ExportOptions{
+			RecentTipSets: cctx.Uint64(recentFlag),
+			FromHeight:    cctx.Uint64(fromHeightFlag),
+			ToHeight:      cctx.Uint64(toHeightFlag),
+		},
 	}, nil
 
 }